@@ -7,23 +7,35 @@ import (
 
 // The Container models a container in a supply chain
 type Container struct {
-	ID           string                 `json:"trackingID"`
-	Type         string                 `json:"docType"`
-	Health       string                 `json:"health"`
-	Contents     []string               `json:"contents"`
-	Metadata     map[string]interface{} `json:"misc"`
-	Custodian    string                 `json:"custodian"`
-	Location     string                 `json:"lastScannedAt"`
-	Timestamp    int64                  `json:"timestamp"`
-	ContainerID  string                 `json:"containerID"`
-	Participants []string               `json:"participants"`
+	ID                        string                 `json:"trackingID"`
+	Type                      string                 `json:"docType"`
+	Health                    string                 `json:"health"`
+	HealthReason              string                 `json:"healthReason,omitempty"`
+	Contents                  []string               `json:"contents"`
+	Metadata                  map[string]interface{} `json:"misc"`
+	Custodian                 string                 `json:"custodian"`
+	Owner                     string                 `json:"owner,omitempty"`
+	CustodySince              int64                  `json:"custodySince,omitempty"`
+	Location                  string                 `json:"lastScannedAt"`
+	Timestamp                 int64                  `json:"timestamp"`
+	ContainerID               string                 `json:"containerID"`
+	Participants              []string               `json:"participants"`
+	ShipmentID                string                 `json:"shipmentID,omitempty"`
+	PurchaseOrder             string                 `json:"purchaseOrder,omitempty"`
+	ContainerType             string                 `json:"containerType,omitempty"`
+	ContainerTypeOrganization string                 `json:"containerTypeOrganization,omitempty"`
+	Organization              string                 `json:"organization,omitempty"`
 }
 
 // AccessibleBy returns true or false depending on if the supplied organization is a member of the application
 func (container *Container) AccessibleBy(id *Identity) bool {
-	idType := id.Cert.Subject.String()
+	return container.HasParticipant(id.Cert.Subject.String())
+}
+
+// HasParticipant returns true if the supplied subject is a participant of the container
+func (container *Container) HasParticipant(subject string) bool {
 	for _, v := range container.Participants {
-		if v == idType {
+		if v == subject {
 			return true
 		}
 	}
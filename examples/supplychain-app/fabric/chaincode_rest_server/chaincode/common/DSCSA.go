@@ -0,0 +1,140 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TransactionStatement is the statement of a DSCSA transaction, attested by the transferring trade partner
+const TransactionStatement = "Seller has complied with each applicable subsection of FDCA Sec. 581(27)(A)-(G)."
+
+// Statuses of a verification request
+const (
+	VerificationPending     = "pending"
+	VerificationVerified    = "verified"
+	VerificationNotVerified = "not_verified"
+)
+
+// Statuses of a suspect product investigation
+const (
+	InvestigationSuspect      = "suspect"
+	InvestigationCleared      = "cleared"
+	InvestigationIllegitimate = "illegitimate"
+)
+
+// Reason codes set on suspect and illegitimate products
+const (
+	ReasonSuspectProduct      = "suspect_product"
+	ReasonIllegitimateProduct = "illegitimate_product"
+)
+
+// The TransactionInformation models the DSCSA transaction information and transaction statement recorded when a
+// prescription product changes custody
+type TransactionInformation struct {
+	Type        string  `json:"docType"`
+	ID          string  `json:"transactionID"`
+	TrackingID  string  `json:"trackingID"`
+	ProductName string  `json:"productName"`
+	Lot         string  `json:"lot,omitempty"`
+	Expiry      string  `json:"expiry,omitempty"`
+	Quantity    float64 `json:"quantity"`
+	Unit        string  `json:"unit"`
+	Seller      string  `json:"seller"`
+	Buyer       string  `json:"buyer"`
+	Location    string  `json:"location"`
+	Statement   string  `json:"transactionStatement"`
+	Timestamp   int64   `json:"timestamp"`
+}
+
+// NewTransactionInformation returns the transaction information of a prescription product handed over by the seller
+// to its current custodian
+func NewTransactionInformation(id string, product Product, seller string, timestamp int64) TransactionInformation {
+	quantity, unit := product.Quantity, product.Unit
+	if !product.Bulk() {
+		quantity, unit = 1, "pcs"
+	}
+	return TransactionInformation{
+		Type:        "dscsaTransaction",
+		ID:          id,
+		TrackingID:  product.ID,
+		ProductName: product.Name,
+		Lot:         product.Lot,
+		Expiry:      product.Expiry,
+		Quantity:    quantity,
+		Unit:        unit,
+		Seller:      seller,
+		Buyer:       product.Custodian,
+		Location:    product.Location,
+		Statement:   TransactionStatement,
+		Timestamp:   timestamp,
+	}
+}
+
+// The VerificationRequest models a request to the manufacturer to verify the SGTIN, lot and expiry of a product.
+// Products created before their manufacturer was recorded are verified by their creator instead.
+type VerificationRequest struct {
+	Type         string `json:"docType"`
+	ID           string `json:"requestID"`
+	TrackingID   string `json:"trackingID"`
+	Lot          string `json:"lot"`
+	Expiry       string `json:"expiry"`
+	Requester    string `json:"requester"`
+	Manufacturer string `json:"manufacturer"`
+	Creator      string `json:"creator,omitempty"`
+	Status       string `json:"status"`
+	Responder    string `json:"responder,omitempty"`
+	Timestamp    int64  `json:"timestamp"`
+	RespondedAt  int64  `json:"respondedAt,omitempty"`
+}
+
+// Verify answers the request by checking that the product exists with the requested lot and expiry
+func (request *VerificationRequest) Verify(product *Product) {
+	if product != nil && product.Lot == request.Lot && product.Expiry == request.Expiry {
+		request.Status = VerificationVerified
+	} else {
+		request.Status = VerificationNotVerified
+	}
+}
+
+// RespondableBy returns true if the supplied identity is a member of the manufacturer organization or, for products
+// without a recorded manufacturer, their creator
+func (request *VerificationRequest) RespondableBy(id *Identity) bool {
+	if request.Manufacturer != "" {
+		return request.Manufacturer == id.Organization
+	}
+	return request.Creator != "" && request.Creator == id.Cert.Subject.String()
+}
+
+// The Investigation models the investigation of a suspect product and its outcome, with the health of the product
+// before it was quarantined so that a cleared product gets it back
+type Investigation struct {
+	Type              string   `json:"docType"`
+	TrackingID        string   `json:"trackingID"`
+	Status            string   `json:"status"`
+	Reason            string   `json:"reason"`
+	Reporter          string   `json:"reporter"`
+	Manufacturer      string   `json:"manufacturer"`
+	PriorHealth       string   `json:"priorHealth"`
+	PriorHealthReason string   `json:"priorHealthReason,omitempty"`
+	Note              string   `json:"note,omitempty"`
+	Notified          []string `json:"notified"`
+	Timestamp         int64    `json:"timestamp"`
+}
+
+// Conclude closes the investigation of a suspect product as cleared or illegitimate
+func (investigation *Investigation) Conclude(status string, note string, timestamp int64) error {
+	if investigation.Status != InvestigationSuspect {
+		return fmt.Errorf("Investigation of %s is already %s", investigation.TrackingID, investigation.Status)
+	}
+	if status != InvestigationCleared && status != InvestigationIllegitimate {
+		return fmt.Errorf("Unknown outcome %s, expecting %s or %s", status, InvestigationCleared, InvestigationIllegitimate)
+	}
+	if status == InvestigationIllegitimate && strings.TrimSpace(note) == "" {
+		return errors.New("A note is required to mark a product illegitimate")
+	}
+	investigation.Status = status
+	investigation.Note = note
+	investigation.Timestamp = timestamp
+	return nil
+}
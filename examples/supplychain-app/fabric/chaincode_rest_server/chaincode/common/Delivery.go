@@ -0,0 +1,126 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Discrepancy states
+const (
+	DiscrepancyOpen     = "open"
+	DiscrepancyResolved = "resolved"
+)
+
+// The Delivery models the proof of delivery of a container, signed off by its receiver
+type Delivery struct {
+	Type          string                   `json:"docType"`
+	ID            string                   `json:"deliveryID"`
+	ContainerID   string                   `json:"containerID"`
+	ShipmentID    string                   `json:"shipmentID,omitempty"`
+	Sender        string                   `json:"sender"`
+	Receiver      string                   `json:"receiver"`
+	Expected      []string                 `json:"expected"`
+	Received      []string                 `json:"received"`
+	Missing       []string                 `json:"missing"`
+	Extra         []string                 `json:"extra"`
+	Damaged       []string                 `json:"damaged"`
+	Notes         string                   `json:"notes,omitempty"`
+	SignatureHash string                   `json:"signatureHash"`
+	DiscrepancyID string                   `json:"discrepancyID,omitempty"`
+	PurchaseOrder string                   `json:"purchaseOrder,omitempty"`
+	Exceptions    []PurchaseOrderException `json:"exceptions,omitempty"`
+	Timestamp     int64                    `json:"timestamp"`
+}
+
+// The DeliveryRequest models a request body confirming the delivery of a container, the sender is only needed for
+// containers delivered outside a shipment
+type DeliveryRequest struct {
+	Scanned       []string `json:"scanned"`
+	Damaged       []string `json:"damaged"`
+	Notes         string   `json:"notes"`
+	SignatureHash string   `json:"signatureHash"`
+	Sender        string   `json:"sender"`
+}
+
+// The Discrepancy models missing, extra or damaged items of a delivery, open for comments by the sender and receiver
+// until both accept a resolution
+type Discrepancy struct {
+	Type        string    `json:"docType"`
+	ID          string    `json:"discrepancyID"`
+	DeliveryID  string    `json:"deliveryID"`
+	ContainerID string    `json:"containerID"`
+	Parties     []string  `json:"parties"`
+	Missing     []string  `json:"missing"`
+	Extra       []string  `json:"extra"`
+	Damaged     []string  `json:"damaged"`
+	Status      string    `json:"status"`
+	Comments    []Comment `json:"comments"`
+	Accepted    []string  `json:"accepted"`
+	Timestamp   int64     `json:"timestamp"`
+}
+
+// The Comment models a comment of a party on a discrepancy
+type Comment struct {
+	Author    string `json:"author"`
+	Text      string `json:"text"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Validate checks the signature hash and that every damaged item was scanned
+func (request *DeliveryRequest) Validate() error {
+	if err := ValidateHash(request.SignatureHash); err != nil {
+		return fmt.Errorf("signatureHash: %s", err)
+	}
+	for _, id := range request.Damaged {
+		if !contains(request.Scanned, id) {
+			return fmt.Errorf("Damaged item %s was not scanned", id)
+		}
+	}
+	return nil
+}
+
+// Reconcile compares the expected contents of a container with the items scanned on delivery
+func (delivery *Delivery) Reconcile() {
+	delivery.Missing = difference(delivery.Expected, delivery.Received)
+	delivery.Extra = difference(delivery.Received, delivery.Expected)
+}
+
+// DamagedContents returns the damaged items that were expected in the container
+func (delivery *Delivery) DamagedContents() []string {
+	return difference(delivery.Damaged, delivery.Extra)
+}
+
+// Discrepant returns true if items of the delivery are missing, extra or damaged
+func (delivery *Delivery) Discrepant() bool {
+	return len(delivery.Missing) != 0 || len(delivery.Extra) != 0 || len(delivery.Damaged) != 0
+}
+
+// AccessibleBy returns true if the supplied identity is a party to the discrepancy
+func (discrepancy *Discrepancy) AccessibleBy(id *Identity) bool {
+	return contains(discrepancy.Parties, id.Cert.Subject.String())
+}
+
+// Accept records the acceptance of a resolution by a party, the discrepancy is resolved once every party accepted
+func (discrepancy *Discrepancy) Accept(party string) error {
+	if discrepancy.Status != DiscrepancyOpen {
+		return errors.New("Discrepancy is already resolved")
+	}
+	if !contains(discrepancy.Accepted, party) {
+		discrepancy.Accepted = append(discrepancy.Accepted, party)
+	}
+	if len(difference(discrepancy.Parties, discrepancy.Accepted)) == 0 {
+		discrepancy.Status = DiscrepancyResolved
+	}
+	return nil
+}
+
+// difference returns the items of a that are not in b
+func difference(a []string, b []string) []string {
+	result := []string{}
+	for _, item := range a {
+		if !contains(b, item) && !contains(result, item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
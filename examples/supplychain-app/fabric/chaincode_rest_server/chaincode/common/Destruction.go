@@ -0,0 +1,54 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DisposalMethods lists every valid method of destroying an item
+var DisposalMethods = []string{"incineration", "chemical", "shredding", "landfill", "recycling", "other"}
+
+// The DestructionCertificate models the certificate of destruction of an item and everything packaged into it, the
+// witness confirms it by signing a transaction of its own
+type DestructionCertificate struct {
+	Type         string   `json:"docType"`
+	ID           string   `json:"certificateID"`
+	TrackingID   string   `json:"trackingID"`
+	Items        []string `json:"items"`
+	Method       string   `json:"method"`
+	Witness      string   `json:"witness"`
+	Reason       string   `json:"reason"`
+	DestroyedBy  string   `json:"destroyedBy"`
+	Organization string   `json:"organization"`
+	Location     string   `json:"location,omitempty"`
+	WitnessedAt  int64    `json:"witnessedAt,omitempty"`
+	Timestamp    int64    `json:"timestamp"`
+}
+
+// The DestructionRequest models a request body for destroying an item
+type DestructionRequest struct {
+	Method   string `json:"method"`
+	Witness  string `json:"witness"`
+	Reason   string `json:"reason"`
+	Location string `json:"location"`
+}
+
+// Validate checks the disposal method and that the destruction has a reason and a witness other than the destroyer
+func (request *DestructionRequest) Validate(destroyer string) error {
+	if !contains(DisposalMethods, request.Method) {
+		return fmt.Errorf("Unknown disposal method %s, expecting one of %v", request.Method, DisposalMethods)
+	}
+	if strings.TrimSpace(request.Reason) == "" {
+		return errors.New("A reason is required")
+	}
+	if strings.TrimSpace(request.Witness) == "" || request.Witness == destroyer {
+		return errors.New("A witness other than the destroyer is required")
+	}
+	return nil
+}
+
+// Witnessed returns true once the witness confirmed the destruction
+func (certificate *DestructionCertificate) Witnessed() bool {
+	return certificate.WitnessedAt != 0
+}
@@ -0,0 +1,109 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Supported device key algorithms
+const (
+	AlgorithmECDSA   = "ECDSA"
+	AlgorithmEd25519 = "Ed25519"
+)
+
+// The Device models an IoT sensor or scanner registered by an organization
+type Device struct {
+	Type        string       `json:"docType"`
+	ID          string       `json:"deviceID"`
+	Owner       string       `json:"owner"`
+	PublicKey   string       `json:"publicKey"`
+	Algorithm   string       `json:"algorithm"`
+	ContainerID string       `json:"containerID"`
+	Revoked     bool         `json:"revoked"`
+	LastReading int64        `json:"lastReading"`
+	LastScan    int64        `json:"lastScan"`
+	RetiredKeys []RetiredKey `json:"retiredKeys"`
+	Timestamp   int64        `json:"timestamp"`
+}
+
+// The RetiredKey models a device key replaced by a key rotation
+type RetiredKey struct {
+	PublicKey string `json:"publicKey"`
+	RetiredAt int64  `json:"retiredAt"`
+}
+
+// The DeviceRequest models a request body for device registration
+type DeviceRequest struct {
+	ID          string `json:"deviceID"`
+	PublicKey   string `json:"publicKey"`
+	ContainerID string `json:"containerID"`
+}
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// KeyAlgorithm parses a PEM encoded public key and returns its algorithm
+func KeyAlgorithm(publicKey string) (string, error) {
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey:
+		return AlgorithmECDSA, nil
+	case ed25519.PublicKey:
+		return AlgorithmEd25519, nil
+	}
+	return "", errors.New("Device keys must be ECDSA or Ed25519")
+}
+
+// Verify checks a base64 encoded signature of the payload against the current device key. ECDSA
+// signatures are ASN.1 encoded over the SHA-256 digest, Ed25519 signatures over the payload itself.
+func (device *Device) Verify(payload []byte, signature string) error {
+	if device.Revoked {
+		return fmt.Errorf("Device %s is revoked", device.ID)
+	}
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("Signature must be base64 encoded: %s", err)
+	}
+	key, err := parsePublicKey(device.PublicKey)
+	if err != nil {
+		return err
+	}
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		var sig ecdsaSignature
+		if _, err := asn1.Unmarshal(signatureBytes, &sig); err != nil || sig.R == nil || sig.S == nil {
+			return fmt.Errorf("Invalid signature of device %s", device.ID)
+		}
+		digest := sha256.Sum256(payload)
+		if !ecdsa.Verify(key, digest[:], sig.R, sig.S) {
+			return fmt.Errorf("Invalid signature of device %s", device.ID)
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, payload, signatureBytes) {
+			return fmt.Errorf("Invalid signature of device %s", device.ID)
+		}
+	default:
+		return errors.New("Device keys must be ECDSA or Ed25519")
+	}
+	return nil
+}
+
+func parsePublicKey(publicKey string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil {
+		return nil, errors.New("Device key must be a PEM encoded public key")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
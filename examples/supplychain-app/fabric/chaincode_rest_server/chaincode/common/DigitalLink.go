@@ -0,0 +1,192 @@
+package common
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// AI for lot/batch numbers and expiry dates carried alongside a trackingID
+const (
+	AILot    = "10"
+	AIExpiry = "17"
+)
+
+// groupSeparator terminates variable length AIs in raw element strings
+const groupSeparator = "\x1d"
+
+// fixedLengths holds the data length of the fixed length AIs understood by ParseIdentifier
+var fixedLengths = map[string]int{AISSCC: 18, AIGTIN: 14, AIExpiry: 6}
+
+// supportedAIs holds the AIs understood by ParseIdentifier
+var supportedAIs = map[string]bool{AISSCC: true, AIGTIN: true, AILot: true, AIExpiry: true, AISerial: true}
+
+var bracketedAI = regexp.MustCompile(`\(([0-9]{2,4})\)`)
+
+// The Identifier is a scanned trackingID resolved to its canonical form
+type Identifier struct {
+	TrackingID string `json:"trackingID"`
+	GS1        bool   `json:"-"`
+	Lot        string `json:"lot,omitempty"`
+	Expiry     string `json:"expiry,omitempty"`
+}
+
+// ParseIdentifier resolves a GS1 Digital Link URI or GS1 element string to the canonical SGTIN
+// or SSCC trackingID, keeping the lot and expiry AIs. Anything else is returned unchanged.
+func ParseIdentifier(input string) (Identifier, error) {
+	var values map[string]string
+	var err error
+	switch {
+	case strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://"):
+		values, err = parseDigitalLink(input)
+	case strings.HasPrefix(input, "("):
+		values, err = parseBracketed(input)
+	case strings.HasPrefix(input, "]d2") || strings.HasPrefix(input, "]Q3") || strings.HasPrefix(input, "]C1") || strings.HasPrefix(input, groupSeparator):
+		values, err = parseRaw(input)
+	default:
+		return Identifier{TrackingID: input}, nil
+	}
+	if err != nil {
+		return Identifier{}, err
+	}
+	return identifierFromAIs(values)
+}
+
+func identifierFromAIs(values map[string]string) (Identifier, error) {
+	identifier := Identifier{GS1: true, Lot: values[AILot]}
+	if expiry, ok := values[AIExpiry]; ok {
+		date, err := parseExpiry(expiry)
+		if err != nil {
+			return Identifier{}, err
+		}
+		identifier.Expiry = date
+	}
+
+	switch {
+	case values[AISSCC] != "":
+		identifier.TrackingID = FormatSSCC(values[AISSCC])
+		if _, err := ParseSSCC(identifier.TrackingID); err != nil {
+			return Identifier{}, err
+		}
+	case values[AIGTIN] != "" && values[AISerial] != "":
+		identifier.TrackingID = FormatSGTIN(values[AIGTIN], values[AISerial])
+		if _, _, err := ParseSGTIN(identifier.TrackingID); err != nil {
+			return Identifier{}, err
+		}
+	default:
+		return Identifier{}, fmt.Errorf("GS1 identifier needs an SSCC (00) or a GTIN (01) with a serial number (21)")
+	}
+	return identifier, nil
+}
+
+// parseDigitalLink reads the AIs from the path and query of a GS1 Digital Link URI
+func parseDigitalLink(input string) (map[string]string, error) {
+	uri, err := url.Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	segments := strings.Split(strings.Trim(uri.EscapedPath(), "/"), "/")
+	for i := range segments {
+		if segments[i] != AIGTIN && segments[i] != AISSCC {
+			continue
+		}
+		//the primary key and its qualifiers are the remaining AI/value pairs of the path
+		for j := i; j+1 < len(segments); j += 2 {
+			if !supportedAIs[segments[j]] {
+				return nil, fmt.Errorf("Unsupported AI (%s)", segments[j])
+			}
+			value, err := url.PathUnescape(segments[j+1])
+			if err != nil {
+				return nil, err
+			}
+			values[segments[j]] = value
+		}
+		break
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%s is not a GS1 Digital Link URI", input)
+	}
+	for ai, value := range uri.Query() {
+		if _, ok := values[ai]; !ok && len(value) > 0 {
+			values[ai] = value[0]
+		}
+	}
+	return values, nil
+}
+
+// parseBracketed reads a human readable element string such as (01)09506000134352(21)ABC(17)251231
+func parseBracketed(input string) (map[string]string, error) {
+	values := map[string]string{}
+	matches := bracketedAI.FindAllStringSubmatchIndex(input, -1)
+	if len(matches) == 0 || matches[0][0] != 0 {
+		return nil, fmt.Errorf("%s is not a GS1 element string", input)
+	}
+	for i, match := range matches {
+		if ai := input[match[2]:match[3]]; !supportedAIs[ai] {
+			return nil, fmt.Errorf("Unsupported AI (%s)", ai)
+		}
+		end := len(input)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		values[input[match[2]:match[3]]] = input[match[1]:end]
+	}
+	return values, nil
+}
+
+// parseRaw reads an unbracketed element string as sent by scanners, with a symbology
+// identifier prefix and group separators after variable length AIs
+func parseRaw(input string) (map[string]string, error) {
+	if !strings.HasPrefix(input, groupSeparator) {
+		input = input[3:]
+	}
+	input = strings.TrimPrefix(input, groupSeparator)
+	values := map[string]string{}
+	for len(input) > 0 {
+		if len(input) < 2 {
+			return nil, fmt.Errorf("Truncated GS1 element string")
+		}
+		ai := input[:2]
+		input = input[2:]
+		if length, ok := fixedLengths[ai]; ok {
+			if len(input) < length {
+				return nil, fmt.Errorf("AI (%s) needs %d characters", ai, length)
+			}
+			values[ai] = input[:length]
+			input = strings.TrimPrefix(input[length:], groupSeparator)
+			continue
+		}
+		if ai != AILot && ai != AISerial {
+			return nil, fmt.Errorf("Unsupported AI (%s)", ai)
+		}
+		end := strings.Index(input, groupSeparator)
+		if end < 0 {
+			end = len(input)
+		}
+		values[ai] = input[:end]
+		input = strings.TrimPrefix(input[end:], groupSeparator)
+	}
+	return values, nil
+}
+
+// parseExpiry turns a YYMMDD expiry date into YYYY-MM-DD, a day of 00 meaning the end of the month
+func parseExpiry(expiry string) (string, error) {
+	if len(expiry) != 6 || !digitsPattern.MatchString(expiry) {
+		return "", fmt.Errorf("Expiry date %s must be YYMMDD", expiry)
+	}
+	day := expiry[4:]
+	if day == "00" {
+		day = "01"
+	}
+	date, err := time.Parse("060102", expiry[:4]+day)
+	if err != nil {
+		return "", fmt.Errorf("Expiry date %s must be YYMMDD", expiry)
+	}
+	if expiry[4:] == "00" {
+		date = date.AddDate(0, 1, -1)
+	}
+	return date.Format(ExpiryLayout), nil
+}
@@ -0,0 +1,143 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Dispute states
+const (
+	DisputeOpen        = "open"
+	DisputeUnderReview = "under_review"
+	DisputeAccepted    = "accepted"
+	DisputeRejected    = "rejected"
+	DisputeSettled     = "settled"
+)
+
+// respondentTransitions are the dispute states the respondent can move a dispute to, by current state
+var respondentTransitions = map[string][]string{
+	DisputeOpen:        {DisputeUnderReview, DisputeAccepted, DisputeRejected},
+	DisputeUnderReview: {DisputeAccepted, DisputeRejected},
+}
+
+// raiserTransitions are the dispute states the raiser can move a dispute to, by current state. A rejected dispute
+// can be taken back under review, for example with new evidence.
+var raiserTransitions = map[string][]string{
+	DisputeAccepted: {DisputeSettled},
+	DisputeRejected: {DisputeUnderReview, DisputeSettled},
+}
+
+// The Dispute models a claim of a participant against the custodian of items during a custody interval
+type Dispute struct {
+	Type          string         `json:"docType"`
+	ID            string         `json:"disputeID"`
+	TrackingIDs   []string       `json:"trackingIDs"`
+	Interval      CustodyPeriod  `json:"custodyInterval"`
+	DiscrepancyID string         `json:"discrepancyID,omitempty"`
+	Description   string         `json:"description"`
+	Raiser        string         `json:"raiser"`
+	Respondent    string         `json:"respondent"`
+	Organizations []string       `json:"organizations"`
+	Status        string         `json:"status"`
+	Evidence      []Evidence     `json:"evidence"`
+	History       []DisputeEvent `json:"history"`
+	Timestamp     int64          `json:"timestamp"`
+}
+
+// The CustodyPeriod models the interval an item was held by the respondent of a dispute, To is 0 while it is held
+type CustodyPeriod struct {
+	Custodian string `json:"custodian"`
+	From      int64  `json:"from"`
+	To        int64  `json:"to,omitempty"`
+}
+
+// The Evidence models an attachment added to a dispute by one of its parties
+type Evidence struct {
+	Attachment
+	AddedBy   string `json:"addedBy"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// The DisputeEvent models a change of the state of a dispute
+type DisputeEvent struct {
+	Status    string `json:"status"`
+	By        string `json:"by"`
+	Note      string `json:"note,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// The DisputeRequest models a request body for raising a dispute, from and to are RFC 3339 times
+type DisputeRequest struct {
+	TrackingIDs   []string     `json:"trackingIDs"`
+	Respondent    string       `json:"respondent"`
+	From          string       `json:"from"`
+	To            string       `json:"to"`
+	DiscrepancyID string       `json:"discrepancyID"`
+	Description   string       `json:"description"`
+	Evidence      []Attachment `json:"evidence"`
+}
+
+// Validate checks the request and returns the custody interval in question
+func (request *DisputeRequest) Validate() (CustodyPeriod, error) {
+	period := CustodyPeriod{Custodian: request.Respondent}
+	if len(request.TrackingIDs) == 0 {
+		return period, errors.New("at least one trackingID is required")
+	}
+	if request.Respondent == "" {
+		return period, errors.New("respondent is required")
+	}
+	from, err := time.Parse(time.RFC3339, request.From)
+	if err != nil {
+		return period, fmt.Errorf("Invalid from %s, expecting an RFC 3339 time", request.From)
+	}
+	period.From = from.UTC().Unix()
+	if request.To != "" {
+		to, err := time.Parse(time.RFC3339, request.To)
+		if err != nil {
+			return period, fmt.Errorf("Invalid to %s, expecting an RFC 3339 time", request.To)
+		}
+		if !to.After(from) {
+			return period, errors.New("Custody interval must end after it starts")
+		}
+		period.To = to.UTC().Unix()
+	}
+	for _, evidence := range request.Evidence {
+		if err := ValidateHash(evidence.Hash); err != nil {
+			return period, err
+		}
+	}
+	return period, nil
+}
+
+// AccessibleBy returns true if the supplied identity belongs to the organization of the raiser or respondent
+func (dispute *Dispute) AccessibleBy(id *Identity) bool {
+	return contains(dispute.Organizations, SubjectOrganization(id.Cert.Subject.String()))
+}
+
+// Closed returns true once a dispute is settled
+func (dispute *Dispute) Closed() bool {
+	return dispute.Status == DisputeSettled
+}
+
+// Transition moves the dispute to the supplied state if the party is allowed to do so from its current state
+func (dispute *Dispute) Transition(party string, status string, note string, timestamp int64) error {
+	allowed := []string{}
+	if party == dispute.Respondent {
+		allowed = append(allowed, respondentTransitions[dispute.Status]...)
+	}
+	if party == dispute.Raiser {
+		allowed = append(allowed, raiserTransitions[dispute.Status]...)
+	}
+	if !contains(allowed, status) {
+		return fmt.Errorf("Cannot move dispute from %s to %s", dispute.Status, status)
+	}
+	dispute.Status = status
+	dispute.History = append(dispute.History, DisputeEvent{
+		Status:    status,
+		By:        party,
+		Note:      note,
+		Timestamp: timestamp,
+	})
+	return nil
+}
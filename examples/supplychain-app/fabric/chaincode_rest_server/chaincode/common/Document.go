@@ -0,0 +1,45 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// The DocumentAnchor models the anchor of an off-chain document such as a bill of lading, invoice or customs form
+type DocumentAnchor struct {
+	Type         string `json:"docType"`
+	ID           string `json:"documentID"`
+	TrackingID   string `json:"trackingID"`
+	Name         string `json:"name,omitempty"`
+	Hash         string `json:"hash"`
+	MediaType    string `json:"mediaType"`
+	URI          string `json:"uri"`
+	Uploader     string `json:"uploader"`
+	Organization string `json:"organization"`
+	Timestamp    int64  `json:"timestamp"`
+}
+
+// The DocumentRequest models a request body for anchoring a document
+type DocumentRequest struct {
+	Name      string `json:"name"`
+	Hash      string `json:"hash"`
+	MediaType string `json:"mediaType"`
+	URI       string `json:"uri"`
+}
+
+// Validate checks the SHA-256 hash, the media type and that the document has a URI such as an IPFS CID or S3 key
+func (request *DocumentRequest) Validate() error {
+	request.Hash = strings.ToLower(request.Hash)
+	if err := ValidateHash(request.Hash); err != nil {
+		return err
+	}
+	if _, _, err := mime.ParseMediaType(request.MediaType); err != nil {
+		return fmt.Errorf("Invalid media type %s", request.MediaType)
+	}
+	if strings.TrimSpace(request.URI) == "" {
+		return errors.New("uri is required")
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DefaultCarrierDwell is the dwell SLA of carriers in hours until a manufacturer sets the dwell SLAs
+const DefaultCarrierDwell = 48
+
+// The DwellSLAs model the hours the products of a manufacturer organization may stay with a custodian, by role. The
+// role of a custodian is an organizational unit of its identity, such as Carrier or Warehouse.
+type DwellSLAs struct {
+	Type         string             `json:"docType"`
+	Organization string             `json:"organization,omitempty"`
+	Hours        map[string]float64 `json:"hours"`
+}
+
+// The Dwell models how long an item has been, or was, with a custodian and whether that breaches the SLA of its role
+type Dwell struct {
+	TrackingID   string  `json:"trackingID"`
+	Custodian    string  `json:"custodian"`
+	Organization string  `json:"organization"`
+	Role         string  `json:"role,omitempty"`
+	Since        int64   `json:"since"`
+	Seconds      int64   `json:"seconds"`
+	Hours        float64 `json:"hours"`
+	SLAHours     float64 `json:"slaHours,omitempty"`
+	Breached     bool    `json:"breached"`
+}
+
+// The SLABreaches model the items breaching their dwell SLA with one custodian
+type SLABreaches struct {
+	Custodian string  `json:"custodian"`
+	Items     []Dwell `json:"items"`
+}
+
+// The DwellStatistics model the completed custody intervals of an organization
+type DwellStatistics struct {
+	Type         string  `json:"docType"`
+	Organization string  `json:"organization"`
+	Intervals    int     `json:"intervals"`
+	TotalSeconds int64   `json:"totalSeconds"`
+	MaxSeconds   int64   `json:"maxSeconds"`
+	Breaches     int     `json:"breaches"`
+	AverageHours float64 `json:"averageHours"`
+}
+
+// DefaultDwellSLAs returns the dwell SLAs in effect until a manufacturer sets its own
+func DefaultDwellSLAs() DwellSLAs {
+	return DwellSLAs{
+		Hours: map[string]float64{"Carrier": DefaultCarrierDwell},
+	}
+}
+
+// Validate checks that every role has a positive number of hours
+func (slas *DwellSLAs) Validate() error {
+	if len(slas.Hours) == 0 {
+		return errors.New("At least one role is required")
+	}
+	for role, hours := range slas.Hours {
+		if strings.TrimSpace(role) == "" {
+			return errors.New("Roles must not be empty")
+		}
+		if hours <= 0 {
+			return fmt.Errorf("Dwell SLA of %s must be positive", role)
+		}
+	}
+	return nil
+}
+
+// For returns the role of the custodian the SLAs have hours for and those hours, or no role and zero hours if
+// none of the organizational units of the custodian has an SLA
+func (slas *DwellSLAs) For(custodian string) (string, float64) {
+	for _, unit := range SubjectAttribute(custodian, "OU") {
+		for role, hours := range slas.Hours {
+			if strings.EqualFold(role, unit) {
+				return role, hours
+			}
+		}
+	}
+	return "", 0
+}
+
+// SubjectAttribute returns the values of an attribute of a distinguished name such as
+// OU=user+OU=Manufacturer,O=PartyA,C=CH
+func SubjectAttribute(subject string, attribute string) []string {
+	values := []string{}
+	for _, rdn := range strings.Split(subject, ",") {
+		for _, pair := range strings.Split(rdn, "+") {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) == 2 && strings.TrimSpace(parts[0]) == attribute {
+				values = append(values, parts[1])
+			}
+		}
+	}
+	return values
+}
+
+// SubjectOrganization returns the organization of a distinguished name, or the name itself if it has none
+func SubjectOrganization(subject string) string {
+	if organizations := SubjectAttribute(subject, "O"); len(organizations) != 0 {
+		return organizations[0]
+	}
+	return subject
+}
+
+// NewDwell returns the dwell of an item with a custodian from since until now
+func NewDwell(trackingID string, custodian string, since int64, now int64, slas DwellSLAs) Dwell {
+	role, hours := slas.For(custodian)
+	seconds := now - since
+	if seconds < 0 {
+		seconds = 0
+	}
+	return Dwell{
+		TrackingID:   trackingID,
+		Custodian:    custodian,
+		Organization: SubjectOrganization(custodian),
+		Role:         role,
+		Since:        since,
+		Seconds:      seconds,
+		Hours:        math.Round(float64(seconds)/36) / 100,
+		SLAHours:     hours,
+		Breached:     hours > 0 && float64(seconds) > hours*3600,
+	}
+}
+
+// Add aggregates a completed custody interval into the statistics
+func (stats *DwellStatistics) Add(dwell Dwell) {
+	stats.Intervals++
+	stats.TotalSeconds += dwell.Seconds
+	if dwell.Seconds > stats.MaxSeconds {
+		stats.MaxSeconds = dwell.Seconds
+	}
+	if dwell.Breached {
+		stats.Breaches++
+	}
+	stats.AverageHours = math.Round(float64(stats.TotalSeconds)/float64(stats.Intervals)/36) / 100
+}
+
+// CustodyStart returns when the custodian took custody of the product, the last update for products from before
+// custody changes were timestamped
+func (product *Product) CustodyStart() int64 {
+	if product.CustodySince == 0 {
+		return product.Timestamp
+	}
+	return product.CustodySince
+}
+
+// CustodyStart returns when the custodian took custody of the container, the last update for containers from
+// before custody changes were timestamped
+func (container *Container) CustodyStart() int64 {
+	if container.CustodySince == 0 {
+		return container.Timestamp
+	}
+	return container.CustodySince
+}
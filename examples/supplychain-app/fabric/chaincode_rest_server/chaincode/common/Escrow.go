@@ -0,0 +1,67 @@
+package common
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// Statuses of the escrow of a purchase order
+const (
+	EscrowLocked   = "locked"
+	EscrowReleased = "released"
+)
+
+// The EscrowConfig models the token or escrow chaincode on the channel that a purchase order is settled through, named
+// by the buyer when issuing the order and agreed to by the seller accepting it
+type EscrowConfig struct {
+	Chaincode string `json:"chaincode"`
+	Channel   string `json:"channel,omitempty"`
+}
+
+// The EscrowAccount models the funds of a purchase order locked in the escrow chaincode, the amount released to the
+// seller so far and a release that failed and is pending, with its error
+type EscrowAccount struct {
+	Chaincode string  `json:"chaincode"`
+	Channel   string  `json:"channel,omitempty"`
+	Reference string  `json:"reference"`
+	Amount    float64 `json:"amount"`
+	Released  float64 `json:"released"`
+	Pending   float64 `json:"pending,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	Status    string  `json:"status"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// Validate checks that the escrow chaincode is named
+func (config *EscrowConfig) Validate() error {
+	if strings.TrimSpace(config.Chaincode) == "" {
+		return errors.New("An escrow chaincode name is required")
+	}
+	return nil
+}
+
+// Amount returns the value of the purchase order, the ordered quantities at their unit prices
+func (po *PurchaseOrder) Amount() float64 {
+	var amount float64
+	for _, line := range po.Lines {
+		amount += line.Quantity * line.Price
+	}
+	return RoundQuantity(amount)
+}
+
+// DueAmount returns the value of the fulfilled quantities, up to the ordered ones, not yet released from escrow
+func (po *PurchaseOrder) DueAmount() float64 {
+	if po.Escrow == nil {
+		return 0
+	}
+	var fulfilled float64
+	for _, line := range po.Lines {
+		fulfilled += math.Min(line.Fulfilled, line.Quantity) * line.Price
+	}
+	due := RoundQuantity(fulfilled - po.Escrow.Released)
+	if due < 0 || EqualQuantity(due, 0) {
+		return 0
+	}
+	return due
+}
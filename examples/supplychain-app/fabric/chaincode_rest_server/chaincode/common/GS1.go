@@ -0,0 +1,153 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SchemeGS1 enables SGTIN product and SSCC container trackingIDs
+const SchemeGS1 = "gs1"
+
+// GS1 application identifiers used in trackingIDs
+const (
+	AISSCC   = "00"
+	AIGTIN   = "01"
+	AISerial = "21"
+)
+
+var digitsPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// serialPattern matches the GS1 AI encodable character set 82, up to 20 characters, as used by serial and lot numbers
+var serialPattern = regexp.MustCompile(`^[!"%&'()*+,\-./0-9:;<=>?A-Z_a-z]{1,20}$`)
+
+// The IdentifierScheme models the trackingID rules and SSCC numbering of an organization
+type IdentifierScheme struct {
+	Type           string `json:"docType"`
+	Organization   string `json:"organization"`
+	Scheme         string `json:"scheme"`
+	CompanyPrefix  string `json:"companyPrefix"`
+	ExtensionDigit int    `json:"extensionDigit"`
+}
+
+// The SSCCCounter models the next serial reference of a GS1 company prefix and extension digit, registered to the
+// organization that set the prefix first
+type SSCCCounter struct {
+	Type                string `json:"docType"`
+	Organization        string `json:"organization"`
+	CompanyPrefix       string `json:"companyPrefix"`
+	ExtensionDigit      int    `json:"extensionDigit"`
+	NextSerialReference int64  `json:"nextSerialReference"`
+}
+
+// Validate checks the company prefix and extension digit of the scheme
+func (scheme *IdentifierScheme) Validate() error {
+	if scheme.Scheme != "" && scheme.Scheme != SchemeGS1 {
+		return fmt.Errorf("Unknown identifier scheme %s", scheme.Scheme)
+	}
+	if scheme.CompanyPrefix == "" {
+		return nil
+	}
+	if !digitsPattern.MatchString(scheme.CompanyPrefix) || len(scheme.CompanyPrefix) < 6 || len(scheme.CompanyPrefix) > 12 {
+		return errors.New("GS1 company prefix must be 6 to 12 digits")
+	}
+	if scheme.ExtensionDigit < 0 || scheme.ExtensionDigit > 9 {
+		return errors.New("SSCC extension digit must be between 0 and 9")
+	}
+	return nil
+}
+
+// CheckDigit returns the GS1 modulo 10 check digit for the supplied digits
+func CheckDigit(digits string) int {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return (10 - sum%10) % 10
+}
+
+func validCheckDigit(digits string) bool {
+	last := len(digits) - 1
+	return CheckDigit(digits[:last]) == int(digits[last]-'0')
+}
+
+// ValidateGTIN checks a GTIN-8, GTIN-12, GTIN-13 or GTIN-14 including its check digit
+func ValidateGTIN(gtin string) error {
+	if !digitsPattern.MatchString(gtin) {
+		return fmt.Errorf("GTIN %s must only contain digits", gtin)
+	}
+	switch len(gtin) {
+	case 8, 12, 13, 14:
+	default:
+		return fmt.Errorf("GTIN %s must be 8, 12, 13 or 14 digits", gtin)
+	}
+	if !validCheckDigit(gtin) {
+		return fmt.Errorf("GTIN %s has an invalid check digit", gtin)
+	}
+	return nil
+}
+
+// FormatSGTIN returns the canonical trackingID of a GTIN and serial number, (01)<GTIN-14>(21)<serial>
+func FormatSGTIN(gtin string, serial string) string {
+	if len(gtin) < 14 {
+		gtin = strings.Repeat("0", 14-len(gtin)) + gtin
+	}
+	return "(" + AIGTIN + ")" + gtin + "(" + AISerial + ")" + serial
+}
+
+// ParseSGTIN splits a canonical SGTIN trackingID into its GTIN-14 and serial number and validates both
+func ParseSGTIN(trackingID string) (string, string, error) {
+	prefix := "(" + AIGTIN + ")"
+	separator := "(" + AISerial + ")"
+	if !strings.HasPrefix(trackingID, prefix) || len(trackingID) < len(prefix)+14+len(separator) {
+		return "", "", fmt.Errorf("trackingID %s is not an SGTIN, expecting (01)<GTIN-14>(21)<serial>", trackingID)
+	}
+	gtin := trackingID[len(prefix) : len(prefix)+14]
+	rest := trackingID[len(prefix)+14:]
+	if !strings.HasPrefix(rest, separator) {
+		return "", "", fmt.Errorf("trackingID %s is not an SGTIN, expecting (01)<GTIN-14>(21)<serial>", trackingID)
+	}
+	serial := strings.TrimPrefix(rest, separator)
+	if err := ValidateGTIN(gtin); err != nil {
+		return "", "", err
+	}
+	if !serialPattern.MatchString(serial) {
+		return "", "", fmt.Errorf("Serial number %s must be 1 to 20 GS1 characters", serial)
+	}
+	return gtin, serial, nil
+}
+
+// FormatSSCC returns the canonical trackingID of an 18 digit SSCC, (00)<SSCC>
+func FormatSSCC(sscc string) string {
+	return "(" + AISSCC + ")" + sscc
+}
+
+// ParseSSCC returns the 18 digit SSCC of a canonical SSCC trackingID and validates its check digit
+func ParseSSCC(trackingID string) (string, error) {
+	prefix := "(" + AISSCC + ")"
+	sscc := strings.TrimPrefix(trackingID, prefix)
+	if sscc == trackingID || len(sscc) != 18 || !digitsPattern.MatchString(sscc) {
+		return "", fmt.Errorf("trackingID %s is not an SSCC, expecting (00)<18 digits>", trackingID)
+	}
+	if !validCheckDigit(sscc) {
+		return "", fmt.Errorf("SSCC %s has an invalid check digit", sscc)
+	}
+	return sscc, nil
+}
+
+// GenerateSSCC builds the SSCC trackingID for an extension digit, company prefix and serial reference
+func GenerateSSCC(extensionDigit int, companyPrefix string, serialReference int64) (string, error) {
+	width := 16 - len(companyPrefix)
+	reference := strconv.FormatInt(serialReference, 10)
+	if serialReference < 0 || len(reference) > width {
+		return "", fmt.Errorf("Serial reference %d does not fit company prefix %s", serialReference, companyPrefix)
+	}
+	digits := strconv.Itoa(extensionDigit) + companyPrefix + strings.Repeat("0", width-len(reference)) + reference
+	return FormatSSCC(digits + strconv.Itoa(CheckDigit(digits))), nil
+}
@@ -0,0 +1,154 @@
+package common
+
+import (
+	"fmt"
+)
+
+// Health states of products and containers, items without a health are ok
+const (
+	HealthOK          = "ok"
+	HealthDamaged     = "damaged"
+	HealthCompromised = "compromised"
+	HealthQuarantined = "quarantined"
+	HealthStolen      = "stolen"
+	HealthDestroyed   = "destroyed"
+)
+
+// HealthStates lists every valid health
+var HealthStates = []string{HealthOK, HealthDamaged, HealthCompromised, HealthQuarantined, HealthStolen, HealthDestroyed}
+
+// Actions that the health of an item can block
+const (
+	ActionSell      = "sell"
+	ActionClaim     = "claim"
+	ActionPackage   = "package"
+	ActionUnpackage = "unpackage"
+	ActionAssemble  = "assemble"
+)
+
+// Reason codes set automatically by the chaincode
+const (
+	ReasonTemperatureExcursion = "temperature_excursion"
+	ReasonHumidityExcursion    = "humidity_excursion"
+	ReasonPhysicalDamage       = "physical_damage"
+	ReasonDisposal             = "disposal"
+)
+
+// The HealthRules model the allowed health transitions, the reason codes a change must carry and the actions
+// blocked in each health, set by a manufacturer for the products it manufactures. Destroyed is always terminal.
+type HealthRules struct {
+	Type         string              `json:"docType"`
+	Organization string              `json:"organization,omitempty"`
+	Transitions  map[string][]string `json:"transitions"`
+	Blocked      map[string][]string `json:"blocked"`
+	Reasons      []string            `json:"reasons"`
+}
+
+// DefaultHealthRules returns the rules used until a manufacturer sets its own
+func DefaultHealthRules() HealthRules {
+	return HealthRules{
+		Type: "healthRules",
+		Transitions: map[string][]string{
+			HealthOK:          {HealthDamaged, HealthCompromised, HealthQuarantined, HealthStolen, HealthDestroyed},
+			HealthDamaged:     {HealthOK, HealthQuarantined, HealthDestroyed},
+			HealthCompromised: {HealthQuarantined, HealthDestroyed},
+			HealthQuarantined: {HealthOK, HealthDamaged, HealthCompromised, HealthDestroyed},
+			HealthStolen:      {HealthOK, HealthQuarantined, HealthDestroyed},
+		},
+		Blocked: map[string][]string{
+			HealthDamaged:     {ActionSell, ActionAssemble},
+			HealthCompromised: {ActionSell, ActionAssemble},
+			HealthQuarantined: {ActionSell, ActionClaim, ActionPackage, ActionAssemble},
+			HealthStolen:      {ActionSell, ActionClaim, ActionPackage, ActionAssemble},
+		},
+		Reasons: []string{
+			"inspection", ReasonPhysicalDamage, ReasonTemperatureExcursion, ReasonHumidityExcursion, "contamination", "tampering",
+			"theft", "recovered", "quality_hold", "released", ReasonDisposal, "other",
+		},
+	}
+}
+
+// ValidHealth returns true if the health is one of HealthStates, an empty health is ok
+func ValidHealth(health string) bool {
+	return health == "" || contains(HealthStates, health)
+}
+
+// NormalizeHealth returns ok for items created without a health or with a free-form health from before the
+// health states were enumerated
+func NormalizeHealth(health string) string {
+	if !contains(HealthStates, health) {
+		return HealthOK
+	}
+	return health
+}
+
+// Validate checks that the rules only refer to valid health states and keep destroyed terminal
+func (r *HealthRules) Validate() error {
+	if len(r.Reasons) == 0 {
+		return fmt.Errorf("At least one reason code is required")
+	}
+	for from, targets := range r.Transitions {
+		if !ValidHealth(from) {
+			return fmt.Errorf("Unknown health %s", from)
+		}
+		if from == HealthDestroyed && len(targets) != 0 {
+			return fmt.Errorf("Health %s is terminal", HealthDestroyed)
+		}
+		for _, to := range targets {
+			if !ValidHealth(to) {
+				return fmt.Errorf("Unknown health %s", to)
+			}
+		}
+	}
+	for health := range r.Blocked {
+		if !ValidHealth(health) {
+			return fmt.Errorf("Unknown health %s", health)
+		}
+	}
+	return nil
+}
+
+// Transition checks that an item can change from one health to another for the supplied reason code
+func (r *HealthRules) Transition(from string, to string, reason string) error {
+	from = NormalizeHealth(from)
+	if !ValidHealth(to) {
+		return fmt.Errorf("Unknown health %s, expecting one of %v", to, HealthStates)
+	}
+	if reason == "" {
+		return fmt.Errorf("A reason code is required to change health")
+	}
+	if !contains(r.Reasons, reason) {
+		return fmt.Errorf("Unknown reason code %s, expecting one of %v", reason, r.Reasons)
+	}
+	if from == HealthDestroyed {
+		return fmt.Errorf("Health %s is terminal", HealthDestroyed)
+	}
+	if !r.CanChange(from, to) {
+		return fmt.Errorf("Health cannot change from %s to %s", from, NormalizeHealth(to))
+	}
+	return nil
+}
+
+// CanChange returns true if the rules allow a change from one health to another
+func (r *HealthRules) CanChange(from string, to string) bool {
+	from = NormalizeHealth(from)
+	return from != HealthDestroyed && contains(r.Transitions[from], NormalizeHealth(to))
+}
+
+// Allows checks that an item in the supplied health can undergo an action, destroyed items allow none
+func (r *HealthRules) Allows(health string, action string) error {
+	health = NormalizeHealth(health)
+	if health == HealthDestroyed || contains(r.Blocked[health], action) {
+		return fmt.Errorf("Cannot %s %s items", action, health)
+	}
+	return nil
+}
+
+func contains(list []string, item string) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
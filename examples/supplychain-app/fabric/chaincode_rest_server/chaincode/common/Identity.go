@@ -38,7 +38,9 @@ func GetInvokerIdentity(stub shim.ChaincodeStubInterface) (*Identity, error) {
 // CanInvoke returns true or false depending on whether the Identity can invoke the supplied transaction
 func (id *Identity) CanInvoke(function string) bool {
 	switch function {
-	case "createProduct":
+	case "createProduct", "assembleProduct", "setTelemetryRange", "setHealthRules",
+		"setReturnPolicy", "approveReturn", "rejectReturn", "setPublicFields",
+		"setDwellSLAs", "defineContainerType", "setPackingRules", "setIdentifierScheme":
 		return id.isManufacturer()
 	default:
 		return false
@@ -0,0 +1,125 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// hashPattern matches a hex encoded SHA-256 digest
+var hashPattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// The Inspection models the result of a quality inspection of a product or container
+type Inspection struct {
+	Type         string            `json:"docType"`
+	ID           string            `json:"inspectionID"`
+	TrackingID   string            `json:"trackingID"`
+	Inspector    string            `json:"inspector"`
+	Organization string            `json:"organization"`
+	Checklist    []InspectionCheck `json:"checklist"`
+	Passed       bool              `json:"passed"`
+	Attachments  []Attachment      `json:"attachments"`
+	Notes        string            `json:"notes,omitempty"`
+	Timestamp    int64             `json:"timestamp"`
+}
+
+// The InspectionCheck models a single checklist item of an inspection
+type InspectionCheck struct {
+	Item   string `json:"item"`
+	Passed bool   `json:"passed"`
+	Note   string `json:"note,omitempty"`
+}
+
+// The Attachment models a photo or document kept off-chain, identified by its SHA-256 hash
+type Attachment struct {
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+}
+
+// The InspectionRequest models a request body for recording an inspection
+type InspectionRequest struct {
+	Checklist   []InspectionCheck `json:"checklist"`
+	Passed      bool              `json:"passed"`
+	Attachments []Attachment      `json:"attachments"`
+	Notes       string            `json:"notes"`
+}
+
+// Validate checks the checklist and attachment hashes, an inspection with a failed check cannot pass
+func (request *InspectionRequest) Validate() error {
+	for _, check := range request.Checklist {
+		if check.Item == "" {
+			return errors.New("Checklist items require a name")
+		}
+		if !check.Passed && request.Passed {
+			return fmt.Errorf("Inspection cannot pass with failed check %s", check.Item)
+		}
+	}
+	for _, attachment := range request.Attachments {
+		if err := ValidateHash(attachment.Hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The Certification models a certificate such as GMP, organic or ISO 9001 held by a product or container
+type Certification struct {
+	Type              string `json:"docType"`
+	ID                string `json:"certificationID"`
+	TrackingID        string `json:"trackingID"`
+	Scheme            string `json:"scheme"`
+	CertificateNumber string `json:"certificateNumber"`
+	Issuer            string `json:"issuer"`
+	ValidFrom         string `json:"validFrom"`
+	ValidUntil        string `json:"validUntil"`
+	DocumentHash      string `json:"documentHash,omitempty"`
+	AddedBy           string `json:"addedBy"`
+	Timestamp         int64  `json:"timestamp"`
+}
+
+// The CertificationRequest models a request body for attaching a certification
+type CertificationRequest struct {
+	Scheme            string `json:"scheme"`
+	CertificateNumber string `json:"certificateNumber"`
+	Issuer            string `json:"issuer"`
+	ValidFrom         string `json:"validFrom"`
+	ValidUntil        string `json:"validUntil"`
+	DocumentHash      string `json:"documentHash"`
+}
+
+// Validate checks the scheme, the YYYY-MM-DD validity window and the document hash
+func (request *CertificationRequest) Validate() error {
+	if request.Scheme == "" || request.CertificateNumber == "" || request.Issuer == "" {
+		return errors.New("scheme, certificateNumber and issuer are required")
+	}
+	from, err := time.Parse(ExpiryLayout, request.ValidFrom)
+	if err != nil {
+		return fmt.Errorf("validFrom must be a YYYY-MM-DD date: %s", request.ValidFrom)
+	}
+	until, err := time.Parse(ExpiryLayout, request.ValidUntil)
+	if err != nil {
+		return fmt.Errorf("validUntil must be a YYYY-MM-DD date: %s", request.ValidUntil)
+	}
+	if until.Before(from) {
+		return errors.New("validUntil is before validFrom")
+	}
+	if request.DocumentHash != "" {
+		return ValidateHash(request.DocumentHash)
+	}
+	return nil
+}
+
+// Active returns true if the day of the supplied time is inside the validity window of the certification
+func (certification *Certification) Active(now time.Time) bool {
+	day := now.UTC().Format(ExpiryLayout)
+	return certification.ValidFrom <= day && day <= certification.ValidUntil
+}
+
+// ValidateHash checks that a hash is a lowercase hex encoded SHA-256 digest
+func ValidateHash(hash string) error {
+	if !hashPattern.MatchString(hash) {
+		return fmt.Errorf("Hash must be a hex encoded SHA-256 digest: %s", hash)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package common
+
+import (
+	"sort"
+)
+
+// The Inventory models the products and containers held by a custodian, grouped by the location they were last
+// scanned at and by the unpackaged items they are packed in
+type Inventory struct {
+	Custodian string              `json:"custodian"`
+	Items     int                 `json:"items"`
+	Locations []InventoryLocation `json:"locations"`
+	Roots     []InventoryRoot     `json:"roots"`
+	Timestamp int64               `json:"timestamp"`
+	contents  map[string][]string
+}
+
+// The InventoryLocation models the items held at a location
+type InventoryLocation struct {
+	Location string   `json:"location"`
+	Items    []string `json:"items"`
+}
+
+// The InventoryRoot models an unpackaged item held and everything packaged into it
+type InventoryRoot struct {
+	TrackingID string   `json:"trackingID"`
+	Location   string   `json:"location"`
+	Contents   []string `json:"contents"`
+}
+
+// The InventoryCountRequest models a request body reconciling a cycle count, optionally of one location only
+type InventoryCountRequest struct {
+	Scanned  []string `json:"scanned"`
+	Location string   `json:"location"`
+	Record   bool     `json:"record"`
+}
+
+// The InventoryReconciliation models the comparison of a cycle count with the items held according to the ledger.
+// Scanning a container counts everything packaged into it.
+type InventoryReconciliation struct {
+	Type       string   `json:"docType"`
+	ID         string   `json:"reconciliationID"`
+	Custodian  string   `json:"custodian"`
+	Location   string   `json:"location,omitempty"`
+	Expected   int      `json:"expected"`
+	Scanned    []string `json:"scanned"`
+	Missing    []string `json:"missing"`
+	Unexpected []string `json:"unexpected"`
+	Recorded   bool     `json:"recorded"`
+	Timestamp  int64    `json:"timestamp"`
+}
+
+// InStock returns true if the product is still physically held, it was not sold, consumed or destroyed
+func (product *Product) InStock() bool {
+	return !product.Sold && product.ConsumedBy == "" && product.Health != HealthDestroyed
+}
+
+// InStock returns true if the container is still physically held, it was not destroyed
+func (container *Container) InStock() bool {
+	return container.Health != HealthDestroyed
+}
+
+// NewInventory returns the inventory of the custodian among the supplied containers and products in stock
+func NewInventory(custodian string, containers []Container, products []Product, timestamp int64) Inventory {
+	inventory := Inventory{
+		Custodian: custodian,
+		Locations: []InventoryLocation{},
+		Roots:     []InventoryRoot{},
+		Timestamp: timestamp,
+		contents:  map[string][]string{},
+	}
+	locations := map[string]string{}
+	packagedIn := map[string]string{}
+	for _, container := range containers {
+		if container.Custodian == custodian && container.InStock() {
+			locations[container.ID] = container.Location
+			packagedIn[container.ID] = container.ContainerID
+			inventory.contents[container.ID] = container.Contents
+		}
+	}
+	for _, product := range products {
+		if product.Custodian == custodian && product.InStock() {
+			locations[product.ID] = product.Location
+			packagedIn[product.ID] = product.ContainerID
+		}
+	}
+
+	//only held contents count, anything else packaged into a held container is not in stock
+	for id, contents := range inventory.contents {
+		held := []string{}
+		for _, contentID := range contents {
+			if _, ok := locations[contentID]; ok {
+				held = append(held, contentID)
+			}
+		}
+		inventory.contents[id] = held
+	}
+	for id, location := range locations {
+		inventory.add(id, location)
+		if _, ok := locations[packagedIn[id]]; !ok {
+			inventory.Roots = append(inventory.Roots, InventoryRoot{
+				TrackingID: id,
+				Location:   location,
+				Contents:   inventory.Nested(id),
+			})
+		}
+	}
+	inventory.sort()
+	return inventory
+}
+
+// Nested returns everything held that is packaged into an item, directly or in containers packaged into it
+func (inventory *Inventory) Nested(trackingID string) []string {
+	nested := []string{}
+	for _, contentID := range inventory.contents[trackingID] {
+		nested = append(nested, contentID)
+		nested = append(nested, inventory.Nested(contentID)...)
+	}
+	sort.Strings(nested)
+	return nested
+}
+
+// At returns the items held at a location, or every item held if the location is empty
+func (inventory *Inventory) At(location string) []string {
+	items := []string{}
+	for _, held := range inventory.Locations {
+		if location == "" || held.Location == location {
+			items = append(items, held.Items...)
+		}
+	}
+	return items
+}
+
+func (inventory *Inventory) add(trackingID string, location string) {
+	inventory.Items++
+	for i := range inventory.Locations {
+		if inventory.Locations[i].Location == location {
+			inventory.Locations[i].Items = append(inventory.Locations[i].Items, trackingID)
+			return
+		}
+	}
+	inventory.Locations = append(inventory.Locations, InventoryLocation{Location: location, Items: []string{trackingID}})
+}
+
+func (inventory *Inventory) sort() {
+	sort.Slice(inventory.Locations, func(i, j int) bool {
+		return inventory.Locations[i].Location < inventory.Locations[j].Location
+	})
+	for _, location := range inventory.Locations {
+		sort.Strings(location.Items)
+	}
+	sort.Slice(inventory.Roots, func(i, j int) bool {
+		return inventory.Roots[i].TrackingID < inventory.Roots[j].TrackingID
+	})
+}
+
+// Reconcile compares the items of the inventory held at the location of the count with the scanned ones
+func (reconciliation *InventoryReconciliation) Reconcile(inventory Inventory) {
+	expected := inventory.At(reconciliation.Location)
+	present := []string{}
+	for _, id := range reconciliation.Scanned {
+		present = append(present, id)
+		present = append(present, inventory.Nested(id)...)
+	}
+	reconciliation.Expected = len(expected)
+	reconciliation.Missing = difference(expected, present)
+	reconciliation.Unexpected = difference(reconciliation.Scanned, expected)
+	sort.Strings(reconciliation.Missing)
+	sort.Strings(reconciliation.Unexpected)
+}
@@ -0,0 +1,25 @@
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+// ExpiryLayout is the date format of product expiry dates
+const ExpiryLayout = "2006-01-02"
+
+// ValidateLot checks that a lot/batch number fits the GS1 AI (10) format
+func ValidateLot(lot string) error {
+	if !serialPattern.MatchString(lot) {
+		return fmt.Errorf("Lot %s must be 1 to 20 GS1 characters", lot)
+	}
+	return nil
+}
+
+// ValidateExpiry checks that an expiry date is a YYYY-MM-DD date
+func ValidateExpiry(expiry string) error {
+	if _, err := time.Parse(ExpiryLayout, expiry); err != nil {
+		return fmt.Errorf("Expiry date %s must be YYYY-MM-DD", expiry)
+	}
+	return nil
+}
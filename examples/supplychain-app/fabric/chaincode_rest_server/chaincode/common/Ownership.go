@@ -0,0 +1,75 @@
+package common
+
+import (
+	"fmt"
+)
+
+// Fields product and container listings can be filtered by
+const (
+	FilterOwner     = "owner"
+	FilterCustodian = "custodian"
+)
+
+// The OwnershipTransfer models the transfer of title of an item and everything packaged into it, independent of who
+// physically holds it
+type OwnershipTransfer struct {
+	Type       string   `json:"docType"`
+	ID         string   `json:"transferID"`
+	TrackingID string   `json:"trackingID"`
+	Items      []string `json:"items"`
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	Reference  string   `json:"reference,omitempty"`
+	Timestamp  int64    `json:"timestamp"`
+}
+
+// The Redirect models a change of destination of a shipment by the owner of its containers
+type Redirect struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	By        string `json:"by"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// ValidateFilter checks the field a listing is filtered by
+func ValidateFilter(field string) error {
+	if field != FilterOwner && field != FilterCustodian {
+		return fmt.Errorf("Unknown filter %s, expecting %s or %s", field, FilterOwner, FilterCustodian)
+	}
+	return nil
+}
+
+// CurrentOwner returns the owner of the product, products created before ownership was tracked are owned by their
+// custodian
+func (product *Product) CurrentOwner() string {
+	if product.Owner == "" {
+		return product.Custodian
+	}
+	return product.Owner
+}
+
+// HeldBy returns true if the supplied subject is the owner or custodian of the product, as given by the filter field
+func (product *Product) HeldBy(field string, subject string) bool {
+	if field == FilterOwner {
+		return product.CurrentOwner() == subject
+	}
+	return product.Custodian == subject
+}
+
+// CurrentOwner returns the owner of the container, containers created before ownership was tracked are owned by
+// their custodian
+func (container *Container) CurrentOwner() string {
+	if container.Owner == "" {
+		return container.Custodian
+	}
+	return container.Owner
+}
+
+// HeldBy returns true if the supplied subject is the owner or custodian of the container, as given by the filter
+// field
+func (container *Container) HeldBy(field string, subject string) bool {
+	if field == FilterOwner {
+		return container.CurrentOwner() == subject
+	}
+	return container.Custodian == subject
+}
@@ -0,0 +1,251 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metadata (misc) keys of products and containers the packing constraints are derived from. Weight and volume of
+// bulk products are per unit of their quantity.
+const (
+	MetadataWeight           = "weight"
+	MetadataVolume           = "volume"
+	MetadataHazmatClass      = "hazmatClass"
+	MetadataTemperatureClass = "temperatureClass"
+)
+
+// The ContainerType models the capacity of a type of container defined by an organization, a zero maximum is
+// unlimited. Containers of a type with a temperature class only take items of that class.
+type ContainerType struct {
+	Type             string  `json:"docType"`
+	Organization     string  `json:"organization,omitempty"`
+	Name             string  `json:"name"`
+	MaxItems         int     `json:"maxItems,omitempty"`
+	MaxWeight        float64 `json:"maxWeight,omitempty"`
+	MaxVolume        float64 `json:"maxVolume,omitempty"`
+	TemperatureClass string  `json:"temperatureClass,omitempty"`
+}
+
+// The PackingRules model the compatibility of items packaged together by an organization: pairs of hazmat classes
+// that cannot be mixed and whether temperature classes must match
+type PackingRules struct {
+	Type               string      `json:"docType"`
+	Organization       string      `json:"organization,omitempty"`
+	IncompatibleHazmat [][2]string `json:"incompatibleHazmat"`
+	MatchTemperature   bool        `json:"matchTemperature"`
+}
+
+// The PackedItem models the properties of an item the packing constraints are checked against. Containers weigh
+// their own weight plus their contents and take their own volume, or the volume of their contents if they have none.
+type PackedItem struct {
+	ID                 string
+	Weight             float64
+	Volume             float64
+	HazmatClasses      map[string]string
+	TemperatureClasses map[string]string
+	ownVolume          bool
+}
+
+// DefaultPackingRules returns the packing rules in effect until an organization sets its own: explosives (class 1)
+// and oxidizers (class 5.1) do not mix with flammable liquids (class 3) or each other, temperature classes match.
+// The default incompatible hazmat classes apply under every organization's rules.
+func DefaultPackingRules() PackingRules {
+	return PackingRules{
+		IncompatibleHazmat: [][2]string{{"1", "3"}, {"1", "5.1"}, {"3", "5.1"}},
+		MatchTemperature:   true,
+	}
+}
+
+// Validate checks that the container type is named and its maximums are not negative
+func (containerType *ContainerType) Validate() error {
+	if strings.TrimSpace(containerType.Name) == "" {
+		return errors.New("A container type name is required")
+	}
+	if containerType.MaxItems < 0 || containerType.MaxWeight < 0 || containerType.MaxVolume < 0 {
+		return errors.New("Maximums must not be negative")
+	}
+	return nil
+}
+
+// Validate checks that every incompatible pair names two hazmat classes
+func (rules *PackingRules) Validate() error {
+	for _, pair := range rules.IncompatibleHazmat {
+		if strings.TrimSpace(pair[0]) == "" || strings.TrimSpace(pair[1]) == "" {
+			return errors.New("Incompatible hazmat pairs need two classes")
+		}
+	}
+	return nil
+}
+
+// AddDefaultHazmat adds the incompatible hazmat pairs of the default rules that the rules are missing, the rules of an
+// organization can add pairs but not remove the default ones
+func (rules *PackingRules) AddDefaultHazmat() {
+	for _, pair := range DefaultPackingRules().IncompatibleHazmat {
+		if !rules.incompatible(pair[0], pair[1]) {
+			rules.IncompatibleHazmat = append(rules.IncompatibleHazmat, pair)
+		}
+	}
+}
+
+// NewPackedItem returns the packing properties of an item and everything packaged into it, the first of the
+// supplied containers and products
+func NewPackedItem(trackingID string, containers []Container, products []Product) PackedItem {
+	item := PackedItem{ID: trackingID, HazmatClasses: map[string]string{}, TemperatureClasses: map[string]string{}}
+	var contentsVolume float64
+	for _, product := range products {
+		multiplier := 1.0
+		if product.Bulk() {
+			multiplier = product.Quantity
+		}
+		weight, _ := metadataNumber(product.Metadata, MetadataWeight)
+		volume, _ := metadataNumber(product.Metadata, MetadataVolume)
+		item.Weight += weight * multiplier
+		contentsVolume += volume * multiplier
+		item.addClasses(product.ID, product.Metadata)
+	}
+	ownVolume := 0.0
+	for _, container := range containers {
+		weight, _ := metadataNumber(container.Metadata, MetadataWeight)
+		item.Weight += weight
+		if container.ID == trackingID {
+			ownVolume, _ = metadataNumber(container.Metadata, MetadataVolume)
+		}
+		item.addClasses(container.ID, container.Metadata)
+	}
+	item.Volume = contentsVolume
+	if ownVolume > 0 {
+		item.Volume = ownVolume
+		item.ownVolume = true
+	}
+	item.Weight = RoundQuantity(item.Weight)
+	item.Volume = RoundQuantity(item.Volume)
+	return item
+}
+
+// Including returns the packing properties of the item with the added item packaged into it, under the ID of the
+// added item. Items with a volume of their own keep it.
+func (item *PackedItem) Including(added PackedItem) PackedItem {
+	including := PackedItem{
+		ID:                 added.ID,
+		Weight:             RoundQuantity(item.Weight + added.Weight),
+		Volume:             item.Volume,
+		HazmatClasses:      map[string]string{},
+		TemperatureClasses: map[string]string{},
+		ownVolume:          item.ownVolume,
+	}
+	if !item.ownVolume {
+		including.Volume = RoundQuantity(item.Volume + added.Volume)
+	}
+	for _, classes := range []PackedItem{*item, added} {
+		for id, class := range classes.HazmatClasses {
+			including.HazmatClasses[id] = class
+		}
+		for id, class := range classes.TemperatureClasses {
+			including.TemperatureClasses[id] = class
+		}
+	}
+	return including
+}
+
+func (item *PackedItem) addClasses(id string, metadata map[string]interface{}) {
+	if class := metadataString(metadata, MetadataHazmatClass); class != "" {
+		item.HazmatClasses[id] = class
+	}
+	if class := metadataString(metadata, MetadataTemperatureClass); class != "" {
+		item.TemperatureClasses[id] = class
+	}
+}
+
+// CheckPacking returns an error explaining the first constraint packaging the added item into a container of the
+// supplied type, already holding the packed contents, would violate
+func CheckPacking(containerID string, containerType *ContainerType, rules PackingRules, contents []PackedItem, added PackedItem) error {
+	if containerType != nil {
+		if containerType.MaxItems > 0 && len(contents)+1 > containerType.MaxItems {
+			return fmt.Errorf("Container %s of type %s holds at most %d items", containerID, containerType.Name, containerType.MaxItems)
+		}
+		weight, volume := added.Weight, added.Volume
+		for _, content := range contents {
+			weight += content.Weight
+			volume += content.Volume
+		}
+		if containerType.MaxWeight > 0 && weight > containerType.MaxWeight && !EqualQuantity(weight, containerType.MaxWeight) {
+			return fmt.Errorf("Container %s of type %s holds at most weight %v, packaging %s would make %v", containerID, containerType.Name, containerType.MaxWeight, added.ID, RoundQuantity(weight))
+		}
+		if containerType.MaxVolume > 0 && volume > containerType.MaxVolume && !EqualQuantity(volume, containerType.MaxVolume) {
+			return fmt.Errorf("Container %s of type %s holds at most volume %v, packaging %s would make %v", containerID, containerType.Name, containerType.MaxVolume, added.ID, RoundQuantity(volume))
+		}
+		if containerType.TemperatureClass != "" {
+			for _, id := range sortedKeys(added.TemperatureClasses) {
+				if class := added.TemperatureClasses[id]; class != containerType.TemperatureClass {
+					return fmt.Errorf("Temperature class %s of %s does not match temperature class %s of container %s", class, id, containerType.TemperatureClass, containerID)
+				}
+			}
+		}
+	}
+	for _, content := range contents {
+		for _, addedID := range sortedKeys(added.HazmatClasses) {
+			for _, contentID := range sortedKeys(content.HazmatClasses) {
+				addedClass, contentClass := added.HazmatClasses[addedID], content.HazmatClasses[contentID]
+				if rules.incompatible(addedClass, contentClass) {
+					return fmt.Errorf("Hazmat class %s of %s cannot be mixed with hazmat class %s of %s", addedClass, addedID, contentClass, contentID)
+				}
+			}
+		}
+		if !rules.MatchTemperature {
+			continue
+		}
+		for _, addedID := range sortedKeys(added.TemperatureClasses) {
+			for _, contentID := range sortedKeys(content.TemperatureClasses) {
+				addedClass, contentClass := added.TemperatureClasses[addedID], content.TemperatureClasses[contentID]
+				if addedClass != contentClass {
+					return fmt.Errorf("Temperature class %s of %s does not match temperature class %s of %s", addedClass, addedID, contentClass, contentID)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (rules *PackingRules) incompatible(a string, b string) bool {
+	for _, pair := range rules.IncompatibleHazmat {
+		if (pair[0] == a && pair[1] == b) || (pair[0] == b && pair[1] == a) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(classes map[string]string) []string {
+	keys := []string{}
+	for key := range classes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func metadataNumber(metadata map[string]interface{}, key string) (float64, bool) {
+	switch value := metadata[key].(type) {
+	case float64:
+		return value, true
+	case string:
+		number, err := strconv.ParseFloat(value, 64)
+		return number, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func metadataString(metadata map[string]interface{}, key string) string {
+	switch value := metadata[key].(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
@@ -3,6 +3,7 @@ package common
 import (
 	"encoding/json"
 	"errors"
+	"time"
 )
 
 // The Product models a product in a supply chain
@@ -11,27 +12,66 @@ type Product struct {
 	Type         string                 `json:"docType"`
 	Name         string                 `json:"productName"`
 	Health       string                 `json:"health"`
+	HealthReason string                 `json:"healthReason,omitempty"`
 	Sold         bool                   `json:"sold"`
 	Recalled     bool                   `json:"recalled"`
 	Metadata     map[string]interface{} `json:"misc"`
 	Custodian    string                 `json:"custodian"`
+	Owner        string                 `json:"owner,omitempty"`
+	CustodySince int64                  `json:"custodySince,omitempty"`
 	Location     string                 `json:"lastScannedAt"`
 	Timestamp    int64                  `json:"timestamp"`
 	ContainerID  string                 `json:"containerID"`
 	Participants []string               `json:"participants"`
+	Lot          string                 `json:"lot,omitempty"`
+	Expiry       string                 `json:"expiry,omitempty"`
+	Inputs       []string               `json:"inputs,omitempty"`
+	ConsumedBy   string                 `json:"consumedBy,omitempty"`
+	Quantity     float64                `json:"quantity,omitempty"`
+	Unit         string                 `json:"unit,omitempty"`
+	Created      float64                `json:"createdQuantity,omitempty"`
+	SplitFrom    string                 `json:"splitFrom,omitempty"`
+	Splits       []string               `json:"splits,omitempty"`
+	SoldAt       int64                  `json:"soldAt,omitempty"`
+	RMA          string                 `json:"rma,omitempty"`
+	Returns      []string               `json:"returns,omitempty"`
+	Manufacturer string                 `json:"manufacturer,omitempty"`
+	PublicFields []string               `json:"publicFields,omitempty"`
+	Prescription bool                   `json:"prescription,omitempty"`
 }
 
 // AccessibleBy returns true or false depending on if the supplied organization is a member of the application
 func (product *Product) AccessibleBy(id *Identity) bool {
-	idType := id.Cert.Subject.String()
+	return product.HasParticipant(id.Cert.Subject.String())
+}
+
+// HasParticipant returns true if the supplied subject is a participant of the product
+func (product *Product) HasParticipant(subject string) bool {
 	for _, v := range product.Participants {
-		if v == idType {
+		if v == subject {
 			return true
 		}
 	}
 	return false
 }
 
+// Expired returns true if the product has an expiry date before the day of the supplied time
+func (product *Product) Expired(now time.Time) bool {
+	if product.Expiry == "" {
+		return false
+	}
+	expiry, err := time.Parse(ExpiryLayout, product.Expiry)
+	if err != nil {
+		return false
+	}
+	return !now.UTC().Before(expiry.AddDate(0, 0, 1))
+}
+
+// Bulk returns true if the product carries a quantity in a unit of measure rather than being a single unit
+func (product *Product) Bulk() bool {
+	return product.Unit != ""
+}
+
 //UnmarshalJSON will override Unmarshal
 func (product *Product) UnmarshalJSON(data []byte) error {
 	var input map[string]interface{}
@@ -8,4 +8,25 @@ type ProductRequest struct {
 	Metadata     map[string]interface{} `json:"misc"`
 	Location     string                 `json:"lastScannedAt"`
 	Participants []string               `json:"counterparties"`
+	Lot          string                 `json:"lot"`
+	Expiry       string                 `json:"expiry"`
+	Quantity     float64                `json:"quantity"`
+	Unit         string                 `json:"unit"`
+	Prescription bool                   `json:"prescription"`
+}
+
+// The AssemblyRequest models request body for a product assembled from input products
+type AssemblyRequest struct {
+	ProductRequest
+	Inputs []string `json:"inputs"`
+}
+
+// The TraceNode models a product found by tracing the inputs or outputs of an assembly
+type TraceNode struct {
+	TrackingID string   `json:"trackingID"`
+	Name       string   `json:"productName"`
+	Lot        string   `json:"lot,omitempty"`
+	Via        string   `json:"via"`
+	Depth      int      `json:"depth"`
+	Returns    []string `json:"returns,omitempty"`
 }
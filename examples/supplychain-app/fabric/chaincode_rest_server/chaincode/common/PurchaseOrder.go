@@ -0,0 +1,227 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Statuses of a purchase order
+const (
+	PurchaseOrderIssued    = "issued"
+	PurchaseOrderAccepted  = "accepted"
+	PurchaseOrderFulfilled = "fulfilled"
+)
+
+// Exceptions raised matching a delivery against a purchase order
+const (
+	ExceptionPartialDelivery  = "partial_delivery"
+	ExceptionOverDelivery     = "over_delivery"
+	ExceptionUnexpectedItem   = "unexpected_item"
+	ExceptionShipmentMismatch = "shipment_mismatch"
+)
+
+// The PurchaseOrder models the order of a buyer to a seller and its fulfillment by the deliveries of the shipments
+// and containers linked to it
+type PurchaseOrder struct {
+	Type       string                   `json:"docType"`
+	ID         string                   `json:"purchaseOrderID"`
+	Buyer      string                   `json:"buyer"`
+	Seller     string                   `json:"seller"`
+	Lines      []LineItem               `json:"lines"`
+	Currency   string                   `json:"currency,omitempty"`
+	Status     string                   `json:"status"`
+	Shipments  []string                 `json:"shipments"`
+	Containers []string                 `json:"containers"`
+	Exceptions []PurchaseOrderException `json:"exceptions"`
+	Settlement *EscrowConfig            `json:"settlement,omitempty"`
+	Escrow     *EscrowAccount           `json:"escrow,omitempty"`
+	Timestamp  int64                    `json:"timestamp"`
+}
+
+// The LineItem models an ordered quantity of a product, identified by GTIN or product name, at an optional unit
+// price and the quantity received so far
+type LineItem struct {
+	ProductName string  `json:"productName,omitempty"`
+	GTIN        string  `json:"gtin,omitempty"`
+	Quantity    float64 `json:"quantity"`
+	Price       float64 `json:"price,omitempty"`
+	Fulfilled   float64 `json:"fulfilled"`
+}
+
+// The PurchaseOrderException models a mismatch between the ordered, shipped and received quantities of a product
+type PurchaseOrderException struct {
+	Type       string  `json:"type"`
+	DeliveryID string  `json:"deliveryID"`
+	Product    string  `json:"product"`
+	Ordered    float64 `json:"ordered"`
+	Shipped    float64 `json:"shipped"`
+	Received   float64 `json:"received"`
+	Timestamp  int64   `json:"timestamp"`
+}
+
+// The PurchaseOrderRequest models a request body for issuing a purchase order
+type PurchaseOrderRequest struct {
+	ID       string        `json:"purchaseOrderID"`
+	Seller   string        `json:"seller"`
+	Lines    []LineItem    `json:"lines"`
+	Currency string        `json:"currency"`
+	Escrow   *EscrowConfig `json:"escrow,omitempty"`
+}
+
+// Validate checks the seller, line items and escrow chaincode of the request, GTINs are normalized to GTIN-14
+func (request *PurchaseOrderRequest) Validate(buyer string) error {
+	if strings.TrimSpace(request.ID) == "" {
+		return errors.New("A purchase order ID is required")
+	}
+	if strings.TrimSpace(request.Seller) == "" || request.Seller == buyer {
+		return errors.New("A seller other than the buyer is required")
+	}
+	if request.Escrow != nil {
+		if err := request.Escrow.Validate(); err != nil {
+			return err
+		}
+	}
+	if len(request.Lines) == 0 {
+		return errors.New("At least one line item is required")
+	}
+	keys := map[string]bool{}
+	for i := range request.Lines {
+		line := &request.Lines[i]
+		if line.GTIN != "" {
+			if err := ValidateGTIN(line.GTIN); err != nil {
+				return err
+			}
+			line.GTIN = strings.Repeat("0", 14-len(line.GTIN)) + line.GTIN
+		} else if strings.TrimSpace(line.ProductName) == "" {
+			return errors.New("Each line item requires a GTIN or product name")
+		}
+		if line.Quantity <= 0 {
+			return fmt.Errorf("Quantity of %s must be positive", line.Key())
+		}
+		if line.Price < 0 {
+			return fmt.Errorf("Price of %s must not be negative", line.Key())
+		}
+		if keys[line.Key()] {
+			return fmt.Errorf("Duplicate line item %s", line.Key())
+		}
+		keys[line.Key()] = true
+		line.Fulfilled = 0
+	}
+	return nil
+}
+
+// Key returns the GTIN of the line item or its product name if it has none
+func (line *LineItem) Key() string {
+	if line.GTIN != "" {
+		return line.GTIN
+	}
+	return line.ProductName
+}
+
+// LineKey returns the key of the line item a product is matched to, the GTIN of SGTIN trackingIDs or else the
+// product name
+func LineKey(product Product) string {
+	if gtin, _, err := ParseSGTIN(product.ID); err == nil {
+		return gtin
+	}
+	return product.Name
+}
+
+// LineQuantity returns the quantity a product counts for on a line item, the quantity of bulk products or else one
+func LineQuantity(product Product) float64 {
+	if product.Bulk() {
+		return product.Quantity
+	}
+	return 1
+}
+
+// LinkShipment adds a shipment to the shipments linked to the purchase order
+func (po *PurchaseOrder) LinkShipment(shipmentID string) {
+	if !contains(po.Shipments, shipmentID) {
+		po.Shipments = append(po.Shipments, shipmentID)
+	}
+}
+
+// LinkContainer adds a container to the containers linked to the purchase order
+func (po *PurchaseOrder) LinkContainer(containerID string) {
+	if !contains(po.Containers, containerID) {
+		po.Containers = append(po.Containers, containerID)
+	}
+}
+
+// Match matches the quantities of a delivery shipped and received per line key against the remaining quantities of
+// the purchase order, records the received quantities as fulfilled and returns the exceptions raised. Only the lines
+// the delivery shipped or received are reported as partial deliveries.
+func (po *PurchaseOrder) Match(deliveryID string, shipped map[string]float64, received map[string]float64, timestamp int64) []PurchaseOrderException {
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, quantities := range []map[string]float64{shipped, received} {
+		for key := range quantities {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	exceptions := []PurchaseOrderException{}
+	raise := func(exceptionType string, key string, ordered float64) {
+		exceptions = append(exceptions, PurchaseOrderException{
+			Type:       exceptionType,
+			DeliveryID: deliveryID,
+			Product:    key,
+			Ordered:    ordered,
+			Shipped:    shipped[key],
+			Received:   received[key],
+			Timestamp:  timestamp,
+		})
+	}
+	for _, key := range keys {
+		line := po.line(key)
+		if line == nil {
+			raise(ExceptionUnexpectedItem, key, 0)
+			continue
+		}
+		if !EqualQuantity(shipped[key], received[key]) {
+			raise(ExceptionShipmentMismatch, key, line.Quantity)
+		}
+		line.Fulfilled = RoundQuantity(line.Fulfilled + received[key])
+		if line.Fulfilled > line.Quantity && !EqualQuantity(line.Fulfilled, line.Quantity) {
+			raise(ExceptionOverDelivery, key, line.Quantity)
+		}
+	}
+	//lines of the delivery left short after it
+	for _, line := range po.Lines {
+		if seen[line.Key()] && line.Fulfilled < line.Quantity && !EqualQuantity(line.Fulfilled, line.Quantity) {
+			raise(ExceptionPartialDelivery, line.Key(), line.Quantity)
+		}
+	}
+
+	po.Exceptions = append(po.Exceptions, exceptions...)
+	if po.fulfilled() {
+		po.Status = PurchaseOrderFulfilled
+	}
+	po.Timestamp = timestamp
+	return exceptions
+}
+
+func (po *PurchaseOrder) line(key string) *LineItem {
+	for i := range po.Lines {
+		if po.Lines[i].Key() == key {
+			return &po.Lines[i]
+		}
+	}
+	return nil
+}
+
+func (po *PurchaseOrder) fulfilled() bool {
+	for _, line := range po.Lines {
+		if line.Fulfilled < line.Quantity && !EqualQuantity(line.Fulfilled, line.Quantity) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// QuantityPrecision is the smallest quantity tracked, quantities are rounded to six decimals
+const QuantityPrecision = 1e-6
+
+// Units of measure of bulk goods
+var Units = []string{"kg", "g", "mg", "t", "l", "ml", "m3", "pcs"}
+
+// The MassBalance models the quantities of every record split from one created bulk product
+type MassBalance struct {
+	TrackingID string  `json:"trackingID"`
+	Unit       string  `json:"unit"`
+	Created    float64 `json:"created"`
+	Available  float64 `json:"available"`
+	Consumed   float64 `json:"consumed"`
+	Sold       float64 `json:"sold"`
+	Destroyed  float64 `json:"destroyed"`
+	Records    int     `json:"records"`
+	Balanced   bool    `json:"balanced"`
+}
+
+// ValidateQuantity checks that a quantity is positive, has at most six decimals and comes with a known unit
+func ValidateQuantity(quantity float64, unit string) error {
+	if !contains(Units, unit) {
+		return fmt.Errorf("Unknown unit %s, expecting one of %v", unit, Units)
+	}
+	if quantity <= 0 || math.IsInf(quantity, 0) || math.IsNaN(quantity) {
+		return fmt.Errorf("Quantity must be positive: %v", quantity)
+	}
+	if !EqualQuantity(quantity, RoundQuantity(quantity)) {
+		return fmt.Errorf("Quantity must have at most six decimals: %v", quantity)
+	}
+	return nil
+}
+
+// ParseQuantity parses a quantity transaction argument
+func ParseQuantity(value string) (float64, error) {
+	quantity, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Quantity must be a number: %s", value)
+	}
+	return RoundQuantity(quantity), nil
+}
+
+// RoundQuantity rounds a quantity to QuantityPrecision
+func RoundQuantity(quantity float64) float64 {
+	return math.Round(quantity/QuantityPrecision) * QuantityPrecision
+}
+
+// EqualQuantity returns true if two quantities are equal up to QuantityPrecision
+func EqualQuantity(a float64, b float64) bool {
+	return math.Abs(a-b) < QuantityPrecision/2
+}
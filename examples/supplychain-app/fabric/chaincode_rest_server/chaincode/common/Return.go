@@ -0,0 +1,67 @@
+package common
+
+import (
+	"errors"
+	"time"
+)
+
+// Return authorization states
+const (
+	ReturnRequested = "requested"
+	ReturnApproved  = "approved"
+	ReturnRejected  = "rejected"
+	ReturnReceived  = "received"
+)
+
+// Outcomes of a received return
+const (
+	OutcomeRestock   = "restock"
+	OutcomeRefurbish = "refurbish"
+	OutcomeDestroy   = "destroy"
+)
+
+// ReturnOutcomes lists every valid outcome of a received return
+var ReturnOutcomes = []string{OutcomeRestock, OutcomeRefurbish, OutcomeDestroy}
+
+// DefaultReturnWindow is the number of days after a sale a product can be returned until a manufacturer sets its own
+const DefaultReturnWindow = 30
+
+// The ReturnPolicy models the number of days after a sale a sold product of a manufacturer organization can be
+// returned
+type ReturnPolicy struct {
+	Type         string `json:"docType"`
+	Organization string `json:"organization,omitempty"`
+	WindowDays   int    `json:"windowDays"`
+}
+
+// The RMA models the return merchandise authorization of a product travelling back to its manufacturer
+type RMA struct {
+	Type       string `json:"docType"`
+	ID         string `json:"rmaID"`
+	TrackingID string `json:"trackingID"`
+	Requester  string `json:"requester"`
+	Reason     string `json:"reason"`
+	Status     string `json:"status"`
+	Receiver   string `json:"receiver,omitempty"`
+	Note       string `json:"note,omitempty"`
+	Outcome    string `json:"outcome,omitempty"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+// Validate checks that the return window is not negative
+func (policy *ReturnPolicy) Validate() error {
+	if policy.WindowDays < 0 {
+		return errors.New("windowDays cannot be negative")
+	}
+	return nil
+}
+
+// Allows returns true if a product sold at the supplied unix time can still be returned at the supplied time
+func (policy *ReturnPolicy) Allows(soldAt int64, now time.Time) bool {
+	return !now.UTC().After(time.Unix(soldAt, 0).UTC().AddDate(0, 0, policy.WindowDays))
+}
+
+// ValidOutcome returns true if the outcome is one of ReturnOutcomes
+func ValidOutcome(outcome string) bool {
+	return contains(ReturnOutcomes, outcome)
+}
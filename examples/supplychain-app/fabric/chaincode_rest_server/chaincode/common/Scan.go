@@ -0,0 +1,153 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Anomalies flagged on a recorded scan
+const (
+	AnomalyImpossibleTravel = "impossible_travel"
+	AnomalyAfterSale        = "scanned_after_sale"
+	AnomalyAfterDestruction = "scanned_after_destruction"
+	AnomalyNonParticipant   = "non_participant"
+)
+
+// MaxTravelSpeed is the highest plausible speed of an item between two scans in km/h, about that of an airliner
+const MaxTravelSpeed = 1000.0
+
+// MaxScanAge is how far in seconds the timestamp signed by a scanning device may be from the time of the transaction
+const MaxScanAge = 300
+
+// earthRadius is the mean radius of the earth in km
+const earthRadius = 6371.0
+
+// The ScanEvent models a recorded scan of an item and the anomalies flagged on it
+type ScanEvent struct {
+	Type         string   `json:"docType"`
+	ID           string   `json:"scanID"`
+	TrackingID   string   `json:"trackingID"`
+	Location     string   `json:"location"`
+	Scanner      string   `json:"scanner"`
+	Organization string   `json:"organization"`
+	DeviceID     string   `json:"deviceID,omitempty"`
+	Status       string   `json:"status"`
+	Anomalies    []string `json:"anomalies"`
+	Timestamp    int64    `json:"timestamp"`
+}
+
+// The ScanRequest models a request body for a recorded scan, signed by the device that scanned the item
+type ScanRequest struct {
+	TrackingID string `json:"trackingID"`
+	Location   string `json:"location"`
+	DeviceID   string `json:"deviceID"`
+	Timestamp  int64  `json:"timestamp"`
+	Signature  string `json:"signature"`
+}
+
+// Validate checks that the scan has a location and a device signature and that its timestamp is within MaxScanAge
+// of now
+func (request *ScanRequest) Validate(now int64) error {
+	if strings.TrimSpace(request.Location) == "" {
+		return errors.New("a location is required to record a scan")
+	}
+	if request.DeviceID == "" || request.Signature == "" {
+		return errors.New("a scan must be signed by a registered device")
+	}
+	if math.Abs(float64(now-request.Timestamp)) > MaxScanAge {
+		return fmt.Errorf("scan timestamp %d is more than %d seconds from the transaction time", request.Timestamp, MaxScanAge)
+	}
+	return nil
+}
+
+// Payload returns the payload signed by the device, trackingID|location|timestamp
+func (request *ScanRequest) Payload() []byte {
+	return []byte(fmt.Sprintf("%s|%s|%d", request.TrackingID, request.Location, request.Timestamp))
+}
+
+// The SuspiciousItem models an item with recorded scans that were flagged
+type SuspiciousItem struct {
+	TrackingID string      `json:"trackingID"`
+	Anomalies  []string    `json:"anomalies"`
+	Scans      []ScanEvent `json:"scans"`
+}
+
+// Detect flags the anomalies of the scan given the previous recorded scan of the item, if any, whether the item is
+// sold or destroyed and whether the scanner is a participant of the item
+func (event *ScanEvent) Detect(previous *ScanEvent, sold bool, destroyed bool, participant bool) {
+	event.Anomalies = []string{}
+	if previous != nil && ImpossibleTravel(previous.Location, previous.Timestamp, event.Location, event.Timestamp) {
+		event.Anomalies = append(event.Anomalies, AnomalyImpossibleTravel)
+	}
+	if sold {
+		event.Anomalies = append(event.Anomalies, AnomalyAfterSale)
+	}
+	if destroyed {
+		event.Anomalies = append(event.Anomalies, AnomalyAfterDestruction)
+	}
+	if !participant {
+		event.Anomalies = append(event.Anomalies, AnomalyNonParticipant)
+	}
+}
+
+// Suspicious returns true if any anomaly was flagged on the scan
+func (event *ScanEvent) Suspicious() bool {
+	return len(event.Anomalies) != 0
+}
+
+// Add adds a flagged scan to the item along with its anomalies not flagged before
+func (item *SuspiciousItem) Add(event ScanEvent) {
+	for _, anomaly := range event.Anomalies {
+		if !contains(item.Anomalies, anomaly) {
+			item.Anomalies = append(item.Anomalies, anomaly)
+		}
+	}
+	item.Scans = append(item.Scans, event)
+}
+
+// ImpossibleTravel returns true if an item cannot have travelled between the two locations in the time between the
+// two scans. Locations without coordinates are never flagged.
+func ImpossibleTravel(from string, fromTimestamp int64, to string, toTimestamp int64) bool {
+	fromLatitude, fromLongitude, ok := ParseCoordinates(from)
+	if !ok {
+		return false
+	}
+	toLatitude, toLongitude, ok := ParseCoordinates(to)
+	if !ok {
+		return false
+	}
+	distance := Distance(fromLatitude, fromLongitude, toLatitude, toLongitude)
+	hours := math.Abs(float64(toTimestamp-fromTimestamp)) / 3600
+	return distance > MaxTravelSpeed*hours
+}
+
+// ParseCoordinates reads the latitude and longitude of a location in the latitude/longitude/name form used by the
+// locality of the certificates, e.g. 47.38/8.54/Zurich
+func ParseCoordinates(location string) (float64, float64, bool) {
+	parts := strings.Split(strings.TrimSpace(location), "/")
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	latitude, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || math.Abs(latitude) > 90 {
+		return 0, 0, false
+	}
+	longitude, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || math.Abs(longitude) > 180 {
+		return 0, 0, false
+	}
+	return latitude, longitude, true
+}
+
+// Distance returns the great-circle distance in km between two coordinates
+func Distance(fromLatitude float64, fromLongitude float64, toLatitude float64, toLongitude float64) float64 {
+	radians := math.Pi / 180
+	dLatitude := (toLatitude - fromLatitude) * radians
+	dLongitude := (toLongitude - fromLongitude) * radians
+	a := math.Sin(dLatitude/2)*math.Sin(dLatitude/2) +
+		math.Cos(fromLatitude*radians)*math.Cos(toLatitude*radians)*math.Sin(dLongitude/2)*math.Sin(dLongitude/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
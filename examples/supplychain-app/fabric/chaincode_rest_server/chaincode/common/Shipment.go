@@ -0,0 +1,139 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Route deviation types
+const (
+	DeviationCustodian = "custodian"
+	DeviationLocation  = "location"
+	DeviationOrder     = "order"
+)
+
+// The Shipment models a consignment of containers travelling from an origin to a destination along planned waypoints
+type Shipment struct {
+	Type               string      `json:"docType"`
+	ID                 string      `json:"shipmentID"`
+	Containers         []string    `json:"containers"`
+	Origin             string      `json:"origin"`
+	Destination        string      `json:"destination"`
+	Waypoints          []string    `json:"waypoints"`
+	ExpectedCustodians []string    `json:"expectedCustodians"`
+	ETA                int64       `json:"eta"`
+	Shipper            string      `json:"shipper"`
+	Participants       []string    `json:"participants"`
+	WaypointsReached   int         `json:"waypointsReached"`
+	ArrivedAt          int64       `json:"arrivedAt,omitempty"`
+	Deviations         []Deviation `json:"deviations"`
+	Redirects          []Redirect  `json:"redirects,omitempty"`
+	PurchaseOrder      string      `json:"purchaseOrder,omitempty"`
+	Timestamp          int64       `json:"timestamp"`
+}
+
+// The Deviation models a claim or scan of a shipped container that does not follow the planned route
+type Deviation struct {
+	Type       string `json:"type"`
+	TrackingID string `json:"trackingID"`
+	Custodian  string `json:"custodian,omitempty"`
+	Location   string `json:"location,omitempty"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+// The ShipmentRequest models a request body for a new shipment, the ETA is an RFC 3339 time
+type ShipmentRequest struct {
+	ID                 string   `json:"shipmentID"`
+	Containers         []string `json:"containers"`
+	Origin             string   `json:"origin"`
+	Destination        string   `json:"destination"`
+	Waypoints          []string `json:"waypoints"`
+	ExpectedCustodians []string `json:"expectedCustodians"`
+	ETA                string   `json:"eta"`
+}
+
+// Validate checks that the shipment has containers, an origin, a destination and an ETA
+func (request *ShipmentRequest) Validate() (time.Time, error) {
+	if request.ID == "" {
+		return time.Time{}, errors.New("shipmentID is required")
+	}
+	if len(request.Containers) == 0 {
+		return time.Time{}, errors.New("at least one container is required")
+	}
+	if strings.TrimSpace(request.Origin) == "" || strings.TrimSpace(request.Destination) == "" {
+		return time.Time{}, errors.New("origin and destination are required")
+	}
+	eta, err := time.Parse(time.RFC3339, request.ETA)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid eta %s, expecting an RFC 3339 time", request.ETA)
+	}
+	return eta, nil
+}
+
+// AccessibleBy returns true if the supplied identity is the shipper or an expected custodian of the shipment
+func (shipment *Shipment) AccessibleBy(id *Identity) bool {
+	return contains(shipment.Participants, id.Cert.Subject.String())
+}
+
+// Track checks a claim or scan of a container against the planned route and records the deviations found, an empty
+// custodian or location is not checked. Reaching the destination marks the shipment arrived.
+func (shipment *Shipment) Track(trackingID string, custodian string, location string, timestamp int64) []Deviation {
+	var deviations []Deviation
+	deviate := func(deviationType string) {
+		deviations = append(deviations, Deviation{
+			Type:       deviationType,
+			TrackingID: trackingID,
+			Custodian:  custodian,
+			Location:   location,
+			Timestamp:  timestamp,
+		})
+	}
+
+	if custodian != "" && custodian != shipment.Shipper && !contains(shipment.ExpectedCustodians, custodian) {
+		deviate(DeviationCustodian)
+	}
+	if location != "" {
+		waypoint := -1
+		for i, planned := range shipment.Waypoints {
+			if sameLocation(planned, location) {
+				waypoint = i
+			}
+		}
+		switch {
+		case sameLocation(shipment.Destination, location):
+			shipment.WaypointsReached = len(shipment.Waypoints)
+			if shipment.ArrivedAt == 0 {
+				shipment.ArrivedAt = timestamp
+			}
+		case waypoint != -1:
+			//going back to a waypoint before the last one reached
+			if waypoint+1 < shipment.WaypointsReached {
+				deviate(DeviationOrder)
+			} else {
+				shipment.WaypointsReached = waypoint + 1
+			}
+		case sameLocation(shipment.Origin, location):
+			if shipment.WaypointsReached != 0 {
+				deviate(DeviationOrder)
+			}
+		default:
+			deviate(DeviationLocation)
+		}
+	}
+	shipment.Deviations = append(shipment.Deviations, deviations...)
+	return deviations
+}
+
+// Late returns true if the shipment arrived after its ETA or has not arrived by the supplied time past its ETA
+func (shipment *Shipment) Late(now time.Time) bool {
+	if shipment.ArrivedAt != 0 {
+		return shipment.ArrivedAt > shipment.ETA
+	}
+	return now.UTC().Unix() > shipment.ETA
+}
+
+func sameLocation(a string, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
@@ -0,0 +1,220 @@
+package common
+
+import (
+	"errors"
+	"math"
+	"reflect"
+)
+
+// Telemetry metrics
+const (
+	MetricTemperature = "temperature"
+	MetricHumidity    = "humidity"
+)
+
+// The Reading models a single sensor reading of a container
+type Reading struct {
+	Timestamp   int64    `json:"timestamp"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	Humidity    *float64 `json:"humidity,omitempty"`
+	DeviceID    string   `json:"deviceID,omitempty"`
+}
+
+// The TelemetryRange models the allowed readings of a product type or container, unset bounds are not checked
+type TelemetryRange struct {
+	MinTemperature *float64 `json:"minTemperature,omitempty"`
+	MaxTemperature *float64 `json:"maxTemperature,omitempty"`
+	MinHumidity    *float64 `json:"minHumidity,omitempty"`
+	MaxHumidity    *float64 `json:"maxHumidity,omitempty"`
+}
+
+// Validate checks that no minimum is above its maximum
+func (r *TelemetryRange) Validate() error {
+	if r.MinTemperature != nil && r.MaxTemperature != nil && *r.MinTemperature > *r.MaxTemperature {
+		return errors.New("minTemperature is above maxTemperature")
+	}
+	if r.MinHumidity != nil && r.MaxHumidity != nil && *r.MinHumidity > *r.MaxHumidity {
+		return errors.New("minHumidity is above maxHumidity")
+	}
+	return nil
+}
+
+// Empty returns true if the range has no bounds
+func (r *TelemetryRange) Empty() bool {
+	return r.MinTemperature == nil && r.MaxTemperature == nil && r.MinHumidity == nil && r.MaxHumidity == nil
+}
+
+// Intersect narrows the range to the bounds of another range
+func (r *TelemetryRange) Intersect(other TelemetryRange) {
+	r.MinTemperature = tighter(r.MinTemperature, other.MinTemperature, math.Max)
+	r.MaxTemperature = tighter(r.MaxTemperature, other.MaxTemperature, math.Min)
+	r.MinHumidity = tighter(r.MinHumidity, other.MinHumidity, math.Max)
+	r.MaxHumidity = tighter(r.MaxHumidity, other.MaxHumidity, math.Min)
+}
+
+// Within returns true if the range keeps every bound of another range and none of its bounds are wider
+func (r *TelemetryRange) Within(other TelemetryRange) bool {
+	narrowed := *r
+	narrowed.Intersect(other)
+	return reflect.DeepEqual(narrowed, *r)
+}
+
+func tighter(a *float64, b *float64, pick func(float64, float64) float64) *float64 {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	value := pick(*a, *b)
+	return &value
+}
+
+// Violations returns the metrics of a reading that are outside the range
+func (r *TelemetryRange) Violations(reading Reading) []string {
+	var metrics []string
+	if outside(reading.Temperature, r.MinTemperature, r.MaxTemperature) {
+		metrics = append(metrics, MetricTemperature)
+	}
+	if outside(reading.Humidity, r.MinHumidity, r.MaxHumidity) {
+		metrics = append(metrics, MetricHumidity)
+	}
+	return metrics
+}
+
+func outside(value *float64, min *float64, max *float64) bool {
+	if value == nil {
+		return false
+	}
+	return (min != nil && *value < *min) || (max != nil && *value > *max)
+}
+
+// The Excursion models consecutive readings of one metric outside the allowed range
+type Excursion struct {
+	ContainerID string  `json:"containerID"`
+	Metric      string  `json:"metric"`
+	Start       int64   `json:"start"`
+	End         int64   `json:"end"`
+	Readings    int     `json:"readings"`
+	Worst       float64 `json:"worst"`
+}
+
+// The ExcursionSummary models the telemetry history of a product or container
+type ExcursionSummary struct {
+	Type       string      `json:"docType"`
+	ID         string      `json:"trackingID"`
+	Readings   int         `json:"readings"`
+	Excursions []Excursion `json:"excursions"`
+	//LastReading is the timestamp of the last reading recorded, an excursion ending at it is still open
+	LastReading int64 `json:"lastReading,omitempty"`
+}
+
+// Open returns the excursions of a container that were still open at the last reading recorded
+func (summary *ExcursionSummary) Open(containerID string) []Excursion {
+	var open []Excursion
+	for _, excursion := range summary.Excursions {
+		if excursion.ContainerID == containerID && excursion.End == summary.LastReading {
+			open = append(open, excursion)
+		}
+	}
+	return open
+}
+
+// Record adds the readings and excursions of a batch, replacing the excursions it continued
+func (summary *ExcursionSummary) Record(readings []Reading, excursions []Excursion) {
+	summary.Readings += len(readings)
+	for _, reading := range readings {
+		if reading.Timestamp > summary.LastReading {
+			summary.LastReading = reading.Timestamp
+		}
+	}
+	for _, excursion := range excursions {
+		continued := false
+		for i, recorded := range summary.Excursions {
+			if recorded.ContainerID == excursion.ContainerID && recorded.Metric == excursion.Metric && recorded.Start == excursion.Start {
+				summary.Excursions[i] = excursion
+				continued = true
+			}
+		}
+		if !continued {
+			summary.Excursions = append(summary.Excursions, excursion)
+		}
+	}
+}
+
+// DetectExcursions groups the out of range readings of a batch, ordered by timestamp, into excursions per metric.
+// Excursions still open at the end of the previous batch are continued by readings that stay out of range.
+func DetectExcursions(containerID string, readings []Reading, allowed TelemetryRange, previous []Excursion) []Excursion {
+	var excursions []Excursion
+	open := map[string]*Excursion{}
+	stale := map[string]bool{}
+	for i := range previous {
+		excursion := previous[i]
+		open[excursion.Metric] = &excursion
+		stale[excursion.Metric] = true
+	}
+	for _, reading := range readings {
+		violated := map[string]bool{}
+		for _, metric := range allowed.Violations(reading) {
+			violated[metric] = true
+			value := *reading.Temperature
+			if metric == MetricHumidity {
+				value = *reading.Humidity
+			}
+			excursion, ok := open[metric]
+			if !ok {
+				excursion = &Excursion{ContainerID: containerID, Metric: metric, Start: reading.Timestamp, Worst: value}
+				open[metric] = excursion
+			}
+			excursion.End = reading.Timestamp
+			excursion.Readings++
+			delete(stale, metric)
+			if math.Abs(value-midpoint(metric, allowed)) > math.Abs(excursion.Worst-midpoint(metric, allowed)) {
+				excursion.Worst = value
+			}
+		}
+		//a reading back in range closes the excursion of that metric
+		for _, metric := range []string{MetricTemperature, MetricHumidity} {
+			if excursion, ok := open[metric]; ok && !violated[metric] {
+				if !stale[metric] {
+					excursions = append(excursions, *excursion)
+				}
+				delete(open, metric)
+			}
+		}
+	}
+	for _, metric := range []string{MetricTemperature, MetricHumidity} {
+		if excursion, ok := open[metric]; ok && !stale[metric] {
+			excursions = append(excursions, *excursion)
+		}
+	}
+	return excursions
+}
+
+// ExcursionReason returns the health reason code of a batch of excursions, a temperature excursion outweighs a
+// humidity excursion
+func ExcursionReason(excursions []Excursion) string {
+	for _, excursion := range excursions {
+		if excursion.Metric == MetricTemperature {
+			return ReasonTemperatureExcursion
+		}
+	}
+	return ReasonHumidityExcursion
+}
+
+// midpoint returns the centre of the allowed range of a metric, or its only bound
+func midpoint(metric string, allowed TelemetryRange) float64 {
+	min, max := allowed.MinTemperature, allowed.MaxTemperature
+	if metric == MetricHumidity {
+		min, max = allowed.MinHumidity, allowed.MaxHumidity
+	}
+	switch {
+	case min != nil && max != nil:
+		return (*min + *max) / 2
+	case min != nil:
+		return *min
+	case max != nil:
+		return *max
+	}
+	return 0
+}
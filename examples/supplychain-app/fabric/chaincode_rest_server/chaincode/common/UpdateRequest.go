@@ -4,6 +4,7 @@ package common
 type UpdateRequest struct {
 	ID       string                 `json:"trackingID"`
 	Health   string                 `json:"health"`
+	Reason   string                 `json:"reason"`
 	Metadata map[string]interface{} `json:"misc"`
 	Location string                 `json:"lastScannedAt"`
 }
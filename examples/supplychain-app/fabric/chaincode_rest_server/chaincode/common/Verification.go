@@ -0,0 +1,121 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Fields of a product a manufacturer can disclose to any identity verifying it
+const (
+	PublicManufacturer = "manufacturer"
+	PublicName         = "productName"
+	PublicStatus       = "status"
+	PublicJourney      = "journey"
+)
+
+// DisclosableFields lists every field of a product that can be disclosed
+var DisclosableFields = []string{PublicManufacturer, PublicName, PublicStatus, PublicJourney}
+
+// DefaultPublicFields are disclosed for products a manufacturer has not chosen the public fields of
+var DefaultPublicFields = []string{PublicManufacturer, PublicName, PublicStatus}
+
+// Statuses of a verified product
+const (
+	VerifiedOK        = "ok"
+	VerifiedRecalled  = "recalled"
+	VerifiedExpired   = "expired"
+	VerifiedDestroyed = "destroyed"
+)
+
+// journeyLayout is the month the coarse journey of a product passed a location in
+const journeyLayout = "2006-01"
+
+// The PublicView models the disclosed fields of a product returned to any identity verifying it
+type PublicView struct {
+	TrackingID   string        `json:"trackingID"`
+	Authentic    bool          `json:"authentic"`
+	Manufacturer string        `json:"manufacturer,omitempty"`
+	Name         string        `json:"productName,omitempty"`
+	Status       string        `json:"status,omitempty"`
+	Expiry       string        `json:"expiry,omitempty"`
+	Journey      []JourneyStep `json:"journey,omitempty"`
+}
+
+// The JourneyStep models a place a product passed in a month, without the custodians holding it
+type JourneyStep struct {
+	Location string `json:"location"`
+	Month    string `json:"month"`
+}
+
+// ValidatePublicFields checks that only fields that can be disclosed are chosen
+func ValidatePublicFields(fields []string) error {
+	for _, field := range fields {
+		if !contains(DisclosableFields, field) {
+			return fmt.Errorf("Field %s cannot be made public, expecting any of %v", field, DisclosableFields)
+		}
+	}
+	return nil
+}
+
+// Discloses returns true if the supplied field of the product is public
+func (product *Product) Discloses(field string) bool {
+	if product.PublicFields == nil {
+		return contains(DefaultPublicFields, field)
+	}
+	return contains(product.PublicFields, field)
+}
+
+// PublicView returns the public fields of the product, destroyed products are not authentic as they should never
+// show up again
+func (product *Product) PublicView(now time.Time, history []History) PublicView {
+	view := PublicView{
+		TrackingID: product.ID,
+		Authentic:  product.Health != HealthDestroyed,
+	}
+	if product.Discloses(PublicManufacturer) {
+		view.Manufacturer = product.Manufacturer
+	}
+	if product.Discloses(PublicName) {
+		view.Name = product.Name
+	}
+	if product.Discloses(PublicStatus) {
+		switch {
+		case product.Health == HealthDestroyed:
+			view.Status = VerifiedDestroyed
+		case product.Recalled:
+			view.Status = VerifiedRecalled
+		case product.Expired(now):
+			view.Status = VerifiedExpired
+		default:
+			view.Status = VerifiedOK
+		}
+		view.Expiry = product.Expiry
+	}
+	if product.Discloses(PublicJourney) {
+		view.Journey = CoarseJourney(history)
+	}
+	return view
+}
+
+// CoarseJourney reduces the custody history of a product to the names of the places it passed and the month it
+// passed them, dropping coordinates and custodians
+func CoarseJourney(history []History) []JourneyStep {
+	journey := []JourneyStep{}
+	for _, item := range history {
+		parts := strings.Split(item.Location, "/")
+		location := strings.TrimSpace(parts[len(parts)-1])
+		if location == "" {
+			continue
+		}
+		step := JourneyStep{
+			Location: location,
+			Month:    time.Unix(item.Timestamp, 0).UTC().Format(journeyLayout),
+		}
+		if len(journey) != 0 && journey[len(journey)-1].Location == step.Location {
+			continue
+		}
+		journey = append(journey, step)
+	}
+	return journey
+}
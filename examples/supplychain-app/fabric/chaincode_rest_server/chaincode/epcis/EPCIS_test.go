@@ -0,0 +1,111 @@
+package epcis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestEPCIS(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	created := time.Unix(1552583510, 0)
+	product := State{
+		ID:        "0d15d7b8-caaa-468d-8b83-aae049b40f46",
+		Name:      "Dextrose",
+		Custodian: "CN=User1@manufacturer-net,OU=user+OU=Manufacturer,O=PartyA,L=47.38/8.54/Zurich,C=CH",
+		Location:  "Zurich",
+		Time:      created,
+	}
+
+	g.Describe("Events", func() {
+		g.It("should commission the first revision", func() {
+			events := Events([]State{product})
+
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal(ObjectEvent))
+			Expect(events[0].Action).To(Equal(ActionAdd))
+			Expect(events[0].BizStep).To(Equal("commissioning"))
+			Expect(events[0].Disposition).To(Equal("active"))
+			Expect(events[0].EPCList).To(Equal([]string{"urn:uuid:0d15d7b8-caaa-468d-8b83-aae049b40f46"}))
+			Expect(events[0].ILMD["productName"]).To(Equal("Dextrose"))
+		})
+
+		g.It("should map packing, custody changes and sales", func() {
+			packed := product
+			packed.ContainerID = "container-1"
+			packed.Time = created.Add(time.Hour)
+			claimed := packed
+			claimed.Custodian = "OU=Carrier,O=PartyB,L=51.50/-0.13/London,C=US"
+			claimed.Time = created.Add(2 * time.Hour)
+			sold := claimed
+			sold.ContainerID = ""
+			sold.Sold = true
+			sold.Time = created.Add(3 * time.Hour)
+
+			events := Events([]State{product, packed, claimed, sold})
+
+			Expect(events).To(HaveLen(5))
+			Expect(events[1].Type).To(Equal(AggregationEvent))
+			Expect(events[1].Action).To(Equal(ActionAdd))
+			Expect(events[1].ParentID).To(Equal(EPC("container-1")))
+			Expect(events[2].Type).To(Equal(TransferEvent))
+			Expect(events[2].BizStep).To(Equal("receiving"))
+			Expect(events[2].SourceList[0].Type).To(Equal(PossessingParty))
+			Expect(events[2].SourceList[0].Source).To(Equal(PartyID(product.Custodian)))
+			Expect(events[2].DestinationList[0].Destination).To(Equal(PartyID(claimed.Custodian)))
+			Expect(events[3].Action).To(Equal(ActionDelete))
+			Expect(events[3].BizStep).To(Equal("unpacking"))
+			Expect(events[4].BizStep).To(Equal("retail_selling"))
+			Expect(events[4].Disposition).To(Equal("retail_sold"))
+		})
+	})
+
+	g.Describe("Import", func() {
+		g.It("should turn commissioning and packing into transactions", func() {
+			container := State{ID: "container-1", Container: true, Location: "Zurich", Time: created}
+			packed := product
+			packed.ContainerID = "container-1"
+			packed.Time = created.Add(time.Hour)
+			events := append(Events([]State{container}), Events([]State{product, packed})...)
+
+			calls, err := Import(NewDocument(events, created), []string{"OU=Carrier,O=PartyB,L=51.50/-0.13/London,C=US"})
+
+			Expect(err).To(BeNil())
+			Expect(calls).To(HaveLen(3))
+			Expect(calls[0].Function).To(Equal("createContainer"))
+			Expect(calls[1].Function).To(Equal("createProduct"))
+			Expect(calls[1].Args[0]).To(ContainSubstring(`"trackingID":"0d15d7b8-caaa-468d-8b83-aae049b40f46"`))
+			Expect(calls[1].Args[0]).To(ContainSubstring(`"lastScannedAt":"Zurich"`))
+			Expect(calls[2]).To(Equal(Call{Function: "package", Args: []string{"container-1", product.ID}}))
+		})
+
+		g.It("should hand the title to the last owning party of a transfer", func() {
+			owned := product
+			owned.Owner = product.Custodian
+			sold := owned
+			sold.Owner = "OU=Store,O=PartyD,L=40.73/-74/New York,C=US"
+			sold.Time = created.Add(time.Hour)
+			events := Events([]State{owned, sold})
+
+			Expect(events[1].Type).To(Equal(TransferEvent))
+			Expect(events[1].BizStep).To(Equal("accepting"))
+			Expect(events[1].SourceList).To(Equal([]Source{{Type: OwningParty, Source: PartyID(owned.Owner)}}))
+
+			calls, err := Import(NewDocument(events, created), nil)
+
+			Expect(err).To(BeNil())
+			Expect(calls).To(HaveLen(2))
+			Expect(calls[1]).To(Equal(Call{Function: "transferOwnership", Args: []string{product.ID, sold.Owner}}))
+		})
+
+		g.It("should reject documents that are not EPCIS documents", func() {
+			_, err := Import(Document{Type: "Foo"}, nil)
+
+			Expect(err).NotTo(BeNil())
+		})
+	})
+}
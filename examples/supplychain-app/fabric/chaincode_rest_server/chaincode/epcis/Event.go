@@ -0,0 +1,95 @@
+package epcis
+
+import "time"
+
+// Context is the JSON-LD context every EPCIS 2.0 document refers to
+const Context = "https://ref.gs1.org/standards/epcis/2.0.0/epcis-context.jsonld"
+
+// Event types produced and consumed by this package
+const (
+	ObjectEvent      = "ObjectEvent"
+	AggregationEvent = "AggregationEvent"
+	TransferEvent    = "TransferEvent"
+)
+
+// CBV source and destination types of transfer events
+const (
+	OwningParty     = "owning_party"
+	PossessingParty = "possessing_party"
+)
+
+// CBV master data attributes carried in the ILMD of commissioning events
+const (
+	ILMDLot    = "cbvmda:lotNumber"
+	ILMDExpiry = "cbvmda:itemExpirationDate"
+)
+
+// Event actions
+const (
+	ActionAdd     = "ADD"
+	ActionObserve = "OBSERVE"
+	ActionDelete  = "DELETE"
+)
+
+// The Document models an EPCIS 2.0 JSON-LD document
+type Document struct {
+	Context       []string `json:"@context"`
+	Type          string   `json:"type"`
+	SchemaVersion string   `json:"schemaVersion"`
+	CreationDate  string   `json:"creationDate"`
+	Body          Body     `json:"epcisBody"`
+}
+
+// The Body wraps the list of events in an EPCIS document
+type Body struct {
+	EventList []Event `json:"eventList"`
+}
+
+// The Event models a single EPCIS event, only the fields used by the supply chain are included
+type Event struct {
+	Type                string                 `json:"type"`
+	EventTime           string                 `json:"eventTime"`
+	EventTimeZoneOffset string                 `json:"eventTimeZoneOffset"`
+	EPCList             []string               `json:"epcList,omitempty"`
+	ParentID            string                 `json:"parentID,omitempty"`
+	ChildEPCs           []string               `json:"childEPCs,omitempty"`
+	Action              string                 `json:"action"`
+	BizStep             string                 `json:"bizStep,omitempty"`
+	Disposition         string                 `json:"disposition,omitempty"`
+	ReadPoint           *Location              `json:"readPoint,omitempty"`
+	BizLocation         *Location              `json:"bizLocation,omitempty"`
+	SourceList          []Source               `json:"sourceList,omitempty"`
+	DestinationList     []Destination          `json:"destinationList,omitempty"`
+	ILMD                map[string]interface{} `json:"ilmd,omitempty"`
+}
+
+// The Location models a readPoint or bizLocation
+type Location struct {
+	ID string `json:"id"`
+}
+
+// The Source models an entry of an events sourceList
+type Source struct {
+	Type   string `json:"type"`
+	Source string `json:"source"`
+}
+
+// The Destination models an entry of an events destinationList
+type Destination struct {
+	Type        string `json:"type"`
+	Destination string `json:"destination"`
+}
+
+// NewDocument wraps the supplied events in an EPCIS document created at the supplied time
+func NewDocument(events []Event, created time.Time) Document {
+	if events == nil {
+		events = []Event{}
+	}
+	return Document{
+		Context:       []string{Context},
+		Type:          "EPCISDocument",
+		SchemaVersion: "2.0",
+		CreationDate:  created.UTC().Format(time.RFC3339),
+		Body:          Body{EventList: events},
+	}
+}
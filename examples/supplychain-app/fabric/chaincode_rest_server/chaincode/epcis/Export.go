@@ -0,0 +1,228 @@
+package epcis
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	. "github.com/chaincode/common"
+)
+
+// uriPrefix namespaces identifiers that have no standard URI representation
+const uriPrefix = "urn:bevel:supplychain:"
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// dispositions maps health values onto CBV dispositions
+var dispositions = map[string]string{
+	"damaged":      "damaged",
+	"destroyed":    "destroyed",
+	"expired":      "expired",
+	"stolen":       "stolen",
+	"recalled":     "recalled",
+	"non_sellable": "non_sellable_other",
+	"quarantined":  "non_sellable_other",
+	"compromised":  "non_sellable_other",
+}
+
+// The State is a single revision of a product or container as read from the ledger history
+type State struct {
+	ID          string
+	Name        string
+	Lot         string
+	Expiry      string
+	Container   bool
+	Health      string
+	Sold        bool
+	Recalled    bool
+	Custodian   string
+	Owner       string
+	Location    string
+	ContainerID string
+	Time        time.Time
+}
+
+// ProductState returns the State of a product revision written at the supplied time
+func ProductState(product Product, t time.Time) State {
+	return State{
+		ID:          product.ID,
+		Name:        product.Name,
+		Lot:         product.Lot,
+		Expiry:      product.Expiry,
+		Health:      product.Health,
+		Sold:        product.Sold,
+		Recalled:    product.Recalled,
+		Custodian:   product.Custodian,
+		Owner:       product.CurrentOwner(),
+		Location:    product.Location,
+		ContainerID: product.ContainerID,
+		Time:        t,
+	}
+}
+
+// ContainerState returns the State of a container revision written at the supplied time
+func ContainerState(container Container, t time.Time) State {
+	return State{
+		ID:          container.ID,
+		Container:   true,
+		Health:      container.Health,
+		Custodian:   container.Custodian,
+		Owner:       container.CurrentOwner(),
+		Location:    container.Location,
+		ContainerID: container.ContainerID,
+		Time:        t,
+	}
+}
+
+// EPC returns the URI used for a trackingID in EPCIS documents
+func EPC(trackingID string) string {
+	if uuidPattern.MatchString(trackingID) {
+		return "urn:uuid:" + strings.ToLower(trackingID)
+	}
+	return uriPrefix + "item:" + url.PathEscape(trackingID)
+}
+
+// TrackingID returns the trackingID of an EPC produced by EPC, or the EPC itself if it is foreign
+func TrackingID(epc string) string {
+	if strings.HasPrefix(epc, "urn:uuid:") {
+		return strings.TrimPrefix(epc, "urn:uuid:")
+	}
+	return unescape(epc, "item:")
+}
+
+// LocationID returns the URI used for a lastScannedAt value
+func LocationID(location string) string {
+	return uriPrefix + "location:" + url.PathEscape(location)
+}
+
+// PartyID returns the URI used for a custodian certificate subject
+func PartyID(subject string) string {
+	return uriPrefix + "party:" + url.PathEscape(subject)
+}
+
+// PartySubject returns the certificate subject of a URI produced by PartyID, or the URI itself if it is foreign
+func PartySubject(party string) string {
+	return unescape(party, "party:")
+}
+
+func unescape(uri string, kind string) string {
+	if !strings.HasPrefix(uri, uriPrefix+kind) {
+		return uri
+	}
+	value, err := url.PathUnescape(strings.TrimPrefix(uri, uriPrefix+kind))
+	if err != nil {
+		return uri
+	}
+	return value
+}
+
+// Events returns the EPCIS events describing the supplied revisions of a single item, oldest first
+func Events(revisions []State) []Event {
+	var events []Event
+	for i, cur := range revisions {
+		if i == 0 {
+			event := newEvent(ObjectEvent, ActionAdd, "commissioning", cur)
+			if event.Disposition == "" {
+				event.Disposition = "active"
+			}
+			if !cur.Container {
+				event.ILMD = map[string]interface{}{"productName": cur.Name}
+				if cur.Lot != "" {
+					event.ILMD[ILMDLot] = cur.Lot
+				}
+				if cur.Expiry != "" {
+					event.ILMD[ILMDExpiry] = cur.Expiry
+				}
+			}
+			events = append(events, event)
+			if cur.ContainerID != "" {
+				events = append(events, aggregation(ActionAdd, "packing", cur.ContainerID, cur))
+			}
+			continue
+		}
+		prev := revisions[i-1]
+		if cur.ContainerID != prev.ContainerID {
+			if prev.ContainerID != "" {
+				events = append(events, aggregation(ActionDelete, "unpacking", prev.ContainerID, cur))
+			}
+			if cur.ContainerID != "" {
+				events = append(events, aggregation(ActionAdd, "packing", cur.ContainerID, cur))
+			}
+		}
+		if cur.Custodian != prev.Custodian || cur.Owner != prev.Owner {
+			events = append(events, transfer(prev, cur))
+		}
+		if cur.Sold && !prev.Sold {
+			events = append(events, newEvent(ObjectEvent, ActionObserve, "retail_selling", cur))
+		}
+		if cur.Recalled && !prev.Recalled {
+			events = append(events, newEvent(ObjectEvent, ActionObserve, "holding", cur))
+		}
+		if cur.Health != prev.Health {
+			events = append(events, newEvent(ObjectEvent, ActionObserve, "inspecting", cur))
+		}
+	}
+	return events
+}
+
+func newEvent(eventType string, action string, bizStep string, state State) Event {
+	event := Event{
+		Type:                eventType,
+		EventTime:           state.Time.UTC().Format(time.RFC3339),
+		EventTimeZoneOffset: "+00:00",
+		EPCList:             []string{EPC(state.ID)},
+		Action:              action,
+		BizStep:             bizStep,
+		Disposition:         Disposition(state),
+	}
+	if state.Location != "" {
+		event.ReadPoint = &Location{ID: LocationID(state.Location)}
+		event.BizLocation = &Location{ID: LocationID(state.Location)}
+	}
+	return event
+}
+
+func aggregation(action string, bizStep string, parentID string, state State) Event {
+	event := newEvent(AggregationEvent, action, bizStep, state)
+	event.EPCList = nil
+	event.ParentID = EPC(parentID)
+	event.ChildEPCs = []string{EPC(state.ID)}
+	return event
+}
+
+// transfer describes a change of custody, title or both. The parties before and after are listed as sources and
+// destinations so that the seller survives a round trip.
+func transfer(prev State, cur State) Event {
+	bizStep := "receiving"
+	if cur.Custodian == prev.Custodian {
+		bizStep = "accepting"
+	}
+	event := newEvent(TransferEvent, ActionObserve, bizStep, cur)
+	if cur.Custodian != prev.Custodian {
+		event.SourceList = append(event.SourceList, Source{Type: PossessingParty, Source: PartyID(prev.Custodian)})
+		event.DestinationList = append(event.DestinationList, Destination{Type: PossessingParty, Destination: PartyID(cur.Custodian)})
+	}
+	if cur.Owner != prev.Owner {
+		event.SourceList = append(event.SourceList, Source{Type: OwningParty, Source: PartyID(prev.Owner)})
+		event.DestinationList = append(event.DestinationList, Destination{Type: OwningParty, Destination: PartyID(cur.Owner)})
+	}
+	return event
+}
+
+// Disposition maps the flags and health of a State onto a CBV disposition
+func Disposition(state State) string {
+	switch {
+	case state.Recalled:
+		return "recalled"
+	case state.Sold:
+		return "retail_sold"
+	}
+	if disposition, ok := dispositions[strings.ToLower(state.Health)]; ok {
+		return disposition
+	}
+	if state.Health == "" {
+		return ""
+	}
+	return "in_progress"
+}
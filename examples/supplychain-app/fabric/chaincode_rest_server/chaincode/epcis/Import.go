@@ -0,0 +1,108 @@
+package epcis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	. "github.com/chaincode/common"
+)
+
+// The Call models a chaincode transaction derived from an EPCIS event
+type Call struct {
+	Function string   `json:"function"`
+	Args     []string `json:"args"`
+}
+
+// Import turns the commissioning and packing events of an EPCIS document into
+// createProduct, createContainer and package calls. Commissioned EPCs carrying a
+// productName in their ILMD become products, all others become containers. The
+// last owning party a TransferEvent hands an EPC to becomes a transferOwnership
+// call after everything is created and packed. Events that have no equivalent
+// transaction are ignored.
+func Import(document Document, participants []string) ([]Call, error) {
+	if document.Type != "EPCISDocument" {
+		return nil, errors.New("Not an EPCIS document")
+	}
+
+	events := append([]Event{}, document.Body.EventList...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(events[i]).Before(eventTime(events[j]))
+	})
+
+	var calls []Call
+	var transferred []string
+	owners := map[string]string{}
+	for _, event := range events {
+		location := ""
+		if event.BizLocation != nil {
+			location = unescape(event.BizLocation.ID, "location:")
+		} else if event.ReadPoint != nil {
+			location = unescape(event.ReadPoint.ID, "location:")
+		}
+
+		switch {
+		case event.Type == ObjectEvent && event.Action == ActionAdd && event.BizStep == "commissioning":
+			name, _ := event.ILMD["productName"].(string)
+			lot, _ := event.ILMD[ILMDLot].(string)
+			expiry, _ := event.ILMD[ILMDExpiry].(string)
+			for _, epc := range event.EPCList {
+				call, err := createCall(TrackingID(epc), name, lot, expiry, location, participants)
+				if err != nil {
+					return nil, err
+				}
+				calls = append(calls, call)
+			}
+		case event.Type == AggregationEvent && event.Action == ActionAdd:
+			if event.ParentID == "" {
+				return nil, fmt.Errorf("AggregationEvent at %s has no parentID", event.EventTime)
+			}
+			for _, epc := range event.ChildEPCs {
+				calls = append(calls, Call{Function: "package", Args: []string{TrackingID(event.ParentID), TrackingID(epc)}})
+			}
+		case event.Type == TransferEvent:
+			for _, destination := range event.DestinationList {
+				if destination.Type != OwningParty {
+					continue
+				}
+				for _, epc := range event.EPCList {
+					trackingID := TrackingID(epc)
+					if _, ok := owners[trackingID]; !ok {
+						transferred = append(transferred, trackingID)
+					}
+					owners[trackingID] = PartySubject(destination.Destination)
+				}
+			}
+		}
+	}
+	for _, trackingID := range transferred {
+		calls = append(calls, Call{Function: "transferOwnership", Args: []string{trackingID, owners[trackingID]}})
+	}
+	return calls, nil
+}
+
+func createCall(trackingID string, name string, lot string, expiry string, location string, participants []string) (Call, error) {
+	var request interface{}
+	function := "createContainer"
+	if name != "" {
+		function = "createProduct"
+		request = ProductRequest{ID: trackingID, ProductName: name, Lot: lot, Expiry: expiry, Location: location, Participants: participants}
+	} else {
+		request = ContainerRequest{ID: trackingID, Location: location, Participants: participants}
+	}
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return Call{}, err
+	}
+	return Call{Function: function, Args: []string{string(requestBytes)}}, nil
+}
+
+func eventTime(event Event) time.Time {
+	t, err := time.Parse(time.RFC3339, event.EventTime)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
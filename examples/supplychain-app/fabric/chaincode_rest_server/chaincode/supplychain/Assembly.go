@@ -0,0 +1,307 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// assembleProduct creates a product from input products held by the current user, the inputs are consumed
+func (s *SmartContract) assembleProduct(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if !identity.CanInvoke("assembleProduct") {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, cannot invoke assembleProduct"),
+		}
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	var request AssemblyRequest
+	if err := json.Unmarshal([]byte(args[0]), &request); err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(request.Inputs) == 0 {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: at least one input product is required "),
+		}
+	}
+
+	//every input must be an unpackaged, unconsumed product held by the current user
+	inputs := []Product{}
+	seen := map[string]bool{}
+	for _, inputID := range request.Inputs {
+		if seen[inputID] || inputID == request.ID {
+			return peer.Response{
+				Status:  400,
+				Message: fmt.Sprintf("Error: input %s is listed twice or is the assembled product ", inputID),
+			}
+		}
+		seen[inputID] = true
+
+		inputBytes, _ := stub.GetState(inputID)
+		var input Product
+		if len(inputBytes) == 0 || json.Unmarshal(inputBytes, &input) != nil {
+			return peer.Response{
+				Status:  404,
+				Message: fmt.Sprintf("Product %s Not Found", inputID),
+			}
+		}
+		if identity.Cert.Subject.String() != input.Custodian {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("You are not authorized to perform this transaction, product %s not held by identity", inputID),
+			}
+		}
+		if input.ContainerID != "" {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("Product %s needs to be unpackaged before it can be assembled", inputID),
+			}
+		}
+		if input.ConsumedBy != "" || input.Sold || input.Recalled {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("Product %s is consumed, sold or recalled", inputID),
+			}
+		}
+		if input.Expired(s.clock.Now()) {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("Product %s expired on %s", inputID, input.Expiry),
+			}
+		}
+		rules, err := loadHealthRules(stub, input.Manufacturer)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if err := rules.Allows(input.Health, ActionAssemble); err != nil {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("Product %s: %s", inputID, err),
+			}
+		}
+		inputs = append(inputs, input)
+	}
+
+	product, validation := s.newProduct(stub, identity, request.ProductRequest)
+	if validation.Status != shim.OK {
+		return validation
+	}
+	product.Inputs = request.Inputs
+
+	//mark the inputs consumed by the assembled product
+	for _, input := range inputs {
+		input.ConsumedBy = product.ID
+		input.Timestamp = product.Timestamp
+		inputBytes, _ := json.Marshal(input)
+		if err := stub.PutState(input.ID, inputBytes); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	productBytes, _ := json.Marshal(product)
+	if err := stub.PutState(product.ID, productBytes); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	response := map[string]interface{}{
+		"generatedID": product.ID,
+		"inputs":      product.Inputs,
+	}
+	bytes, _ := json.Marshal(response)
+
+	s.logger.Infof("Assembled Product %s from %d inputs\n", product.ID, len(inputs))
+	return shim.Success(bytes)
+}
+
+// traceComponents answers what went into a product, every input of its assembly and their inputs in turn, along with
+// the bulk products it was split from
+func (s *SmartContract) traceComponents(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	return s.trace(stub, args, productInputs)
+}
+
+// traceProducts answers where a component ended up, the portions split from it and the product that consumed it and
+// so on up to the finished good
+func (s *SmartContract) traceProducts(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	return s.trace(stub, args, productOutputs)
+}
+
+// recallProduct recalls a product, every portion split from it and every finished good they were assembled into, on
+// behalf of the manufacturer or the owner of the product
+func (s *SmartContract) recallProduct(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	trackingID := args[0]
+
+	product, err := getProduct(stub, trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if product == nil || !product.AccessibleBy(identity) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Product %s Not Found", trackingID),
+		}
+	}
+	allowed := product.CurrentOwner() == identity.Cert.Subject.String()
+	if !allowed {
+		manufacturer, err := resolveManufacturer(stub, product, identity)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		allowed = manufacturer == identity.Organization
+	}
+	if !allowed {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, product not manufactured or owned by identity"),
+		}
+	}
+
+	products, err := traceProductTree(stub, trackingID, productOutputs)
+	if err != nil {
+		return peer.Response{
+			Status:  404,
+			Message: err.Error(),
+		}
+	}
+
+	recalled := []string{}
+	for _, product := range products {
+		if product.Recalled {
+			continue
+		}
+		product.Recalled = true
+		product.Timestamp = int64(s.clock.Now().UTC().Unix())
+		productBytes, _ := json.Marshal(product)
+		if err := stub.PutState(product.ID, productBytes); err != nil {
+			return shim.Error(err.Error())
+		}
+		recalled = append(recalled, product.ID)
+	}
+
+	response := map[string]interface{}{
+		"recalled": recalled,
+	}
+	bytes, _ := json.Marshal(response)
+
+	s.logger.Infof("Recalled %s and %d products\n", trackingID, len(recalled))
+	return shim.Success(bytes)
+}
+
+// trace returns the products linked to a product accessible by the current user, following the supplied links
+func (s *SmartContract) trace(stub shim.ChaincodeStubInterface, args []string, links func(Product) []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	trackingID := args[0]
+
+	productBytes, _ := stub.GetState(trackingID)
+	var product Product
+	if len(productBytes) == 0 || json.Unmarshal(productBytes, &product) != nil || !product.AccessibleBy(identity) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Product %s Not Found", trackingID),
+		}
+	}
+
+	//only the identifying fields of linked products are returned, their custody stays private
+	nodes := []TraceNode{}
+	depth := map[string]int{trackingID: 0}
+	via := map[string]string{}
+	products, err := traceProductTree(stub, trackingID, func(product Product) []string {
+		for _, id := range links(product) {
+			if _, ok := depth[id]; !ok {
+				depth[id] = depth[product.ID] + 1
+				via[id] = product.ID
+			}
+		}
+		return links(product)
+	})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	for _, linked := range products[1:] {
+		nodes = append(nodes, TraceNode{
+			TrackingID: linked.ID,
+			Name:       linked.Name,
+			Lot:        linked.Lot,
+			Via:        via[linked.ID],
+			Depth:      depth[linked.ID],
+			Returns:    linked.Returns,
+		})
+	}
+	nodesBytes, _ := json.Marshal(nodes)
+	return shim.Success(nodesBytes)
+}
+
+// traceProductTree returns the product with the supplied trackingID followed by every product reachable through
+// the supplied links, breadth first
+func traceProductTree(stub shim.ChaincodeStubInterface, trackingID string, links func(Product) []string) ([]Product, error) {
+	var products []Product
+	visited := map[string]bool{trackingID: true}
+	queue := []string{trackingID}
+	for len(queue) != 0 {
+		id := queue[0]
+		queue = queue[1:]
+		productBytes, err := stub.GetState(id)
+		if err != nil {
+			return products, err
+		}
+		var product Product
+		if len(productBytes) == 0 || json.Unmarshal(productBytes, &product) != nil {
+			return products, fmt.Errorf("Product %s Not Found", id)
+		}
+		products = append(products, product)
+		for _, linked := range links(product) {
+			if !visited[linked] {
+				visited[linked] = true
+				queue = append(queue, linked)
+			}
+		}
+	}
+	return products, nil
+}
+
+// productInputs returns the inputs of the assembly of a product along with the bulk product it was split from
+func productInputs(product Product) []string {
+	if product.SplitFrom == "" {
+		return product.Inputs
+	}
+	return append([]string{product.SplitFrom}, product.Inputs...)
+}
+
+// productOutputs returns the portions split from a product along with the product that consumed it
+func productOutputs(product Product) []string {
+	outputs := append([]string{}, product.Splits...)
+	if product.ConsumedBy != "" {
+		outputs = append(outputs, product.ConsumedBy)
+	}
+	return outputs
+}
@@ -0,0 +1,125 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestAssembly(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	producer := org1Identity.subject()
+	carrier := carrierIdentity.subject()
+
+	createProduct := func(request string) {
+		bed.mustInvoke("createProduct", request)
+	}
+	getProduct := func(trackingID string) Product {
+		var product Product
+		json.Unmarshal(bed.mustInvoke("getProduct", trackingID), &product)
+		return product
+	}
+	trace := func(function string, trackingID string) []TraceNode {
+		var nodes []TraceNode
+		json.Unmarshal(bed.mustInvoke(function, trackingID), &nodes)
+		return nodes
+	}
+
+	g.Describe("Assemble Product", func() {
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC))
+			bed.as(org1Identity)
+			createProduct(`{"trackingID":"syringe-1","productName":"Syringe","lot":"S1","counterparties":[]}`)
+			createProduct(`{"trackingID":"vial-1","productName":"Vial","lot":"V1","counterparties":[]}`)
+			createProduct(`{"trackingID":"leaflet-1","productName":"Leaflet","counterparties":[]}`)
+
+			bed.mustInvoke("assembleProduct", `{"trackingID":"kit-1","productName":"Injection Kit","inputs":["syringe-1","vial-1"]}`)
+			bed.mustInvoke("assembleProduct", `{"trackingID":"box-1","productName":"Retail Box","inputs":["kit-1","leaflet-1"]}`)
+		})
+
+		g.It("should consume the inputs and link them to the assembled product", func() {
+			Expect(getProduct("syringe-1").ConsumedBy).To(Equal("kit-1"))
+			Expect(getProduct("kit-1").ConsumedBy).To(Equal("box-1"))
+			Expect(getProduct("kit-1").Inputs).To(Equal([]string{"syringe-1", "vial-1"}))
+			Expect(getProduct("box-1").Custodian).To(Equal(producer))
+		})
+
+		g.It("should trace what went into a product", func() {
+			Expect(trace("traceComponents", "box-1")).To(Equal([]TraceNode{
+				{TrackingID: "kit-1", Name: "Injection Kit", Via: "box-1", Depth: 1},
+				{TrackingID: "leaflet-1", Name: "Leaflet", Via: "box-1", Depth: 1},
+				{TrackingID: "syringe-1", Name: "Syringe", Lot: "S1", Via: "kit-1", Depth: 2},
+				{TrackingID: "vial-1", Name: "Vial", Lot: "V1", Via: "kit-1", Depth: 2},
+			}))
+		})
+
+		g.It("should trace where a component ended up", func() {
+			Expect(trace("traceProducts", "vial-1")).To(Equal([]TraceNode{
+				{TrackingID: "kit-1", Name: "Injection Kit", Via: "vial-1", Depth: 1},
+				{TrackingID: "box-1", Name: "Retail Box", Via: "kit-1", Depth: 2},
+			}))
+		})
+
+		g.It("should recall every finished good containing a recalled component", func() {
+			Expect(string(bed.mustInvoke("recallProduct", "vial-1"))).To(Equal(`{"recalled":["vial-1","kit-1","box-1"]}`))
+			Expect(getProduct("box-1").Recalled).To(BeTrue())
+			Expect(getProduct("syringe-1").Recalled).To(BeFalse())
+		})
+
+		g.It("should recall the portions split from a recalled bulk component and what they went into", func() {
+			createProduct(`{"trackingID":"saline-1","productName":"Saline","lot":"L7","quantity":100,"unit":"l","counterparties":[]}`)
+			bed.mustInvoke("splitProduct", "saline-1", "20", "saline-2")
+			bed.mustInvoke("assembleProduct", `{"trackingID":"kit-2","productName":"Injection Kit","inputs":["saline-2"]}`)
+
+			Expect(trace("traceComponents", "kit-2")).To(ContainElement(TraceNode{TrackingID: "saline-1", Name: "Saline", Lot: "L7", Via: "saline-2", Depth: 2}))
+
+			Expect(string(bed.mustInvoke("recallProduct", "saline-1"))).To(Equal(`{"recalled":["saline-1","saline-2","kit-2"]}`))
+			Expect(getProduct("kit-2").Recalled).To(BeTrue())
+			Expect(getProduct("box-1").Recalled).To(BeFalse())
+		})
+
+		g.It("should only let the manufacturer or the owner recall a product", func() {
+			//Org1 and Org2 each make vials and sell the title to the carrier
+			createProduct(`{"trackingID":"vial-2","productName":"Vial","counterparties":["` + carrier + `"]}`)
+			createProduct(`{"trackingID":"vial-4","productName":"Vial","counterparties":["` + carrier + `"]}`)
+			bed.as(testIdentity{mspID: "Org2MSP", certPath: org1Identity.certPath})
+			createProduct(`{"trackingID":"vial-3","productName":"Vial","counterparties":["` + carrier + `"]}`)
+			for _, trackingID := range []string{"vial-2", "vial-3", "vial-4"} {
+				bed.mustInvoke("transferOwnership", trackingID, carrier)
+			}
+
+			bed.as(org1Identity)
+			Expect(bed.invoke("recallProduct", "vial-2").Status).To(BeEquivalentTo(200))
+			Expect(bed.invoke("recallProduct", "vial-3").Status).To(BeEquivalentTo(403))
+			//a member of Org1 who was never a participant of the vial
+			bed.as(testIdentity{mspID: "Org1MSP", certPath: retailerIdentity.certPath})
+			Expect(bed.invoke("recallProduct", "vial-4").Status).To(BeEquivalentTo(404))
+			bed.as(org1Identity)
+			Expect(getProduct("vial-4").Recalled).To(BeFalse())
+		})
+
+		g.It("should not sell or assemble consumed products", func() {
+			Expect(bed.invoke("sellProduct", "syringe-1").Status).To(BeEquivalentTo(403))
+
+			response := bed.invoke("assembleProduct", `{"trackingID":"kit-2","productName":"Injection Kit","inputs":["syringe-1"]}`)
+			Expect(response.Status).To(BeEquivalentTo(403))
+			Expect(bed.invoke("getProduct", "kit-2").Status).To(BeEquivalentTo(404))
+		})
+
+		g.It("should return 403 if the identity cannot assemble products", func() {
+			bed.as(carrierIdentity)
+			response := bed.invoke("assembleProduct", `{"trackingID":"kit-2","productName":"Injection Kit","inputs":["box-1"]}`)
+
+			Expect(response.Status).To(BeEquivalentTo(403))
+		})
+	})
+}
@@ -10,7 +10,8 @@ import (
 	"github.com/hyperledger/fabric/protos/peer"
 )
 
-//updateState takes health and misc data and allows a user to update the trackingID
+//updateState takes health and misc data and allows a user to update the trackingID. A change of health must be
+//allowed by the health rules and carry a reason code.
 func (s *SmartContract) updateState(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	//get user identity
 	identity, err := GetInvokerIdentity(stub)
@@ -45,28 +46,31 @@ func (s *SmartContract) updateState(stub shim.ChaincodeStubInterface, args []str
 	//try to unmarshal as product
 	var productData Product
 	err = json.Unmarshal(existingsBytes, &productData)
-	//set new data
-	productData.Health = request.Health
-	productData.Metadata = request.Metadata
 
-	if !(identity.Cert.Subject.String() == productData.Custodian) {
-		return peer.Response{
-			Status:  403,
-			Message: fmt.Sprintf("You are not authorized to perform this transaction"),
+	if err == nil {
+		if !(identity.Cert.Subject.String() == productData.Custodian) {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("You are not authorized to perform this transaction"),
+			}
 		}
-	}
-
-	newBytes, _ = json.Marshal(productData)
-
-	if err != nil {
+		//set new data under the health rules of the manufacturer
+		rules, err := loadHealthRules(stub, productData.Manufacturer)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if response := changeHealth(rules, &productData.Health, &productData.HealthReason, request); response.Status != 200 {
+			return response
+		}
+		productData.Metadata = request.Metadata
+		newBytes, _ = json.Marshal(productData)
+	} else {
 		//retry with container
 		var containerData Container
 		err := json.Unmarshal(existingsBytes, &containerData)
 		if err != nil {
 			return shim.Error(err.Error())
 		}
-		containerData.Health = request.Health
-		containerData.Metadata = request.Metadata
 
 		//check is user is custodian
 		if !(identity.Cert.Subject.String() == containerData.Custodian) {
@@ -75,6 +79,14 @@ func (s *SmartContract) updateState(stub shim.ChaincodeStubInterface, args []str
 				Message: fmt.Sprintf("You are not authorized to perform this transaction"),
 			}
 		}
+		rules, err := loadHealthRules(stub, containerData.Organization)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if response := changeHealth(rules, &containerData.Health, &containerData.HealthReason, request); response.Status != 200 {
+			return response
+		}
+		containerData.Metadata = request.Metadata
 		newBytes, _ = json.Marshal(containerData)
 	}
 
@@ -87,6 +99,31 @@ func (s *SmartContract) updateState(stub shim.ChaincodeStubInterface, args []str
 	return shim.Success([]byte(args[0]))
 }
 
+//changeHealth applies the requested health and reason code if the health rules allow the transition, an empty
+//health keeps the current one
+func changeHealth(rules HealthRules, health *string, reason *string, request UpdateRequest) peer.Response {
+	//destroyed items are terminal, not even their misc data changes
+	if NormalizeHealth(*health) == HealthDestroyed {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Health %s is terminal", HealthDestroyed),
+		}
+	}
+	if request.Health == "" || request.Health == *health ||
+		(ValidHealth(request.Health) && NormalizeHealth(request.Health) == NormalizeHealth(*health)) {
+		return shim.Success(nil)
+	}
+	if err := rules.Transition(*health, request.Health, request.Reason); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	*health = request.Health
+	*reason = request.Reason
+	return shim.Success(nil)
+}
+
 //scan checks to see if state exists and whether it is owned by the current identity
 func (s *SmartContract) scan(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	//get user identity
@@ -98,19 +135,29 @@ func (s *SmartContract) scan(stub shim.ChaincodeStubInterface, args []string) pe
 	if len(args) != 1 {
 		return shim.Error("Incorrect number of arguments. Expecting 1")
 	}
+	//accept GS1 Digital Link URIs and element strings as trackingID
+	identifier, err := resolveIdentifier(stub, args[0])
+	if err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	trackingID := identifier.TrackingID
 
 	//get state by id as key
-	existingsBytes, _ := stub.GetState(args[0])
+	existingsBytes, _ := stub.GetState(trackingID)
 	var response map[string]interface{}
 	// return 404 is not found
 	if len(existingsBytes) == 0 {
 		response = map[string]interface{}{
 			"status": "new",
 		}
+		addIdentifier(response, identifier)
 		bytes, _ := json.Marshal(response)
 		return shim.Success(bytes)
 	}
-	var owner string
+	var owner, health string
 	var product Product
 	if err := json.Unmarshal(existingsBytes, &product); err != nil {
 		var container Container
@@ -118,11 +165,19 @@ func (s *SmartContract) scan(stub shim.ChaincodeStubInterface, args []string) pe
 			return shim.Error(err.Error())
 		}
 		owner = container.Custodian
+		health = container.Health
 
 	} else {
 		owner = product.Custodian
+		health = product.Health
 	}
-	if owner == identity.Cert.Subject.String() {
+	//destroyed items that show up again are flagged
+	if health == HealthDestroyed {
+		s.logger.Warningf("Destroyed item %s was scanned\n", trackingID)
+		response = map[string]interface{}{
+			"status": "destroyed",
+		}
+	} else if owner == identity.Cert.Subject.String() {
 		response = map[string]interface{}{
 			"status": "owned",
 		}
@@ -131,6 +186,7 @@ func (s *SmartContract) scan(stub shim.ChaincodeStubInterface, args []string) pe
 			"status": "unowned",
 		}
 	}
+	addIdentifier(response, identifier)
 	bytes, _ := json.Marshal(response)
 	return shim.Success(bytes)
 
@@ -158,9 +214,18 @@ func (s *SmartContract) getHistory(stub shim.ChaincodeStubInterface, args []stri
 	if len(args) != 1 {
 		return shim.Error("Incorrect number of arguments. Expecting 1")
 	}
+	//accept GS1 Digital Link URIs and element strings as trackingID
+	identifier, err := resolveIdentifier(stub, args[0])
+	if err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	trackingID := identifier.TrackingID
 
 	// Get iterator for all history entries
-	iterator, err := stub.GetHistoryForKey(args[0])
+	iterator, err := stub.GetHistoryForKey(trackingID)
 	if err != nil {
 		shim.Error(fmt.Sprintf("Error getting state iterator: %s", err))
 	}
@@ -201,3 +266,35 @@ func isInHistory(list []History, item History) bool {
 	}
 	return false
 }
+
+//addIdentifier adds the canonical trackingID, lot and expiry of a scanned GS1 identifier to a response
+func addIdentifier(response map[string]interface{}, identifier Identifier) {
+	if !identifier.GS1 {
+		return
+	}
+	response["trackingID"] = identifier.TrackingID
+	if identifier.Lot != "" {
+		response["lot"] = identifier.Lot
+	}
+	if identifier.Expiry != "" {
+		response["expiry"] = identifier.Expiry
+	}
+}
+
+// itemHasParticipant returns true if the product or container with the supplied trackingID exists and the supplied
+// subject is one of its participants
+func itemHasParticipant(stub shim.ChaincodeStubInterface, trackingID string, subject string) bool {
+	itemBytes, _ := stub.GetState(trackingID)
+	if len(itemBytes) == 0 {
+		return false
+	}
+	var product Product
+	if err := json.Unmarshal(itemBytes, &product); err == nil {
+		return product.HasParticipant(subject)
+	}
+	var container Container
+	if err := json.Unmarshal(itemBytes, &container); err != nil {
+		return false
+	}
+	return container.HasParticipant(subject)
+}
@@ -28,6 +28,10 @@ func (s *SmartContract) createContainer(stub shim.ChaincodeStubInterface, args [
 	if err := json.Unmarshal(argBytes, &request); err != nil {
 		return shim.Error(err.Error())
 	}
+	//Check the trackingID against the organizations identifier scheme
+	if response := validateTrackingID(stub, identity, request.ID, true); response.Status != shim.OK {
+		return response
+	}
 
 	//Check if product  state using id as key exsists
 	testContainerAsBytes, err := stub.GetState(request.ID)
@@ -42,19 +46,37 @@ func (s *SmartContract) createContainer(stub shim.ChaincodeStubInterface, args [
 		}
 	}
 
-	container := Container{
-		ID:           request.ID,
-		Type:         "container",
-		Health:       "",
-		Metadata:     request.Metadata,
-		Location:     request.Location,
-		ContainerID:  "",
-		Custodian:    identity.Cert.Subject.String(),
-		Timestamp:    int64(s.clock.Now().UTC().Unix()),
-		Contents:     []string{},
-		Participants: request.Participants,
+	//the container type must be defined before containers of it are created, by default by the organization creating
+	if request.ContainerType != "" && request.ContainerTypeOrganization == "" {
+		request.ContainerTypeOrganization = identity.Organization
+	}
+	if request.ContainerType != "" {
+		if _, err := getContainerType(stub, request.ContainerTypeOrganization, request.ContainerType); err != nil {
+			return peer.Response{
+				Status:  400,
+				Message: fmt.Sprintf("Error: %s ", err),
+			}
+		}
 	}
 
+	container := Container{
+		ID:                        request.ID,
+		Type:                      "container",
+		Health:                    "",
+		Metadata:                  request.Metadata,
+		Location:                  request.Location,
+		ContainerID:               "",
+		Custodian:                 identity.Cert.Subject.String(),
+		Owner:                     identity.Cert.Subject.String(),
+		Timestamp:                 int64(s.clock.Now().UTC().Unix()),
+		Contents:                  []string{},
+		Participants:              request.Participants,
+		ContainerType:             request.ContainerType,
+		ContainerTypeOrganization: request.ContainerTypeOrganization,
+		Organization:              identity.Organization,
+	}
+	container.CustodySince = container.Timestamp
+
 	container.Participants = append(container.Participants, identity.Cert.Subject.String())
 
 	// Put new Container onto blockchain
@@ -83,7 +105,32 @@ func (s *SmartContract) getAllContainer(stub shim.ChaincodeStubInterface, args [
 	if len(args) != 0 {
 		return shim.Error("Incorrect number of arguments. Expecting 0")
 	}
+	return listContainers(stub, identity, "", "")
+}
+
+//getContainersBy retrieves the containers owned or held by the supplied subject
+func (s *SmartContract) getContainersBy(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//Get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	if err := ValidateFilter(args[0]); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	return listContainers(stub, identity, args[0], args[1])
+}
 
+//listContainers lists the containers accessible by the identity, filtered by owner or custodian unless the
+//field is empty
+func listContainers(stub shim.ChaincodeStubInterface, identity *Identity, field string, subject string) peer.Response {
 	// Get iterator for all entries
 	iterator, err := stub.GetStateByRange("", "")
 	if err != nil {
@@ -106,7 +153,7 @@ func (s *SmartContract) getAllContainer(stub shim.ChaincodeStubInterface, args [
 		if err != nil && err.Error() != "Not a Container" {
 			return shim.Error(err.Error())
 		}
-		if container.AccessibleBy(identity) {
+		if container.AccessibleBy(identity) && (field == "" || container.HeldBy(field, subject)) {
 			if buffer.Len() != 1 {
 				buffer.WriteString(",")
 			}
@@ -129,9 +176,18 @@ func (s *SmartContract) getSingleContainer(stub shim.ChaincodeStubInterface, arg
 	if len(args) != 1 {
 		return shim.Error("Incorrect number of arguments. Expecting 1")
 	}
+	//accept GS1 Digital Link URIs and element strings as trackingID
+	identifier, err := resolveIdentifier(stub, args[0])
+	if err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	trackingID := identifier.TrackingID
 
 	//get single state using id as key
-	containerAsBytes, err := stub.GetState(args[0])
+	containerAsBytes, err := stub.GetState(trackingID)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
@@ -139,7 +195,7 @@ func (s *SmartContract) getSingleContainer(stub shim.ChaincodeStubInterface, arg
 	if len(containerAsBytes) == 0 {
 		return peer.Response{
 			Status:  404,
-			Message: fmt.Sprintf("Container %s Not Found", args[0]),
+			Message: fmt.Sprintf("Container %s Not Found", trackingID),
 		}
 	}
 
@@ -156,7 +212,7 @@ func (s *SmartContract) getSingleContainer(stub shim.ChaincodeStubInterface, arg
 	if !container.AccessibleBy(identity) {
 		return peer.Response{
 			Status:  404,
-			Message: fmt.Sprintf("Container %s Not Found", args[0]),
+			Message: fmt.Sprintf("Container %s Not Found", trackingID),
 		}
 	}
 	return shim.Success(containerAsBytes)
@@ -223,6 +279,21 @@ func (s *SmartContract) updateContainerCustodian(stub shim.ChaincodeStubInterfac
 			}
 		}
 	}
+	//the health of everything inside the container must allow the claim
+	containers, products, err := getContainerTree(stub, trackingID)
+	if err != nil {
+		return peer.Response{
+			Status:  404,
+			Message: err.Error(),
+		}
+	}
+	if response := checkHealth(stub, ActionClaim, containers, products); response.Status != 200 {
+		return response
+	}
+	//claims of a shipped container are checked against the planned route
+	if _, err := s.trackShipment(stub, container, newCustodian, newLocation); err != nil {
+		return shim.Error(err.Error())
+	}
 
 	//change custodian
 	//container.Custodian = newCustodian
@@ -238,12 +309,25 @@ func (s *SmartContract) updateContainerCustodian(stub shim.ChaincodeStubInterfac
 	//arguments for a container id
     //
 
+    //custody intervals completed by the claim of the container, packaged items dwell with their container
+    slasOf := dwellSLAsLoader(stub)
+    intervals := []Dwell{}
+
     var recup func(Container) peer.Response
     recup = func (container Container) peer.Response {
 
+        timestamp := int64(s.clock.Now().UTC().Unix())
+        if container.ContainerID == "" {
+            slas, err := slasOf(container.Organization)
+            if err != nil {
+                return shim.Error(err.Error())
+            }
+            intervals = append(intervals, NewDwell(container.ID, container.Custodian, container.CustodyStart(), timestamp, slas))
+        }
         container.Custodian = newCustodian
         container.Location = newLocation
-        container.Timestamp = int64(s.clock.Now().UTC().Unix())
+        container.Timestamp = timestamp
+        container.CustodySince = timestamp
 
             newBytes, _ := json.Marshal(container)
             if err := stub.PutState(container.ID, newBytes); err != nil {
@@ -272,9 +356,14 @@ func (s *SmartContract) updateContainerCustodian(stub shim.ChaincodeStubInterfac
                                                              //s.updateContainerCustodian(stub, []string{contentID, ""})
             }else if err == nil {
                 //claim product
+                seller := contentState.Custodian
                 contentState.Custodian = newCustodian
                 contentState.Location = newLocation
                 contentState.Timestamp = container.Timestamp
+                contentState.CustodySince = container.Timestamp
+                if err := recordTransactionInformation(stub, contentState, seller); err != nil {
+                    return shim.Error(err.Error())
+                }
                 newProductBytes, _ := json.Marshal(contentState)
                 if err := stub.PutState(contentID, newProductBytes); err != nil {
                     return shim.Error(err.Error())
@@ -287,7 +376,14 @@ func (s *SmartContract) updateContainerCustodian(stub shim.ChaincodeStubInterfac
         s.logger.Infof("Updated state: %s\n", trackingID)
         	return shim.Success([]byte(trackingID))
     }
-	return recup(container)
+	response := recup(container)
+	if response.Status != shim.OK {
+		return response
+	}
+	if err := recordCustodyIntervals(stub, intervals); err != nil {
+		return shim.Error(err.Error())
+	}
+	return response
 }
 
 //packageItem takes product/container and updates its containerID and takes a container and adds to its contents list
@@ -298,13 +394,15 @@ func (s *SmartContract) packageItem(stub shim.ChaincodeStubInterface, args []str
 		shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
 	}
 
-	if len(args) != 2 {
-		return shim.Error("Incorrect number of arguments. Expecting 2")
+	if len(args) != 2 && len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 2 or 4")
 	}
 
 	//get ids for contents and contents
 	containerID := args[0]
 	contentID := args[1]
+	packagedID := contentID
+	var updatedSplitBytes []byte
 
     if (containerID == contentID) {
            return peer.Response{
@@ -341,6 +439,12 @@ func (s *SmartContract) packageItem(stub shim.ChaincodeStubInterface, args []str
         if err != nil {
             return shim.Error(err.Error())
         }
+        if len(args) == 4 {
+            return peer.Response{
+                Status:  400,
+                Message: fmt.Sprintf("Error: container %s does not carry a quantity and cannot be split ", contentID),
+            }
+        }
         if !(contentContainer.ContainerID == "") {
             return peer.Response{
                 Status:  403,
@@ -377,6 +481,28 @@ func (s *SmartContract) packageItem(stub shim.ChaincodeStubInterface, args []str
                 Message: fmt.Sprintf("You are not authorized to perform this transaction as containerID is not empty for product"),
             }
         }
+        if contentProduct.ConsumedBy != "" {
+            return peer.Response{
+                Status:  403,
+                Message: fmt.Sprintf("Product %s was consumed by %s and cannot be packaged", contentID, contentProduct.ConsumedBy),
+            }
+        }
+        if contentProduct.Expired(s.clock.Now()) {
+            return peer.Response{
+                Status:  403,
+                Message: fmt.Sprintf("Product %s expired on %s and cannot be packaged", contentID, contentProduct.Expiry),
+            }
+        }
+        //package part of a bulk product by splitting the packaged quantity off first
+        if len(args) == 4 {
+            split, response := s.splitQuantity(stub, identity, &contentProduct, args[2], args[3])
+            if response.Status != shim.OK {
+                return response
+            }
+            updatedSplitBytes, _ = json.Marshal(contentProduct)
+            contentProduct = split
+            packagedID = split.ID
+        }
         //set new data
         contentProduct.ContainerID = containerID
 
@@ -389,7 +515,23 @@ func (s *SmartContract) packageItem(stub shim.ChaincodeStubInterface, args []str
 	if err := json.Unmarshal(containerBytes, &container); err != nil {
 		return shim.Error(err.Error())
 	}
-	container.Contents = append(container.Contents, contentID)
+	//the health of the container and everything packaged into it must allow packaging
+	containers, products, err := getContainerTree(stub, contentID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if response := checkHealth(stub, ActionPackage, append(containers, container), products); response.Status != 200 {
+		return response
+	}
+	//the container type and packing rules must allow packaging, a split is packed by its own quantity
+	added := NewPackedItem(contentID, containers, products)
+	if packagedID != contentID {
+		added = NewPackedItem(packagedID, nil, []Product{contentProduct})
+	}
+	if response := checkPacking(stub, identity.Organization, container, added); response.Status != shim.OK {
+		return response
+	}
+	container.Contents = append(container.Contents, packagedID)
 	if !(identity.Cert.Subject.String() == container.Custodian) {
 		return peer.Response{
 			Status:  403,
@@ -401,7 +543,12 @@ func (s *SmartContract) packageItem(stub shim.ChaincodeStubInterface, args []str
 	if err := stub.PutState(containerID, updatedContainerBytes); err != nil {
 		return shim.Error(err.Error())
 	}
-	if err := stub.PutState(contentID, updatedContentBytes); err != nil {
+	if updatedSplitBytes != nil {
+		if err := stub.PutState(contentID, updatedSplitBytes); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	if err := stub.PutState(packagedID, updatedContentBytes); err != nil {
 		return shim.Error(err.Error())
 	}
 
@@ -501,6 +648,16 @@ func (s *SmartContract) unpackageItem(stub shim.ChaincodeStubInterface, args []s
 			Message: fmt.Sprintf("You are not authorized to perform this transaction as cert.subject.string doesn't equal custodian for container while unpackaging"),
 		}
 	}
+	rules, err := loadHealthRules(stub, container.Organization)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := rules.Allows(container.Health, ActionUnpackage); err != nil {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Container %s: %s", containerID, err),
+		}
+	}
 	container.Remove(contentID)
 	updatedContainerBytes, _ := json.Marshal(container)
 
@@ -513,3 +670,46 @@ func (s *SmartContract) unpackageItem(stub shim.ChaincodeStubInterface, args []s
 	return shim.Success([]byte(containerID))
 
 }
+
+//getContainerTree returns the container with the supplied trackingID and every product and container nested inside it
+func getContainerTree(stub shim.ChaincodeStubInterface, trackingID string) ([]Container, []Product, error) {
+	var containers []Container
+	var products []Product
+	visited := map[string]bool{}
+	var walk func(id string) error
+	walk = func(id string) error {
+		if visited[id] {
+			return nil
+		}
+		visited[id] = true
+		itemBytes, err := stub.GetState(id)
+		if err != nil {
+			return err
+		}
+		if len(itemBytes) == 0 {
+			return fmt.Errorf("Content tracking id %s is invalid.", id)
+		}
+		var product Product
+		err = json.Unmarshal(itemBytes, &product)
+		if err == nil {
+			products = append(products, product)
+			return nil
+		}
+		if err.Error() != "Not a Product" {
+			return err
+		}
+		var container Container
+		if err := json.Unmarshal(itemBytes, &container); err != nil {
+			return err
+		}
+		containers = append(containers, container)
+		for _, contentID := range container.Contents {
+			if err := walk(contentID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := walk(trackingID)
+	return containers, products, err
+}
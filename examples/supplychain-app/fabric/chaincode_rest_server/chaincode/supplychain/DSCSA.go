@@ -0,0 +1,456 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// transactionInformationKey is the composite key object type DSCSA transaction information is stored under, by
+// trackingID and transactionID
+const transactionInformationKey = "dscsaTransaction"
+
+// verificationKey is the composite key object type verification requests are stored under
+const verificationKey = "verification"
+
+// investigationKey is the composite key object type suspect product investigations are stored under, by trackingID
+const investigationKey = "investigation"
+
+// getTransactionInformation retrieves the DSCSA transaction information and statements of a prescription product
+// the current user participates in
+func (s *SmartContract) getTransactionInformation(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	trackingID := args[0]
+
+	if !itemHasParticipant(stub, trackingID, identity.Cert.Subject.String()) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Product %s Not Found", trackingID),
+		}
+	}
+	iterator, err := stub.GetStateByPartialCompositeKey(transactionInformationKey, []string{trackingID})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer iterator.Close()
+	transactions := []TransactionInformation{}
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		var transaction TransactionInformation
+		if err := json.Unmarshal(state.Value, &transaction); err != nil {
+			return shim.Error(err.Error())
+		}
+		transactions = append(transactions, transaction)
+	}
+	transactionsBytes, _ := json.Marshal(transactions)
+	return shim.Success(transactionsBytes)
+}
+
+// verificationRequest asks the manufacturer of a product to verify its SGTIN, lot and expiry. The lot and expiry
+// are taken from a GS1 identifier or passed as second and third argument. Products created before their manufacturer
+// was recorded are verified by their first custodian, who created them.
+func (s *SmartContract) verificationRequest(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 && len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 or 3")
+	}
+	//accept GS1 Digital Link URIs and element strings as trackingID
+	identifier, err := resolveIdentifier(stub, args[0])
+	if err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	if len(args) == 3 {
+		identifier.Lot, identifier.Expiry = args[1], args[2]
+	}
+	if strings.TrimSpace(identifier.Lot) == "" || strings.TrimSpace(identifier.Expiry) == "" {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: a lot and expiry are required to verify %s ", identifier.TrackingID),
+		}
+	}
+
+	request := VerificationRequest{
+		Type:       verificationKey,
+		ID:         stub.GetTxID(),
+		TrackingID: identifier.TrackingID,
+		Lot:        identifier.Lot,
+		Expiry:     identifier.Expiry,
+		Requester:  identity.Cert.Subject.String(),
+		Status:     VerificationPending,
+		Timestamp:  int64(s.clock.Now().UTC().Unix()),
+	}
+	product, err := getProduct(stub, identifier.TrackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if product != nil && product.Manufacturer == "" {
+		history, err := getCustodyHistory(stub, product.ID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if len(history) != 0 {
+			request.Creator = history[0].Custodian
+		}
+	}
+	//unknown products have no manufacturer to answer and are not verified right away
+	if product == nil || (product.Manufacturer == "" && request.Creator == "") {
+		request.Verify(nil)
+		request.RespondedAt = request.Timestamp
+	} else {
+		request.Manufacturer = product.Manufacturer
+	}
+	if err := putVerificationRequest(stub, request); err != nil {
+		return shim.Error(err.Error())
+	}
+	requestBytes, _ := json.Marshal(request)
+	return shim.Success(requestBytes)
+}
+
+// respondVerification answers a pending verification request against the product on the ledger, only members of
+// the manufacturer organization or the creator of a product without a recorded manufacturer can respond
+func (s *SmartContract) respondVerification(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	request, response := getPartyVerificationRequest(stub, identity, args[0])
+	if request == nil {
+		return response
+	}
+	if !request.RespondableBy(identity) {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, only the manufacturer can respond"),
+		}
+	}
+	if request.Status != VerificationPending {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Verification request %s was already answered", request.ID),
+		}
+	}
+
+	product, err := getProduct(stub, request.TrackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	request.Verify(product)
+	request.Responder = identity.Cert.Subject.String()
+	request.RespondedAt = int64(s.clock.Now().UTC().Unix())
+	if err := putVerificationRequest(stub, *request); err != nil {
+		return shim.Error(err.Error())
+	}
+	requestBytes, _ := json.Marshal(request)
+	return shim.Success(requestBytes)
+}
+
+// getSingleVerificationRequest retrieves a verification request for its requester or the manufacturer
+func (s *SmartContract) getSingleVerificationRequest(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	request, response := getPartyVerificationRequest(stub, identity, args[0])
+	if request == nil {
+		return response
+	}
+	requestBytes, _ := json.Marshal(request)
+	return shim.Success(requestBytes)
+}
+
+// reportSuspectProduct quarantines a product the current user participates in as suspect and notifies its
+// participants through a suspectProduct event
+func (s *SmartContract) reportSuspectProduct(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	trackingID, reason := args[0], args[1]
+	if strings.TrimSpace(reason) == "" {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: a reason is required "),
+		}
+	}
+
+	product, err := getProduct(stub, trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if product == nil || !product.AccessibleBy(identity) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Product %s Not Found", trackingID),
+		}
+	}
+	investigation, err := getInvestigation(stub, trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if investigation != nil && investigation.Status != InvestigationCleared {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Product %s is already %s", trackingID, investigation.Status),
+		}
+	}
+	rules, err := loadHealthRules(stub, product.Manufacturer)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if NormalizeHealth(product.Health) != HealthQuarantined && !rules.CanChange(product.Health, HealthQuarantined) {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Product %s cannot be quarantined from %s", trackingID, NormalizeHealth(product.Health)),
+		}
+	}
+
+	timestamp := int64(s.clock.Now().UTC().Unix())
+	investigation = &Investigation{
+		Type:              investigationKey,
+		TrackingID:        trackingID,
+		Status:            InvestigationSuspect,
+		Reason:            reason,
+		Reporter:          identity.Cert.Subject.String(),
+		Manufacturer:      product.Manufacturer,
+		PriorHealth:       product.Health,
+		PriorHealthReason: product.HealthReason,
+		Notified:          product.Participants,
+		Timestamp:         timestamp,
+	}
+	product.Health = HealthQuarantined
+	product.HealthReason = ReasonSuspectProduct
+	product.Timestamp = timestamp
+	if response := putProducts(stub, *product); response.Status != shim.OK {
+		return response
+	}
+	investigationBytes, err := putInvestigation(stub, *investigation)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := stub.SetEvent("suspectProduct", investigationBytes); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	s.logger.Warningf("Product %s reported suspect: %s\n", trackingID, reason)
+	return shim.Success(investigationBytes)
+}
+
+// concludeInvestigation clears a suspect product or marks it illegitimate, only members of the manufacturer
+// organization can conclude. Cleared products get back the health they had before they were reported, illegitimate
+// ones stay quarantined and their participants are notified through an illegitimateProduct event.
+func (s *SmartContract) concludeInvestigation(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 2 or 3")
+	}
+	trackingID, status := args[0], args[1]
+	var note string
+	if len(args) == 3 {
+		note = args[2]
+	}
+
+	investigation, err := getInvestigation(stub, trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	product, err := getProduct(stub, trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if investigation == nil || product == nil || !product.AccessibleBy(identity) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Investigation of %s Not Found", trackingID),
+		}
+	}
+	if investigation.Manufacturer != identity.Organization {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, only the manufacturer can conclude"),
+		}
+	}
+	if investigation.Status != InvestigationSuspect {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Investigation of %s is already %s", trackingID, investigation.Status),
+		}
+	}
+	timestamp := int64(s.clock.Now().UTC().Unix())
+	if err := investigation.Conclude(status, note, timestamp); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+
+	if status == InvestigationCleared {
+		product.Health = investigation.PriorHealth
+		product.HealthReason = investigation.PriorHealthReason
+	} else {
+		product.HealthReason = ReasonIllegitimateProduct
+	}
+	product.Timestamp = timestamp
+	if response := putProducts(stub, *product); response.Status != shim.OK {
+		return response
+	}
+	investigationBytes, err := putInvestigation(stub, *investigation)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if status == InvestigationIllegitimate {
+		if err := stub.SetEvent("illegitimateProduct", investigationBytes); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	s.logger.Infof("Investigation of %s concluded %s\n", trackingID, status)
+	return shim.Success(investigationBytes)
+}
+
+// getSingleInvestigation retrieves the investigation of a suspect product the current user participates in
+func (s *SmartContract) getSingleInvestigation(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	trackingID := args[0]
+
+	investigation, err := getInvestigation(stub, trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if investigation == nil || !itemHasParticipant(stub, trackingID, identity.Cert.Subject.String()) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Investigation of %s Not Found", trackingID),
+		}
+	}
+	investigationBytes, _ := json.Marshal(investigation)
+	return shim.Success(investigationBytes)
+}
+
+// recordTransactionInformation records the transaction information and statement of a prescription product handed
+// over by the seller to its current custodian, other products are not recorded
+func recordTransactionInformation(stub shim.ChaincodeStubInterface, product Product, seller string) error {
+	if !product.Prescription {
+		return nil
+	}
+	transaction := NewTransactionInformation(stub.GetTxID(), product, seller, product.Timestamp)
+	key, _ := stub.CreateCompositeKey(transactionInformationKey, []string{product.ID, transaction.ID})
+	transactionBytes, _ := json.Marshal(transaction)
+	return stub.PutState(key, transactionBytes)
+}
+
+// getProduct returns the product with the supplied trackingID or nil if there is none
+func getProduct(stub shim.ChaincodeStubInterface, trackingID string) (*Product, error) {
+	productBytes, err := stub.GetState(trackingID)
+	if err != nil {
+		return nil, err
+	}
+	var product Product
+	if len(productBytes) == 0 || json.Unmarshal(productBytes, &product) != nil {
+		return nil, nil
+	}
+	return &product, nil
+}
+
+// getPartyVerificationRequest returns a 404 response if the verification request does not exist or the identity is
+// neither its requester nor able to respond to it
+func getPartyVerificationRequest(stub shim.ChaincodeStubInterface, identity *Identity, requestID string) (*VerificationRequest, peer.Response) {
+	key, _ := stub.CreateCompositeKey(verificationKey, []string{requestID})
+	requestBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, shim.Error(err.Error())
+	}
+	var request VerificationRequest
+	if len(requestBytes) == 0 || json.Unmarshal(requestBytes, &request) != nil ||
+		(request.Requester != identity.Cert.Subject.String() && !request.RespondableBy(identity)) {
+		return nil, peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Verification request %s Not Found", requestID),
+		}
+	}
+	return &request, shim.Success(nil)
+}
+
+// putVerificationRequest writes a verification request to the ledger
+func putVerificationRequest(stub shim.ChaincodeStubInterface, request VerificationRequest) error {
+	key, _ := stub.CreateCompositeKey(verificationKey, []string{request.ID})
+	requestBytes, _ := json.Marshal(request)
+	return stub.PutState(key, requestBytes)
+}
+
+// getInvestigation returns the investigation of a product or nil if it was never reported suspect
+func getInvestigation(stub shim.ChaincodeStubInterface, trackingID string) (*Investigation, error) {
+	key, _ := stub.CreateCompositeKey(investigationKey, []string{trackingID})
+	investigationBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(investigationBytes) == 0 {
+		return nil, nil
+	}
+	var investigation Investigation
+	if err := json.Unmarshal(investigationBytes, &investigation); err != nil {
+		return nil, err
+	}
+	return &investigation, nil
+}
+
+// putInvestigation writes an investigation to the ledger and returns it as written
+func putInvestigation(stub shim.ChaincodeStubInterface, investigation Investigation) ([]byte, error) {
+	key, _ := stub.CreateCompositeKey(investigationKey, []string{investigation.TrackingID})
+	investigationBytes, _ := json.Marshal(investigation)
+	return investigationBytes, stub.PutState(key, investigationBytes)
+}
@@ -0,0 +1,185 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestDSCSA(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	now := time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC)
+	producer := org1Identity.subject()
+	pharmacy := carrierIdentity.subject()
+
+	//createProduct has Org1 make insulin with the pharmacy as counterparty and continues as the pharmacy
+	createProduct := func(prescription bool) {
+		bed.as(org1Identity)
+		bed.mustInvoke("createProduct", fmt.Sprintf(`{"trackingID":"insulin-1","productName":"Insulin Glargine","lot":"L42","expiry":"2020-06-30","prescription":%t,"counterparties":["%s"]}`, prescription, pharmacy))
+		bed.as(carrierIdentity)
+	}
+	getProduct := func() Product {
+		var product Product
+		json.Unmarshal(bed.mustInvoke("getProduct", "insulin-1"), &product)
+		return product
+	}
+	requestVerification := func(args ...string) VerificationRequest {
+		var request VerificationRequest
+		json.Unmarshal(bed.mustInvoke("verificationRequest", args...), &request)
+		return request
+	}
+	respondVerification := func(id string) VerificationRequest {
+		var request VerificationRequest
+		json.Unmarshal(bed.mustInvoke("respondVerification", id), &request)
+		return request
+	}
+	nextEvent := func() string {
+		select {
+		case event := <-bed.stub.ChaincodeEventsChannel:
+			return event.EventName
+		default:
+			return ""
+		}
+	}
+
+	g.Describe("Transaction Information", func() {
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, now)
+		})
+
+		g.It("should record the transaction information and statement of a prescription product", func() {
+			createProduct(true)
+			bed.mustInvoke("claimProduct", "insulin-1", "London")
+			claimID := fmt.Sprintf("tx%d", bed.tx)
+
+			var transactions []TransactionInformation
+			json.Unmarshal(bed.mustInvoke("getTransactionInformation", "insulin-1"), &transactions)
+			Expect(transactions).To(HaveLen(1))
+			Expect(transactions[0].ID).To(Equal(claimID))
+			Expect(transactions[0].Seller).To(Equal(producer))
+			Expect(transactions[0].Buyer).To(Equal(pharmacy))
+			Expect(transactions[0].Lot).To(Equal("L42"))
+			Expect(transactions[0].Quantity).To(BeEquivalentTo(1))
+			Expect(transactions[0].Statement).To(Equal(TransactionStatement))
+		})
+
+		g.It("should not record other products", func() {
+			createProduct(false)
+			bed.mustInvoke("claimProduct", "insulin-1", "London")
+			Expect(string(bed.mustInvoke("getTransactionInformation", "insulin-1"))).To(Equal("[]"))
+		})
+	})
+
+	g.Describe("Verification Requests", func() {
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, now)
+			createProduct(true)
+		})
+
+		g.It("should be answered by the manufacturer", func() {
+			id := requestVerification("insulin-1", "L42", "2020-06-30").ID
+			Expect(bed.invoke("respondVerification", id).Status).To(BeEquivalentTo(403))
+
+			bed.as(org1Identity)
+			request := respondVerification(id)
+			Expect(request.Status).To(Equal(VerificationVerified))
+			Expect(request.Responder).To(Equal(producer))
+
+			bed.as(carrierIdentity)
+			json.Unmarshal(bed.mustInvoke("getVerificationRequest", id), &request)
+			Expect(request.Status).To(Equal(VerificationVerified))
+		})
+
+		g.It("should not verify a mismatching lot or an unknown product", func() {
+			id := requestVerification("insulin-1", "L43", "2020-06-30").ID
+			bed.as(org1Identity)
+			Expect(respondVerification(id).Status).To(Equal(VerificationNotVerified))
+
+			Expect(requestVerification("insulin-9", "L42", "2020-06-30").Status).To(Equal(VerificationNotVerified))
+		})
+
+		g.It("should return 400 without a lot and expiry", func() {
+			Expect(bed.invoke("verificationRequest", "insulin-1").Status).To(BeEquivalentTo(400))
+		})
+	})
+
+	g.Describe("Products without a manufacturer", func() {
+		g.BeforeEach(func() {
+			bed = newLedgerTestbed(now)
+			bed.as(org1Identity)
+			bed.mustInvoke("createProduct", `{"trackingID":"insulin-2","productName":"Insulin Glargine","lot":"L42","expiry":"2020-06-30","lastScannedAt":"Zurich","counterparties":["`+pharmacy+`"]}`)
+			//drop the manufacturer as products created before it was recorded lack it
+			var product Product
+			json.Unmarshal(bed.stub.State["insulin-2"], &product)
+			product.Manufacturer = ""
+			productBytes, _ := json.Marshal(product)
+			bed.stub.State["insulin-2"] = productBytes
+		})
+
+		g.It("should be answered by the creator of the product", func() {
+			bed.as(carrierIdentity)
+			request := requestVerification("insulin-2", "L42", "2020-06-30")
+			Expect(request.Status).To(Equal(VerificationPending))
+			Expect(request.Creator).To(Equal(producer))
+
+			bed.as(testIdentity{mspID: "Org2MSP", certPath: org1Identity.certPath})
+			Expect(respondVerification(request.ID).Status).To(Equal(VerificationVerified))
+		})
+	})
+
+	g.Describe("Suspect Products", func() {
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, now)
+			createProduct(true)
+		})
+
+		g.It("should quarantine a suspect product and notify its participants", func() {
+			var investigation Investigation
+			json.Unmarshal(bed.mustInvoke("reportSuspectProduct", "insulin-1", "damaged seal"), &investigation)
+			Expect(nextEvent()).To(Equal("suspectProduct"))
+			Expect(getProduct().Health).To(Equal(HealthQuarantined))
+			Expect(getProduct().HealthReason).To(Equal(ReasonSuspectProduct))
+			Expect(investigation.Notified).To(Equal([]string{pharmacy, producer}))
+
+			Expect(bed.invoke("reportSuspectProduct", "insulin-1", "damaged seal").Status).To(BeEquivalentTo(403))
+		})
+
+		g.It("should let the manufacturer mark a suspect product illegitimate", func() {
+			bed.mustInvoke("reportSuspectProduct", "insulin-1", "damaged seal")
+			nextEvent()
+			Expect(bed.invoke("concludeInvestigation", "insulin-1", InvestigationCleared).Status).To(BeEquivalentTo(403))
+
+			bed.as(org1Identity)
+			Expect(bed.invoke("concludeInvestigation", "insulin-1", InvestigationIllegitimate).Status).To(BeEquivalentTo(400))
+			bed.mustInvoke("concludeInvestigation", "insulin-1", InvestigationIllegitimate, "counterfeit")
+			Expect(nextEvent()).To(Equal("illegitimateProduct"))
+			Expect(getProduct().Health).To(Equal(HealthQuarantined))
+			Expect(getProduct().HealthReason).To(Equal(ReasonIllegitimateProduct))
+		})
+
+		g.It("should release a cleared product from quarantine to the health it had before", func() {
+			bed.as(org1Identity)
+			bed.mustInvoke("updateState", "insulin-1", `{"trackingID":"insulin-1","health":"damaged","reason":"physical_damage"}`)
+			bed.as(carrierIdentity)
+			bed.mustInvoke("reportSuspectProduct", "insulin-1", "damaged seal")
+			bed.as(org1Identity)
+			bed.mustInvoke("concludeInvestigation", "insulin-1", InvestigationCleared)
+			Expect(getProduct().Health).To(Equal(HealthDamaged))
+			Expect(getProduct().HealthReason).To(Equal(ReasonPhysicalDamage))
+
+			var investigation Investigation
+			json.Unmarshal(bed.mustInvoke("getInvestigation", "insulin-1"), &investigation)
+			Expect(investigation.Status).To(Equal(InvestigationCleared))
+		})
+	})
+}
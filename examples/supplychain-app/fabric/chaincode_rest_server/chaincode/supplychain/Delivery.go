@@ -0,0 +1,346 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// deliveryKey is the composite key object type proofs of delivery are stored under, by containerID and txID
+const deliveryKey = "delivery"
+
+// discrepancyKey is the composite key object type discrepancies are stored under
+const discrepancyKey = "discrepancy"
+
+// confirmDelivery signs off the delivery of a container held by the current user, comparing its expected contents
+// with the items scanned on arrival and opening a discrepancy for missing, extra or damaged items
+func (s *SmartContract) confirmDelivery(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	containerID := args[0]
+
+	var request DeliveryRequest
+	if err := json.Unmarshal([]byte(args[1]), &request); err != nil {
+		return shim.Error(err.Error())
+	}
+	request.SignatureHash = strings.ToLower(request.SignatureHash)
+	if err := request.Validate(); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+
+	containerBytes, _ := stub.GetState(containerID)
+	var container Container
+	if len(containerBytes) == 0 || json.Unmarshal(containerBytes, &container) != nil {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Container %s Not Found", containerID),
+		}
+	}
+	receiver := identity.Cert.Subject.String()
+	if receiver != container.Custodian {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, container not held by identity"),
+		}
+	}
+
+	//the sender is the shipper of a shipped container, or a participant named by the receiver
+	timestamp := int64(s.clock.Now().UTC().Unix())
+	sender := request.Sender
+	poID := container.PurchaseOrder
+	if container.ShipmentID != "" {
+		shipment, err := getShipment(stub, container.ShipmentID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if shipment != nil {
+			sender = shipment.Shipper
+			if poID == "" {
+				poID = shipment.PurchaseOrder
+			}
+			if shipment.ArrivedAt == 0 {
+				shipment.ArrivedAt = timestamp
+				if err := putShipment(stub, *shipment); err != nil {
+					return shim.Error(err.Error())
+				}
+			}
+		}
+	}
+	if sender == "" || sender == receiver || !container.HasParticipant(sender) {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: sender must be another participant of container %s ", containerID),
+		}
+	}
+
+	delivery := Delivery{
+		Type:          deliveryKey,
+		ID:            stub.GetTxID(),
+		ContainerID:   containerID,
+		ShipmentID:    container.ShipmentID,
+		Sender:        sender,
+		Receiver:      receiver,
+		Expected:      append([]string{}, container.Contents...),
+		Received:      append([]string{}, request.Scanned...),
+		Damaged:       append([]string{}, request.Damaged...),
+		Notes:         request.Notes,
+		SignatureHash: request.SignatureHash,
+		Timestamp:     timestamp,
+	}
+	delivery.Reconcile()
+
+	//damaged contents are marked damaged where the health rules allow it
+	for _, id := range delivery.DamagedContents() {
+		if err := markDamaged(stub, id, timestamp); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	if delivery.Discrepant() {
+		discrepancy := Discrepancy{
+			Type:        discrepancyKey,
+			ID:          delivery.ID,
+			DeliveryID:  delivery.ID,
+			ContainerID: containerID,
+			Parties:     []string{sender, receiver},
+			Missing:     delivery.Missing,
+			Extra:       delivery.Extra,
+			Damaged:     delivery.Damaged,
+			Status:      DiscrepancyOpen,
+			Comments:    []Comment{},
+			Accepted:    []string{},
+			Timestamp:   timestamp,
+		}
+		if err := putDiscrepancy(stub, discrepancy); err != nil {
+			return shim.Error(err.Error())
+		}
+		delivery.DiscrepancyID = discrepancy.ID
+	}
+
+	//deliveries against a purchase order are matched with the order and the shipped contents
+	if poID != "" {
+		if err := s.matchPurchaseOrder(stub, poID, container, &delivery); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	key, _ := stub.CreateCompositeKey(deliveryKey, []string{containerID, delivery.ID})
+	deliveryBytes, _ := json.Marshal(delivery)
+	if err := stub.PutState(key, deliveryBytes); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	s.logger.Infof("Confirmed delivery of %s, %d missing, %d extra, %d damaged\n", containerID, len(delivery.Missing), len(delivery.Extra), len(delivery.Damaged))
+	return shim.Success(deliveryBytes)
+}
+
+// getDeliveries retrieves every proof of delivery of a container
+func (s *SmartContract) getDeliveries(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	containerID := args[0]
+
+	if !itemHasParticipant(stub, containerID, identity.Cert.Subject.String()) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Item with trackingID %s not found", containerID),
+		}
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(deliveryKey, []string{containerID})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer iterator.Close()
+	deliveries := []Delivery{}
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		var delivery Delivery
+		if err := json.Unmarshal(state.Value, &delivery); err != nil {
+			return shim.Error(err.Error())
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	deliveriesBytes, _ := json.Marshal(deliveries)
+	return shim.Success(deliveriesBytes)
+}
+
+// commentDiscrepancy adds a comment of the sender or receiver to an open discrepancy
+func (s *SmartContract) commentDiscrepancy(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	if strings.TrimSpace(args[1]) == "" {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: comment is empty "),
+		}
+	}
+
+	discrepancy, response := getPartyDiscrepancy(stub, identity, args[0])
+	if discrepancy == nil {
+		return response
+	}
+	if discrepancy.Status != DiscrepancyOpen {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Discrepancy %s is already resolved", discrepancy.ID),
+		}
+	}
+	discrepancy.Comments = append(discrepancy.Comments, Comment{
+		Author:    identity.Cert.Subject.String(),
+		Text:      args[1],
+		Timestamp: int64(s.clock.Now().UTC().Unix()),
+	})
+	if err := putDiscrepancy(stub, *discrepancy); err != nil {
+		return shim.Error(err.Error())
+	}
+	discrepancyBytes, _ := json.Marshal(discrepancy)
+	return shim.Success(discrepancyBytes)
+}
+
+// resolveDiscrepancy records that the current user accepts the resolution of a discrepancy, it is resolved once both
+// the sender and receiver accepted
+func (s *SmartContract) resolveDiscrepancy(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	discrepancy, response := getPartyDiscrepancy(stub, identity, args[0])
+	if discrepancy == nil {
+		return response
+	}
+	if err := discrepancy.Accept(identity.Cert.Subject.String()); err != nil {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Discrepancy %s: %s", discrepancy.ID, err),
+		}
+	}
+	if err := putDiscrepancy(stub, *discrepancy); err != nil {
+		return shim.Error(err.Error())
+	}
+	discrepancyBytes, _ := json.Marshal(discrepancy)
+
+	s.logger.Infof("Discrepancy %s is %s\n", discrepancy.ID, discrepancy.Status)
+	return shim.Success(discrepancyBytes)
+}
+
+// getSingleDiscrepancy retrieves a discrepancy with its comments by discrepancyID
+func (s *SmartContract) getSingleDiscrepancy(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	discrepancy, response := getPartyDiscrepancy(stub, identity, args[0])
+	if discrepancy == nil {
+		return response
+	}
+	discrepancyBytes, _ := json.Marshal(discrepancy)
+	return shim.Success(discrepancyBytes)
+}
+
+// markDamaged sets the health of a delivered product or container to damaged if the health rules of its
+// manufacturer allow the change
+func markDamaged(stub shim.ChaincodeStubInterface, trackingID string, timestamp int64) error {
+	itemBytes, err := stub.GetState(trackingID)
+	if err != nil || len(itemBytes) == 0 {
+		return err
+	}
+	var product Product
+	if err := json.Unmarshal(itemBytes, &product); err == nil {
+		rules, err := loadHealthRules(stub, product.Manufacturer)
+		if err != nil {
+			return err
+		}
+		if !rules.CanChange(product.Health, HealthDamaged) {
+			return nil
+		}
+		product.Health = HealthDamaged
+		product.HealthReason = ReasonPhysicalDamage
+		product.Timestamp = timestamp
+		itemBytes, _ = json.Marshal(product)
+	} else {
+		var container Container
+		if err := json.Unmarshal(itemBytes, &container); err != nil {
+			return err
+		}
+		rules, err := loadHealthRules(stub, container.Organization)
+		if err != nil {
+			return err
+		}
+		if !rules.CanChange(container.Health, HealthDamaged) {
+			return nil
+		}
+		container.Health = HealthDamaged
+		container.HealthReason = ReasonPhysicalDamage
+		container.Timestamp = timestamp
+		itemBytes, _ = json.Marshal(container)
+	}
+	return stub.PutState(trackingID, itemBytes)
+}
+
+// getPartyDiscrepancy returns a 404 response if the discrepancy does not exist or the identity is not a party to it
+func getPartyDiscrepancy(stub shim.ChaincodeStubInterface, identity *Identity, discrepancyID string) (*Discrepancy, peer.Response) {
+	key, _ := stub.CreateCompositeKey(discrepancyKey, []string{discrepancyID})
+	discrepancyBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, shim.Error(err.Error())
+	}
+	var discrepancy Discrepancy
+	if len(discrepancyBytes) == 0 || json.Unmarshal(discrepancyBytes, &discrepancy) != nil || !discrepancy.AccessibleBy(identity) {
+		return nil, peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Discrepancy %s Not Found", discrepancyID),
+		}
+	}
+	return &discrepancy, shim.Success(nil)
+}
+
+func putDiscrepancy(stub shim.ChaincodeStubInterface, discrepancy Discrepancy) error {
+	key, _ := stub.CreateCompositeKey(discrepancyKey, []string{discrepancy.ID})
+	discrepancyBytes, _ := json.Marshal(discrepancy)
+	return stub.PutState(key, discrepancyBytes)
+}
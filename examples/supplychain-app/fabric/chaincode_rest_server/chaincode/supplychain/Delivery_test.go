@@ -0,0 +1,130 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestDelivery(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	manufacturer := manufacturerIdentity.subject()
+	carrier := carrierIdentity.subject()
+	signature := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+	confirm := func(request string) (Delivery, int32) {
+		var delivery Delivery
+		response := bed.invoke("confirmDelivery", "pallet-1", request)
+		json.Unmarshal(response.Payload, &delivery)
+		return delivery, response.Status
+	}
+	getDiscrepancy := func(id string) Discrepancy {
+		var discrepancy Discrepancy
+		json.Unmarshal(bed.mustInvoke("getDiscrepancy", id), &discrepancy)
+		return discrepancy
+	}
+
+	g.Describe("Confirm Delivery", func() {
+		//Org1 packs three cases onto a pallet the carrier delivers to the manufacturer
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC))
+			bed.as(org1Identity)
+			counterparties := `["` + carrier + `","` + manufacturer + `"]`
+			bed.mustInvoke("createContainer", `{"trackingID":"pallet-1","counterparties":`+counterparties+`}`)
+			for _, id := range []string{"case-1", "case-2", "case-3"} {
+				bed.mustInvoke("createProduct", `{"trackingID":"`+id+`","productName":"Vaccine","counterparties":`+counterparties+`}`)
+				bed.mustInvoke("package", "pallet-1", id)
+			}
+			bed.as(carrierIdentity)
+			bed.mustInvoke("claimContainer", "pallet-1", "London")
+			bed.as(manufacturerIdentity)
+			bed.mustInvoke("claimContainer", "pallet-1", "Zurich")
+		})
+
+		g.It("should sign off a delivery matching the expected contents", func() {
+			delivery, status := confirm(`{"scanned":["case-3","case-1","case-2"],"notes":"all good","signatureHash":"` + signature + `","sender":"` + carrier + `"}`)
+
+			Expect(status).To(BeEquivalentTo(200))
+			Expect(delivery.Sender).To(Equal(carrier))
+			Expect(delivery.Receiver).To(Equal(manufacturer))
+			Expect(delivery.Missing).To(BeEmpty())
+			Expect(delivery.Extra).To(BeEmpty())
+			Expect(delivery.DiscrepancyID).To(Equal(""))
+
+			var deliveries []Delivery
+			json.Unmarshal(bed.mustInvoke("getDeliveries", "pallet-1"), &deliveries)
+			Expect(deliveries).To(Equal([]Delivery{delivery}))
+		})
+
+		g.It("should record missing, extra and damaged items and open a discrepancy", func() {
+			delivery, status := confirm(`{"scanned":["case-1","case-2","case-9"],"damaged":["case-2"],"notes":"received 2 of 3 cases","signatureHash":"` + signature + `","sender":"` + carrier + `"}`)
+
+			Expect(status).To(BeEquivalentTo(200))
+			Expect(delivery.Missing).To(Equal([]string{"case-3"}))
+			Expect(delivery.Extra).To(Equal([]string{"case-9"}))
+			Expect(delivery.Damaged).To(Equal([]string{"case-2"}))
+			Expect(delivery.DiscrepancyID).To(Equal(fmt.Sprintf("tx%d", bed.tx)))
+
+			var product Product
+			json.Unmarshal(bed.mustInvoke("getProduct", "case-2"), &product)
+			Expect(product.Health).To(Equal(HealthDamaged))
+			Expect(product.HealthReason).To(Equal(ReasonPhysicalDamage))
+
+			discrepancy := getDiscrepancy(delivery.DiscrepancyID)
+			Expect(discrepancy.Status).To(Equal(DiscrepancyOpen))
+			Expect(discrepancy.Parties).To(Equal([]string{carrier, manufacturer}))
+		})
+
+		g.It("should let both parties comment until both accept the resolution", func() {
+			delivery, _ := confirm(`{"scanned":["case-1","case-2"],"signatureHash":"` + signature + `","sender":"` + carrier + `"}`)
+			id := delivery.DiscrepancyID
+
+			bed.mustInvoke("commentDiscrepancy", id, "case-3 was not loaded")
+			bed.mustInvoke("resolveDiscrepancy", id)
+			Expect(getDiscrepancy(id).Status).To(Equal(DiscrepancyOpen))
+
+			bed.as(carrierIdentity)
+			bed.mustInvoke("commentDiscrepancy", id, "credit note issued")
+			bed.mustInvoke("resolveDiscrepancy", id)
+
+			discrepancy := getDiscrepancy(id)
+			Expect(discrepancy.Status).To(Equal(DiscrepancyResolved))
+			Expect(discrepancy.Comments).To(HaveLen(2))
+			Expect(discrepancy.Comments[1].Author).To(Equal(carrier))
+
+			Expect(bed.invoke("commentDiscrepancy", id, "too late").Status).To(BeEquivalentTo(403))
+		})
+
+		g.It("should return 400 for an invalid sign-off", func() {
+			_, status := confirm(`{"scanned":["case-1"],"damaged":["case-2"],"signatureHash":"` + signature + `","sender":"` + carrier + `"}`)
+			Expect(status).To(BeEquivalentTo(400))
+			_, status = confirm(`{"scanned":["case-1"],"signatureHash":"signed","sender":"` + carrier + `"}`)
+			Expect(status).To(BeEquivalentTo(400))
+			_, status = confirm(`{"scanned":["case-1"],"signatureHash":"` + signature + `","sender":"` + manufacturer + `"}`)
+			Expect(status).To(BeEquivalentTo(400))
+		})
+
+		g.It("should return 403 if the identity does not hold the container", func() {
+			bed.as(carrierIdentity)
+			_, status := confirm(`{"scanned":[],"signatureHash":"` + signature + `","sender":"` + manufacturer + `"}`)
+			Expect(status).To(BeEquivalentTo(403))
+		})
+
+		g.It("should return 404 if the identity is not a party to the discrepancy", func() {
+			delivery, _ := confirm(`{"scanned":[],"signatureHash":"` + signature + `","sender":"` + carrier + `"}`)
+			Expect(delivery.DiscrepancyID).NotTo(BeEmpty())
+			bed.as(org1Identity)
+			Expect(bed.invoke("getDiscrepancy", delivery.DiscrepancyID).Status).To(BeEquivalentTo(404))
+		})
+	})
+}
@@ -0,0 +1,239 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// destructionKey is the composite key object type certificates of destruction are stored under, by the trackingID
+// of every destroyed item
+const destructionKey = "destruction"
+
+// destroyAsset destroys an unpackaged product or container held by the current user along with everything packaged
+// into it and issues a certificate of destruction, which the named witness confirms with witnessDestruction
+func (s *SmartContract) destroyAsset(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	trackingID := args[0]
+
+	var request DestructionRequest
+	if err := json.Unmarshal([]byte(args[1]), &request); err != nil {
+		return shim.Error(err.Error())
+	}
+	destroyer := identity.Cert.Subject.String()
+	if err := request.Validate(destroyer); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+
+	if response := checkCustodian(stub, trackingID, identity); response.Status != shim.OK {
+		return response
+	}
+	containers, products, err := getContainerTree(stub, trackingID)
+	if err != nil {
+		return peer.Response{
+			Status:  404,
+			Message: err.Error(),
+		}
+	}
+	//the destroyed item is the first of the tree, its contents follow
+	var health, containerID string
+	if len(containers) != 0 && containers[0].ID == trackingID {
+		health, containerID = containers[0].Health, containers[0].ContainerID
+	} else {
+		health, containerID = products[0].Health, products[0].ContainerID
+	}
+	if containerID != "" {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Item %s needs to be unpackaged before it can be destroyed", trackingID),
+		}
+	}
+	if health == HealthDestroyed {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Item %s is already destroyed", trackingID),
+		}
+	}
+	return s.destroyItems(stub, identity, trackingID, request, containers, products)
+}
+
+// destroyItems destroys the supplied containers and products of the item with the supplied trackingID and returns
+// its certificate of destruction, items that are already destroyed are skipped
+func (s *SmartContract) destroyItems(stub shim.ChaincodeStubInterface, identity *Identity, trackingID string, request DestructionRequest, containers []Container, products []Product) peer.Response {
+	if response := checkDestroyable(stub, containers, products); response.Status != shim.OK {
+		return response
+	}
+
+	timestamp := int64(s.clock.Now().UTC().Unix())
+	certificate := DestructionCertificate{
+		Type:         destructionKey,
+		ID:           stub.GetTxID(),
+		TrackingID:   trackingID,
+		Items:        []string{},
+		Method:       request.Method,
+		Witness:      request.Witness,
+		Reason:       request.Reason,
+		DestroyedBy:  identity.Cert.Subject.String(),
+		Organization: identity.Organization,
+		Location:     request.Location,
+		Timestamp:    timestamp,
+	}
+	for _, container := range containers {
+		if container.Health == HealthDestroyed {
+			continue
+		}
+		container.Health = HealthDestroyed
+		container.HealthReason = ReasonDisposal
+		container.Timestamp = timestamp
+		containerBytes, _ := json.Marshal(container)
+		if err := stub.PutState(container.ID, containerBytes); err != nil {
+			return shim.Error(err.Error())
+		}
+		certificate.Items = append(certificate.Items, container.ID)
+	}
+	for _, product := range products {
+		if product.Health == HealthDestroyed {
+			continue
+		}
+		product.Health = HealthDestroyed
+		product.HealthReason = ReasonDisposal
+		product.Timestamp = timestamp
+		if response := putProducts(stub, product); response.Status != shim.OK {
+			return response
+		}
+		certificate.Items = append(certificate.Items, product.ID)
+	}
+
+	certificateBytes, err := putDestructionCertificate(stub, certificate)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	s.logger.Infof("Destroyed %s and %d items by %s\n", trackingID, len(certificate.Items)-1, certificate.Method)
+	return shim.Success(certificateBytes)
+}
+
+// witnessDestruction confirms the destruction of an item by the witness named in its certificate of destruction
+func (s *SmartContract) witnessDestruction(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	key, _ := stub.CreateCompositeKey(destructionKey, []string{args[0]})
+	certificateBytes, err := stub.GetState(key)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	var certificate DestructionCertificate
+	if len(certificateBytes) == 0 || json.Unmarshal(certificateBytes, &certificate) != nil {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Certificate of destruction for %s Not Found", args[0]),
+		}
+	}
+	if identity.Cert.Subject.String() != certificate.Witness {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, not the witness of %s", certificate.ID),
+		}
+	}
+	if certificate.Witnessed() {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Certificate of destruction %s is already witnessed", certificate.ID),
+		}
+	}
+
+	certificate.WitnessedAt = int64(s.clock.Now().UTC().Unix())
+	certificateBytes, err = putDestructionCertificate(stub, certificate)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	s.logger.Infof("Witnessed destruction %s of %s\n", certificate.ID, certificate.TrackingID)
+	return shim.Success(certificateBytes)
+}
+
+// getDestructionCertificate retrieves the certificate of destruction of a destroyed item, it is public so that
+// regulators and anyone coming across the item can verify its destruction
+func (s *SmartContract) getDestructionCertificate(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	key, _ := stub.CreateCompositeKey(destructionKey, []string{args[0]})
+	certificateBytes, err := stub.GetState(key)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(certificateBytes) == 0 {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Certificate of destruction for %s Not Found", args[0]),
+		}
+	}
+	return shim.Success(certificateBytes)
+}
+
+// checkDestroyable returns a 403 response if the health of any of the items cannot change to destroyed under the
+// rules of its manufacturer or the organization that created it, items that are already destroyed are skipped
+func checkDestroyable(stub shim.ChaincodeStubInterface, containers []Container, products []Product) peer.Response {
+	for _, container := range containers {
+		rules, err := loadHealthRules(stub, container.Organization)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if container.Health != HealthDestroyed && !rules.CanChange(container.Health, HealthDestroyed) {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("Container %s cannot be destroyed from %s", container.ID, NormalizeHealth(container.Health)),
+			}
+		}
+	}
+	for _, product := range products {
+		rules, err := loadHealthRules(stub, product.Manufacturer)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if product.Health != HealthDestroyed && !rules.CanChange(product.Health, HealthDestroyed) {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("Product %s cannot be destroyed from %s", product.ID, NormalizeHealth(product.Health)),
+			}
+		}
+	}
+	return shim.Success(nil)
+}
+
+// putDestructionCertificate stores the certificate of destruction under every item it covers
+func putDestructionCertificate(stub shim.ChaincodeStubInterface, certificate DestructionCertificate) ([]byte, error) {
+	certificateBytes, _ := json.Marshal(certificate)
+	for _, id := range certificate.Items {
+		key, _ := stub.CreateCompositeKey(destructionKey, []string{id})
+		if err := stub.PutState(key, certificateBytes); err != nil {
+			return nil, err
+		}
+	}
+	return certificateBytes, nil
+}
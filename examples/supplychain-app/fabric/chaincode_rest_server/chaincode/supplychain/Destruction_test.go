@@ -0,0 +1,114 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestDestruction(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	now := time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC)
+	holder := manufacturerIdentity.subject()
+	witness := carrierIdentity.subject()
+	request := `{"method":"incineration","witness":"` + witness + `","reason":"contaminated batch"}`
+
+	getProduct := func(id string) Product {
+		var product Product
+		json.Unmarshal(bed.mustInvoke("getProduct", id), &product)
+		return product
+	}
+	getCertificate := func(id string) DestructionCertificate {
+		var certificate DestructionCertificate
+		json.Unmarshal(bed.mustInvoke("getDestructionCertificate", id), &certificate)
+		return certificate
+	}
+	destroyAsset := func(trackingID string, body string) int32 {
+		return bed.invoke("destroyAsset", trackingID, body).Status
+	}
+
+	g.Describe("Destroy Asset", func() {
+		//the holder takes two vials of Org1 and packs them onto its pallet
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, now)
+			bed.as(org1Identity)
+			for _, id := range []string{"vial-1", "vial-2"} {
+				bed.mustInvoke("createProduct", `{"trackingID":"`+id+`","productName":"Vaccine","counterparties":["`+holder+`"]}`)
+			}
+			bed.as(manufacturerIdentity)
+			bed.mustInvoke("createContainer", `{"trackingID":"pallet-1","counterparties":[]}`)
+			for _, id := range []string{"vial-1", "vial-2"} {
+				bed.mustInvoke("claimProduct", id, "Zurich")
+				bed.mustInvoke("package", "pallet-1", id)
+			}
+		})
+
+		g.It("should destroy a container with its contents and issue a certificate", func() {
+			var certificate DestructionCertificate
+			json.Unmarshal(bed.mustInvoke("destroyAsset", "pallet-1", request), &certificate)
+			Expect(certificate.ID).To(Equal(fmt.Sprintf("tx%d", bed.tx)))
+			Expect(certificate.Items).To(Equal([]string{"pallet-1", "vial-1", "vial-2"}))
+			Expect(certificate.DestroyedBy).To(Equal(holder))
+			Expect(getProduct("vial-2").Health).To(Equal(HealthDestroyed))
+			Expect(getProduct("vial-2").HealthReason).To(Equal(ReasonDisposal))
+
+			bed.as(retailerIdentity)
+			certificate = getCertificate("vial-1")
+			Expect(certificate.TrackingID).To(Equal("pallet-1"))
+			Expect(certificate.Method).To(Equal("incineration"))
+		})
+
+		g.It("should record the named witness once it confirms the destruction", func() {
+			destroyAsset("pallet-1", request)
+			Expect(bed.invoke("witnessDestruction", "vial-1").Status).To(BeEquivalentTo(403))
+
+			bed.as(carrierIdentity)
+			bed.mustInvoke("witnessDestruction", "vial-1")
+			Expect(bed.invoke("witnessDestruction", "pallet-1").Status).To(BeEquivalentTo(403))
+
+			certificate := getCertificate("pallet-1")
+			Expect(certificate.Witness).To(Equal(witness))
+			Expect(certificate.WitnessedAt).To(BeEquivalentTo(now.Unix()))
+		})
+
+		g.It("should only destroy items the health rules of their manufacturer allow to be destroyed", func() {
+			bed.as(org1Identity)
+			bed.mustInvoke("setHealthRules", `{"transitions":{"ok":["damaged"],"damaged":["destroyed"]},"reasons":["physical_damage"]}`)
+
+			bed.as(manufacturerIdentity)
+			Expect(destroyAsset("pallet-1", request)).To(BeEquivalentTo(403))
+			Expect(NormalizeHealth(getProduct("vial-1").Health)).To(Equal(HealthOK))
+		})
+
+		g.It("should reject destroyed items on scan", func() {
+			destroyAsset("pallet-1", request)
+			Expect(string(bed.mustInvoke("scan", "vial-1"))).To(Equal(`{"status":"destroyed"}`))
+
+			Expect(destroyAsset("pallet-1", request)).To(BeEquivalentTo(403))
+		})
+
+		g.It("should only destroy unpackaged items held by the current user", func() {
+			Expect(destroyAsset("vial-1", request)).To(BeEquivalentTo(403))
+
+			bed.as(carrierIdentity)
+			Expect(destroyAsset("pallet-1", `{"method":"shredding","witness":"`+holder+`","reason":"expired"}`)).To(BeEquivalentTo(403))
+		})
+
+		g.It("should return 400 without a method, a reason or an independent witness", func() {
+			Expect(destroyAsset("pallet-1", `{"method":"burial","witness":"`+witness+`","reason":"expired"}`)).To(BeEquivalentTo(400))
+			Expect(destroyAsset("pallet-1", `{"method":"shredding","witness":"`+witness+`","reason":" "}`)).To(BeEquivalentTo(400))
+			Expect(destroyAsset("pallet-1", `{"method":"shredding","witness":"`+holder+`","reason":"expired"}`)).To(BeEquivalentTo(400))
+			Expect(NormalizeHealth(getProduct("vial-1").Health)).To(Equal(HealthOK))
+		})
+	})
+}
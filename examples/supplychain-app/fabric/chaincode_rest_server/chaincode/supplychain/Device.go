@@ -0,0 +1,307 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// deviceKey is the composite key object type devices are stored under
+const deviceKey = "device"
+
+// registerDevice registers an IoT device and its public key, owned by the current user
+func (s *SmartContract) registerDevice(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	var request DeviceRequest
+	if err := json.Unmarshal([]byte(args[0]), &request); err != nil {
+		return shim.Error(err.Error())
+	}
+	if request.ID == "" {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: deviceID is required "),
+		}
+	}
+	algorithm, err := KeyAlgorithm(request.PublicKey)
+	if err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+
+	existing, err := getDevice(stub, request.ID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if existing != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Existing Device %s Found", request.ID),
+		}
+	}
+
+	device := Device{
+		Type:        deviceKey,
+		ID:          request.ID,
+		Owner:       identity.Cert.Subject.String(),
+		PublicKey:   request.PublicKey,
+		Algorithm:   algorithm,
+		RetiredKeys: []RetiredKey{},
+		Timestamp:   int64(s.clock.Now().UTC().Unix()),
+	}
+	if request.ContainerID != "" {
+		if response := checkBinding(stub, identity, request.ContainerID); response.Status != 200 {
+			return response
+		}
+		device.ContainerID = request.ContainerID
+	}
+
+	if err := putDevice(stub, device); err != nil {
+		return shim.Error(err.Error())
+	}
+	deviceBytes, _ := json.Marshal(device)
+	s.logger.Infof("Registered %s device %s\n", algorithm, device.ID)
+	return shim.Success(deviceBytes)
+}
+
+// bindDevice binds a device to a container held by the current user, an empty containerID unbinds it
+func (s *SmartContract) bindDevice(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	containerID := args[1]
+
+	device, response := getOwnedDevice(stub, identity, args[0])
+	if device == nil {
+		return response
+	}
+	if containerID != "" {
+		if response := checkBinding(stub, identity, containerID); response.Status != 200 {
+			return response
+		}
+	}
+	device.ContainerID = containerID
+
+	if err := putDevice(stub, *device); err != nil {
+		return shim.Error(err.Error())
+	}
+	deviceBytes, _ := json.Marshal(device)
+	s.logger.Infof("Bound device %s to container %s\n", device.ID, containerID)
+	return shim.Success(deviceBytes)
+}
+
+// rotateDeviceKey replaces the public key of a device, signatures of the retired key are no longer accepted
+func (s *SmartContract) rotateDeviceKey(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	publicKey := args[1]
+
+	device, response := getOwnedDevice(stub, identity, args[0])
+	if device == nil {
+		return response
+	}
+	algorithm, err := KeyAlgorithm(publicKey)
+	if err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	if publicKey == device.PublicKey {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: new key of device %s is the current key ", device.ID),
+		}
+	}
+	for _, retired := range device.RetiredKeys {
+		if retired.PublicKey == publicKey {
+			return peer.Response{
+				Status:  400,
+				Message: fmt.Sprintf("Error: new key of device %s was retired ", device.ID),
+			}
+		}
+	}
+
+	now := int64(s.clock.Now().UTC().Unix())
+	device.RetiredKeys = append(device.RetiredKeys, RetiredKey{PublicKey: device.PublicKey, RetiredAt: now})
+	device.PublicKey = publicKey
+	device.Algorithm = algorithm
+	device.Timestamp = now
+
+	if err := putDevice(stub, *device); err != nil {
+		return shim.Error(err.Error())
+	}
+	deviceBytes, _ := json.Marshal(device)
+	s.logger.Infof("Rotated key of device %s\n", device.ID)
+	return shim.Success(deviceBytes)
+}
+
+// revokeDevice permanently revokes a device, its signatures are no longer accepted
+func (s *SmartContract) revokeDevice(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	device, response := getOwnedDevice(stub, identity, args[0])
+	if device == nil {
+		return response
+	}
+	device.Revoked = true
+	device.ContainerID = ""
+	device.Timestamp = int64(s.clock.Now().UTC().Unix())
+
+	if err := putDevice(stub, *device); err != nil {
+		return shim.Error(err.Error())
+	}
+	deviceBytes, _ := json.Marshal(device)
+	s.logger.Infof("Revoked device %s\n", device.ID)
+	return shim.Success(deviceBytes)
+}
+
+// getSingleDevice retrieves a device by its deviceID, if the current user is a member of the organization owning it
+// or a participant of the container it is bound to
+func (s *SmartContract) getSingleDevice(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	device, err := getDevice(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	subject := identity.Cert.Subject.String()
+	if device == nil || (SubjectOrganization(device.Owner) != SubjectOrganization(subject) &&
+		(device.ContainerID == "" || !itemHasParticipant(stub, device.ContainerID, subject))) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Device %s Not Found", args[0]),
+		}
+	}
+	deviceBytes, _ := json.Marshal(device)
+	return shim.Success(deviceBytes)
+}
+
+// verifyDeviceSignature checks that the payload was signed by a registered device that is not revoked
+func verifyDeviceSignature(stub shim.ChaincodeStubInterface, deviceID string, payload []byte, signature string) (*Device, peer.Response) {
+	device, err := getDevice(stub, deviceID)
+	if err != nil {
+		return nil, shim.Error(err.Error())
+	}
+	if device == nil {
+		return nil, peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Device %s Not Found", deviceID),
+		}
+	}
+	if err := device.Verify(payload, signature); err != nil {
+		return nil, peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	return device, shim.Success(nil)
+}
+
+// getOwnedDevice returns the device if it exists, is not revoked and is owned by the identity
+func getOwnedDevice(stub shim.ChaincodeStubInterface, identity *Identity, deviceID string) (*Device, peer.Response) {
+	device, err := getDevice(stub, deviceID)
+	if err != nil {
+		return nil, shim.Error(err.Error())
+	}
+	if device == nil {
+		return nil, peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Device %s Not Found", deviceID),
+		}
+	}
+	if device.Owner != identity.Cert.Subject.String() {
+		return nil, peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, device not owned by identity"),
+		}
+	}
+	if device.Revoked {
+		return nil, peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Device %s is revoked", deviceID),
+		}
+	}
+	return device, shim.Success(nil)
+}
+
+// checkBinding checks that a device can be bound to the container, it must exist and be held by the identity
+func checkBinding(stub shim.ChaincodeStubInterface, identity *Identity, containerID string) peer.Response {
+	containerBytes, _ := stub.GetState(containerID)
+	var container Container
+	if len(containerBytes) == 0 || json.Unmarshal(containerBytes, &container) != nil {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Container %s Not Found", containerID),
+		}
+	}
+	if identity.Cert.Subject.String() != container.Custodian {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, container not held by identity"),
+		}
+	}
+	return shim.Success(nil)
+}
+
+func getDevice(stub shim.ChaincodeStubInterface, deviceID string) (*Device, error) {
+	key, _ := stub.CreateCompositeKey(deviceKey, []string{deviceID})
+	deviceBytes, err := stub.GetState(key)
+	if err != nil || len(deviceBytes) == 0 {
+		return nil, err
+	}
+	var device Device
+	if err := json.Unmarshal(deviceBytes, &device); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+func putDevice(stub shim.ChaincodeStubInterface, device Device) error {
+	key, _ := stub.CreateCompositeKey(deviceKey, []string{device.ID})
+	deviceBytes, _ := json.Marshal(device)
+	return stub.PutState(key, deviceBytes)
+}
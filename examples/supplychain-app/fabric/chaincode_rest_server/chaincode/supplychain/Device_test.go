@@ -0,0 +1,171 @@
+package supplychain
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	"github.com/hyperledger/fabric/protos/peer"
+	. "github.com/onsi/gomega"
+)
+
+func newDeviceKey() *ecdsa.PrivateKey {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	return key
+}
+
+func publicKeyPEM(key interface{}) string {
+	keyBytes, _ := x509.MarshalPKIXPublicKey(key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: keyBytes}))
+}
+
+func signECDSA(key *ecdsa.PrivateKey, payload string) string {
+	digest := sha256.Sum256([]byte(payload))
+	signature, _ := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func signScan(key *ecdsa.PrivateKey, request ScanRequest) []byte {
+	request.Signature = signECDSA(key, string(request.Payload()))
+	requestBytes, _ := json.Marshal(request)
+	return requestBytes
+}
+
+func TestDevice(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	manufacturer := manufacturerIdentity.subject()
+	scannerKey := newDeviceKey()
+	rotatedKey := newDeviceKey()
+	edPublicKey, edPrivateKey, _ := ed25519.GenerateKey(rand.Reader)
+	now := time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC).Unix()
+	scan := ScanRequest{TrackingID: "cooler-1", Location: "Zurich", DeviceID: "scanner-1", Timestamp: now}
+
+	registerDevice := func(request DeviceRequest) peer.Response {
+		requestBytes, _ := json.Marshal(request)
+		return bed.invoke("registerDevice", string(requestBytes))
+	}
+	getDevice := func(deviceID string) Device {
+		var device Device
+		json.Unmarshal(bed.mustInvoke("getDevice", deviceID), &device)
+		return device
+	}
+
+	g.Describe("Device Registry", func() {
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, time.Unix(now, 0))
+			bed.as(manufacturerIdentity)
+			bed.mustInvoke("createContainer", `{"trackingID":"cooler-1","counterparties":["`+carrierIdentity.subject()+`"]}`)
+			Expect(registerDevice(DeviceRequest{ID: "scanner-1", PublicKey: publicKeyPEM(&scannerKey.PublicKey)}).Status).To(BeEquivalentTo(200))
+		})
+
+		g.It("should register ECDSA and Ed25519 devices", func() {
+			response := registerDevice(DeviceRequest{ID: "logger-1", PublicKey: publicKeyPEM(edPublicKey), ContainerID: "cooler-1"})
+			Expect(response.Status).To(BeEquivalentTo(200))
+
+			device := getDevice("logger-1")
+			Expect(device.Algorithm).To(Equal(AlgorithmEd25519))
+			Expect(device.Owner).To(Equal(manufacturer))
+			Expect(device.ContainerID).To(Equal("cooler-1"))
+			Expect(getDevice("scanner-1").Algorithm).To(Equal(AlgorithmECDSA))
+		})
+
+		g.It("should only return devices to their organization and the participants of the bound container", func() {
+			registerDevice(DeviceRequest{ID: "logger-1", PublicKey: publicKeyPEM(edPublicKey), ContainerID: "cooler-1"})
+
+			bed.as(org1Identity)
+			Expect(bed.invoke("getDevice", "scanner-1").Status).To(BeEquivalentTo(200))
+
+			bed.as(carrierIdentity)
+			Expect(bed.invoke("getDevice", "logger-1").Status).To(BeEquivalentTo(200))
+			Expect(bed.invoke("getDevice", "scanner-1").Status).To(BeEquivalentTo(404))
+
+			bed.as(retailerIdentity)
+			Expect(bed.invoke("getDevice", "logger-1").Status).To(BeEquivalentTo(404))
+		})
+
+		g.It("should return 400 for an existing device or a key that is not PEM encoded", func() {
+			response := registerDevice(DeviceRequest{ID: "scanner-1", PublicKey: publicKeyPEM(&scannerKey.PublicKey)})
+			Expect(response.Status).To(BeEquivalentTo(400))
+
+			response = bed.invoke("registerDevice", `{"deviceID":"scanner-2","publicKey":"not a key"}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+		})
+
+		g.It("should accept scans signed by the device", func() {
+			response := bed.invoke("recordScan", string(signScan(scannerKey, scan)))
+			Expect(response.Status).To(BeEquivalentTo(200))
+			Expect(string(response.Payload)).To(Equal(fmt.Sprintf(`{"device":"scanner-1","scanID":"tx%d","status":"owned"}`, bed.tx)))
+
+			var moved ScanRequest
+			json.Unmarshal(signScan(scannerKey, ScanRequest{TrackingID: "cooler-1", Location: "Zurich", DeviceID: "scanner-1", Timestamp: now + 1}), &moved)
+			moved.Location = "London"
+			movedBytes, _ := json.Marshal(moved)
+			response = bed.invoke("recordScan", string(movedBytes))
+			Expect(response.Status).To(BeEquivalentTo(403))
+		})
+
+		g.It("should return 400 for replayed, stale and unsigned scans", func() {
+			bed.mustInvoke("recordScan", string(signScan(scannerKey, scan)))
+			response := bed.invoke("recordScan", string(signScan(scannerKey, scan)))
+			Expect(response.Status).To(BeEquivalentTo(400))
+			Expect(response.Message).To(Equal(fmt.Sprintf("Error: scan at %d is not newer than the last scan of device scanner-1 ", now)))
+
+			stale := scan
+			stale.Timestamp = now - MaxScanAge - 1
+			response = bed.invoke("recordScan", string(signScan(scannerKey, stale)))
+			Expect(response.Status).To(BeEquivalentTo(400))
+
+			response = bed.invoke("recordScan", `{"trackingID":"cooler-1","location":"Zurich"}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+			Expect(response.Message).To(Equal("Error: a scan must be signed by a registered device "))
+		})
+
+		g.It("should verify Ed25519 signatures", func() {
+			registerDevice(DeviceRequest{ID: "scanner-2", PublicKey: publicKeyPEM(edPublicKey)})
+
+			edScan := ScanRequest{TrackingID: "cooler-1", Location: "Zurich", DeviceID: "scanner-2", Timestamp: now}
+			edScan.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(edPrivateKey, edScan.Payload()))
+			requestBytes, _ := json.Marshal(edScan)
+			bed.mustInvoke("recordScan", string(requestBytes))
+		})
+
+		g.It("should only accept signatures of the current key after a rotation", func() {
+			bed.mustInvoke("rotateDeviceKey", "scanner-1", publicKeyPEM(&rotatedKey.PublicKey))
+
+			Expect(bed.invoke("recordScan", string(signScan(scannerKey, scan))).Status).To(BeEquivalentTo(403))
+			Expect(bed.invoke("recordScan", string(signScan(rotatedKey, scan))).Status).To(BeEquivalentTo(200))
+
+			response := bed.invoke("rotateDeviceKey", "scanner-1", publicKeyPEM(&scannerKey.PublicKey))
+			Expect(response.Status).To(BeEquivalentTo(400))
+		})
+
+		g.It("should reject signatures of a revoked device", func() {
+			bed.mustInvoke("revokeDevice", "scanner-1")
+
+			Expect(bed.invoke("recordScan", string(signScan(scannerKey, scan))).Status).To(BeEquivalentTo(403))
+			Expect(bed.invoke("bindDevice", "scanner-1", "cooler-1").Status).To(BeEquivalentTo(403))
+		})
+
+		g.It("should return 403 if the identity does not own the device", func() {
+			bed.as(carrierIdentity)
+			Expect(bed.invoke("bindDevice", "scanner-1", "cooler-1").Status).To(BeEquivalentTo(403))
+			Expect(bed.invoke("revokeDevice", "scanner-1").Status).To(BeEquivalentTo(403))
+		})
+	})
+}
@@ -0,0 +1,263 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// disputeKey is the composite key object type disputes are stored under
+const disputeKey = "dispute"
+
+// raiseDispute opens a dispute of the current user against the custodian of items during a custody interval
+func (s *SmartContract) raiseDispute(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	var request DisputeRequest
+	if err := json.Unmarshal([]byte(args[0]), &request); err != nil {
+		return shim.Error(err.Error())
+	}
+	interval, err := request.Validate()
+	if err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	raiser := identity.Cert.Subject.String()
+	if request.Respondent == raiser {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: cannot raise a dispute against yourself "),
+		}
+	}
+
+	//both parties must be participants of every item in question
+	for _, trackingID := range request.TrackingIDs {
+		if !itemHasParticipant(stub, trackingID, identity.Cert.Subject.String()) {
+			return peer.Response{
+				Status:  404,
+				Message: fmt.Sprintf("Item with trackingID %s not found", trackingID),
+			}
+		}
+		if !itemHasParticipant(stub, trackingID, request.Respondent) {
+			return peer.Response{
+				Status:  400,
+				Message: fmt.Sprintf("Error: respondent is not a participant of %s ", trackingID),
+			}
+		}
+	}
+	if request.DiscrepancyID != "" {
+		if discrepancy, response := getPartyDiscrepancy(stub, identity, request.DiscrepancyID); discrepancy == nil {
+			return response
+		}
+	}
+
+	timestamp := int64(s.clock.Now().UTC().Unix())
+	dispute := Dispute{
+		Type:          disputeKey,
+		ID:            stub.GetTxID(),
+		TrackingIDs:   request.TrackingIDs,
+		Interval:      interval,
+		DiscrepancyID: request.DiscrepancyID,
+		Description:   request.Description,
+		Raiser:        raiser,
+		Respondent:    request.Respondent,
+		Organizations: []string{SubjectOrganization(raiser)},
+		Status:        DisputeOpen,
+		Evidence:      []Evidence{},
+		History: []DisputeEvent{{
+			Status:    DisputeOpen,
+			By:        raiser,
+			Note:      request.Description,
+			Timestamp: timestamp,
+		}},
+		Timestamp: timestamp,
+	}
+	//the organizations are taken from the certificates of both parties rather than the request
+	if respondentOrganization := SubjectOrganization(request.Respondent); respondentOrganization != dispute.Organizations[0] {
+		dispute.Organizations = append(dispute.Organizations, respondentOrganization)
+	}
+	for _, attachment := range request.Evidence {
+		dispute.Evidence = append(dispute.Evidence, Evidence{Attachment: attachment, AddedBy: raiser, Timestamp: timestamp})
+	}
+	if err := putDispute(stub, dispute); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	response := map[string]interface{}{
+		"generatedID": dispute.ID,
+	}
+	bytes, _ := json.Marshal(response)
+
+	s.logger.Infof("Raised dispute %s against %s\n", dispute.ID, dispute.Respondent)
+	return shim.Success(bytes)
+}
+
+// updateDispute moves a dispute to a new state. The respondent takes it under review, accepts or rejects it and the
+// raiser settles it or takes a rejected dispute back under review.
+func (s *SmartContract) updateDispute(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 2 or 3")
+	}
+	var note string
+	if len(args) == 3 {
+		note = args[2]
+	}
+
+	dispute, response := getPartyDispute(stub, identity, args[0])
+	if dispute == nil {
+		return response
+	}
+	if err := dispute.Transition(identity.Cert.Subject.String(), args[1], note, int64(s.clock.Now().UTC().Unix())); err != nil {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Dispute %s: %s", dispute.ID, err),
+		}
+	}
+	if err := putDispute(stub, *dispute); err != nil {
+		return shim.Error(err.Error())
+	}
+	disputeBytes, _ := json.Marshal(dispute)
+
+	s.logger.Infof("Dispute %s is %s\n", dispute.ID, dispute.Status)
+	return shim.Success(disputeBytes)
+}
+
+// addDisputeEvidence attaches the SHA-256 hash of a photo or document to a dispute that is not settled
+func (s *SmartContract) addDisputeEvidence(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+
+	var attachment Attachment
+	if err := json.Unmarshal([]byte(args[1]), &attachment); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := ValidateHash(attachment.Hash); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+
+	dispute, response := getPartyDispute(stub, identity, args[0])
+	if dispute == nil {
+		return response
+	}
+	if dispute.Closed() {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Dispute %s is settled", dispute.ID),
+		}
+	}
+	dispute.Evidence = append(dispute.Evidence, Evidence{
+		Attachment: attachment,
+		AddedBy:    identity.Cert.Subject.String(),
+		Timestamp:  int64(s.clock.Now().UTC().Unix()),
+	})
+	if err := putDispute(stub, *dispute); err != nil {
+		return shim.Error(err.Error())
+	}
+	disputeBytes, _ := json.Marshal(dispute)
+	return shim.Success(disputeBytes)
+}
+
+// getSingleDispute retrieves a dispute with its evidence and history by disputeID
+func (s *SmartContract) getSingleDispute(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	dispute, response := getPartyDispute(stub, identity, args[0])
+	if dispute == nil {
+		return response
+	}
+	disputeBytes, _ := json.Marshal(dispute)
+	return shim.Success(disputeBytes)
+}
+
+// getOpenDisputes retrieves the disputes of the organization of the current user that are not settled
+func (s *SmartContract) getOpenDisputes(stub shim.ChaincodeStubInterface) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(disputeKey, []string{})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer iterator.Close()
+	disputes := []Dispute{}
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		var dispute Dispute
+		if err := json.Unmarshal(state.Value, &dispute); err != nil {
+			return shim.Error(err.Error())
+		}
+		if dispute.AccessibleBy(identity) && !dispute.Closed() {
+			disputes = append(disputes, dispute)
+		}
+	}
+	disputesBytes, _ := json.Marshal(disputes)
+	return shim.Success(disputesBytes)
+}
+
+// getPartyDispute returns a 404 response if the dispute does not exist or the identity is not a member of the
+// organization of the raiser or respondent
+func getPartyDispute(stub shim.ChaincodeStubInterface, identity *Identity, disputeID string) (*Dispute, peer.Response) {
+	key, _ := stub.CreateCompositeKey(disputeKey, []string{disputeID})
+	disputeBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, shim.Error(err.Error())
+	}
+	var dispute Dispute
+	if len(disputeBytes) == 0 || json.Unmarshal(disputeBytes, &dispute) != nil || !dispute.AccessibleBy(identity) {
+		return nil, peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Dispute %s Not Found", disputeID),
+		}
+	}
+	return &dispute, shim.Success(nil)
+}
+
+func putDispute(stub shim.ChaincodeStubInterface, dispute Dispute) error {
+	key, _ := stub.CreateCompositeKey(disputeKey, []string{dispute.ID})
+	disputeBytes, _ := json.Marshal(dispute)
+	return stub.PutState(key, disputeBytes)
+}
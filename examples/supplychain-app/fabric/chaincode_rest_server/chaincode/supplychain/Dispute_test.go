@@ -0,0 +1,114 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestDispute(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	var disputeID string
+	chaincode := new(SmartContract)
+	manufacturer := manufacturerIdentity.subject()
+	carrier := carrierIdentity.subject()
+	photo := `{"name":"crushed case","hash":"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"}`
+
+	raise := func(request string) string {
+		var result map[string]string
+		json.Unmarshal(bed.mustInvoke("raiseDispute", request), &result)
+		return result["generatedID"]
+	}
+	update := func(status string) int32 {
+		return bed.invoke("updateDispute", disputeID, status, "note").Status
+	}
+	getOpenDisputes := func() []Dispute {
+		var disputes []Dispute
+		json.Unmarshal(bed.mustInvoke("getOpenDisputes"), &disputes)
+		return disputes
+	}
+
+	g.Describe("Dispute Workflow", func() {
+		//the carrier delivers a case of Org1 to the manufacturer, who disputes its condition
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC))
+			bed.as(org1Identity)
+			bed.mustInvoke("createProduct", `{"trackingID":"case-1","productName":"Vaccine","counterparties":["`+carrier+`","`+manufacturer+`"]}`)
+			bed.as(carrierIdentity)
+			bed.mustInvoke("claimProduct", "case-1", "London")
+			bed.as(manufacturerIdentity)
+			bed.mustInvoke("claimProduct", "case-1", "Zurich")
+
+			disputeID = raise(`{"trackingIDs":["case-1"],"respondent":"` + carrier + `","from":"2019-03-10T08:00:00Z","to":"2019-03-12T17:00:00Z","description":"case crushed in transit","evidence":[` + photo + `]}`)
+		})
+
+		g.It("should show open disputes to the organizations of both parties only", func() {
+			disputes := getOpenDisputes()
+			Expect(disputes).To(HaveLen(1))
+			Expect(disputes[0].Interval).To(Equal(CustodyPeriod{Custodian: carrier, From: 1552204800, To: 1552410000}))
+			Expect(disputes[0].Organizations).To(Equal([]string{"PartyA", "PartyB"}))
+			Expect(disputes[0].Evidence[0].AddedBy).To(Equal(manufacturer))
+
+			bed.as(carrierIdentity)
+			Expect(getOpenDisputes()).To(HaveLen(1))
+
+			bed.as(retailerIdentity)
+			Expect(getOpenDisputes()).To(BeEmpty())
+			Expect(bed.invoke("getDispute", disputeID).Status).To(BeEquivalentTo(404))
+		})
+
+		g.It("should take the organization of the respondent from its subject rather than the request", func() {
+			id := raise(`{"trackingIDs":["case-1"],"respondent":"` + carrier + `","respondentOrganization":"PartyC","from":"2019-03-10T08:00:00Z"}`)
+
+			bed.as(retailerIdentity)
+			Expect(bed.invoke("getDispute", id).Status).To(BeEquivalentTo(404))
+		})
+
+		g.It("should move through review, rejection, acceptance and settlement", func() {
+			bed.as(carrierIdentity)
+			Expect(update(DisputeUnderReview)).To(BeEquivalentTo(200))
+			Expect(update(DisputeRejected)).To(BeEquivalentTo(200))
+
+			bed.as(manufacturerIdentity)
+			bed.mustInvoke("addDisputeEvidence", disputeID, photo)
+			Expect(update(DisputeUnderReview)).To(BeEquivalentTo(200))
+
+			bed.as(carrierIdentity)
+			Expect(update(DisputeAccepted)).To(BeEquivalentTo(200))
+			bed.as(manufacturerIdentity)
+			Expect(update(DisputeSettled)).To(BeEquivalentTo(200))
+
+			var dispute Dispute
+			json.Unmarshal(bed.mustInvoke("getDispute", disputeID), &dispute)
+			Expect(dispute.Status).To(Equal(DisputeSettled))
+			Expect(dispute.History).To(HaveLen(6))
+			Expect(dispute.Evidence).To(HaveLen(2))
+			Expect(getOpenDisputes()).To(BeEmpty())
+
+			Expect(bed.invoke("addDisputeEvidence", disputeID, photo).Status).To(BeEquivalentTo(403))
+		})
+
+		g.It("should return 403 for a state change the party cannot make", func() {
+			Expect(update(DisputeAccepted)).To(BeEquivalentTo(403))
+			bed.as(carrierIdentity)
+			Expect(update(DisputeSettled)).To(BeEquivalentTo(403))
+			Expect(update("closed")).To(BeEquivalentTo(403))
+		})
+
+		g.It("should return 400 for a respondent that is not a participant or an invalid interval", func() {
+			response := bed.invoke("raiseDispute", `{"trackingIDs":["case-1"],"respondent":"`+retailerIdentity.subject()+`","from":"2019-03-10T08:00:00Z"}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+
+			response = bed.invoke("raiseDispute", `{"trackingIDs":["case-1"],"respondent":"`+carrier+`","from":"2019-03-12T08:00:00Z","to":"2019-03-10T08:00:00Z"}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+		})
+	})
+}
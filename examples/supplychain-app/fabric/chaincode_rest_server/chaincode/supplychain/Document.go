@@ -0,0 +1,167 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// documentKey is the composite key object type document anchors are stored under, by trackingID and hash
+const documentKey = "document"
+
+// attachDocument anchors the SHA-256 hash, media type and URI of an off-chain document to a product or container
+func (s *SmartContract) attachDocument(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	trackingID := args[0]
+
+	var request DocumentRequest
+	if err := json.Unmarshal([]byte(args[1]), &request); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := request.Validate(); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+
+	//documents are only visible to the participants of the item
+	if !itemHasParticipant(stub, trackingID, identity.Cert.Subject.String()) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Item with trackingID %s not found", trackingID),
+		}
+	}
+
+	key, _ := stub.CreateCompositeKey(documentKey, []string{trackingID, request.Hash})
+	existingBytes, err := stub.GetState(key)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(existingBytes) != 0 {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Document %s is already attached to %s", request.Hash, trackingID),
+		}
+	}
+
+	document := DocumentAnchor{
+		Type:         documentKey,
+		ID:           stub.GetTxID(),
+		TrackingID:   trackingID,
+		Name:         request.Name,
+		Hash:         request.Hash,
+		MediaType:    request.MediaType,
+		URI:          request.URI,
+		Uploader:     identity.Cert.Subject.String(),
+		Organization: identity.Organization,
+		Timestamp:    int64(s.clock.Now().UTC().Unix()),
+	}
+	documentBytes, _ := json.Marshal(document)
+	if err := stub.PutState(key, documentBytes); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	s.logger.Infof("Attached document %s to %s\n", document.Hash, trackingID)
+	return shim.Success(documentBytes)
+}
+
+// verifyDocument checks a presented SHA-256 hash against the documents anchored to a product or container
+func (s *SmartContract) verifyDocument(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	trackingID := args[0]
+	hash := strings.ToLower(args[1])
+
+	if err := ValidateHash(hash); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	if !itemHasParticipant(stub, trackingID, identity.Cert.Subject.String()) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Item with trackingID %s not found", trackingID),
+		}
+	}
+
+	key, _ := stub.CreateCompositeKey(documentKey, []string{trackingID, hash})
+	documentBytes, err := stub.GetState(key)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	response := map[string]interface{}{
+		"verified": len(documentBytes) != 0,
+	}
+	if len(documentBytes) != 0 {
+		var document DocumentAnchor
+		if err := json.Unmarshal(documentBytes, &document); err != nil {
+			return shim.Error(err.Error())
+		}
+		response["document"] = document
+	}
+	bytes, _ := json.Marshal(response)
+	return shim.Success(bytes)
+}
+
+// getDocuments retrieves every document anchored to a product or container
+func (s *SmartContract) getDocuments(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	trackingID := args[0]
+
+	if !itemHasParticipant(stub, trackingID, identity.Cert.Subject.String()) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Item with trackingID %s not found", trackingID),
+		}
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(documentKey, []string{trackingID})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer iterator.Close()
+	documents := []DocumentAnchor{}
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		var document DocumentAnchor
+		if err := json.Unmarshal(state.Value, &document); err != nil {
+			return shim.Error(err.Error())
+		}
+		documents = append(documents, document)
+	}
+	documentsBytes, _ := json.Marshal(documents)
+	return shim.Success(documentsBytes)
+}
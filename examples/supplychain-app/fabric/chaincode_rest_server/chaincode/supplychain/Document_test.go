@@ -0,0 +1,61 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestDocument(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	manufacturer := manufacturerIdentity.subject()
+	billOfLading := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	tampered := "fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9"
+
+	g.Describe("Document Anchoring", func() {
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC))
+			bed.as(manufacturerIdentity)
+			bed.mustInvoke("createContainer", `{"trackingID":"pallet-1","counterparties":[]}`)
+			bed.mustInvoke("attachDocument", "pallet-1", `{"name":"Bill of lading","hash":"`+billOfLading+`","mediaType":"application/pdf","uri":"ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"}`)
+		})
+
+		g.It("should verify the hash of an attached document", func() {
+			var result struct {
+				Verified bool           `json:"verified"`
+				Document DocumentAnchor `json:"document"`
+			}
+			json.Unmarshal(bed.mustInvoke("verifyDocument", "pallet-1", billOfLading), &result)
+			Expect(result.Verified).To(BeTrue())
+			Expect(result.Document.Uploader).To(Equal(manufacturer))
+			Expect(result.Document.MediaType).To(Equal("application/pdf"))
+		})
+
+		g.It("should not verify the hash of a tampered document", func() {
+			Expect(string(bed.mustInvoke("verifyDocument", "pallet-1", tampered))).To(Equal(`{"verified":false}`))
+		})
+
+		g.It("should return 400 for a document that is already attached or has no valid hash", func() {
+			response := bed.invoke("attachDocument", "pallet-1", `{"hash":"`+billOfLading+`","mediaType":"application/pdf","uri":"s3://docs/bol.pdf"}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+
+			response = bed.invoke("attachDocument", "pallet-1", `{"hash":"md5:abc","mediaType":"application/pdf","uri":"s3://docs/bol.pdf"}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+		})
+
+		g.It("should return 404 if the identity is not a participant", func() {
+			bed.as(carrierIdentity)
+			Expect(bed.invoke("verifyDocument", "pallet-1", billOfLading).Status).To(BeEquivalentTo(404))
+			Expect(bed.invoke("getDocuments", "pallet-1").Status).To(BeEquivalentTo(404))
+		})
+	})
+}
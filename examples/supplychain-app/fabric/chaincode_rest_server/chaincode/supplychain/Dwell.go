@@ -0,0 +1,358 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// dwellSLAsKey is the composite key object type the dwell SLAs are stored under, by organization
+const dwellSLAsKey = "dwellSLAs"
+
+// dwellStatisticsKey is the composite key object type the dwell statistics are stored under, by organization
+const dwellStatisticsKey = "dwellStatistics"
+
+// setDwellSLAs replaces the hours the products manufactured and the containers created by the organization of the
+// current user may stay with a custodian, by role
+func (s *SmartContract) setDwellSLAs(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if !identity.CanInvoke("setDwellSLAs") {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, cannot invoke setDwellSLAs"),
+		}
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	var slas DwellSLAs
+	if err := json.Unmarshal([]byte(args[0]), &slas); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := slas.Validate(); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	slas.Type = dwellSLAsKey
+	slas.Organization = identity.Organization
+
+	key, _ := stub.CreateCompositeKey(dwellSLAsKey, []string{identity.Organization})
+	slasBytes, _ := json.Marshal(slas)
+	if err := stub.PutState(key, slasBytes); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	s.logger.Infof("Updated dwell SLAs of %s\n", identity.Organization)
+	return shim.Success(slasBytes)
+}
+
+// getDwellSLAs retrieves the dwell SLAs in effect for the items of an organization, by default the organization of
+// the current user
+func (s *SmartContract) getDwellSLAs(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) > 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 0 or 1")
+	}
+	organization := identity.Organization
+	if len(args) == 1 {
+		organization = args[0]
+	}
+
+	slas, err := loadDwellSLAs(stub, organization)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	slasBytes, _ := json.Marshal(slas)
+	return shim.Success(slasBytes)
+}
+
+// getDwellTime computes how long an item the current user participates in has been with its custodian, against the
+// dwell SLAs of its manufacturer or, for containers, the organization that created it
+func (s *SmartContract) getDwellTime(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	trackingID := args[0]
+
+	if !itemHasParticipant(stub, trackingID, identity.Cert.Subject.String()) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Item with trackingID %s not found", trackingID),
+		}
+	}
+	containers, products, err := getContainerTree(stub, trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now := int64(s.clock.Now().UTC().Unix())
+	var dwell Dwell
+	if len(containers) != 0 && containers[0].ID == trackingID {
+		slas, err := loadDwellSLAs(stub, containers[0].Organization)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		dwell = NewDwell(trackingID, containers[0].Custodian, containers[0].CustodyStart(), now, slas)
+	} else {
+		slas, err := loadDwellSLAs(stub, products[0].Manufacturer)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		dwell = NewDwell(trackingID, products[0].Custodian, products[0].CustodyStart(), now, slas)
+	}
+	dwellBytes, _ := json.Marshal(dwell)
+	return shim.Success(dwellBytes)
+}
+
+// getSLABreaches retrieves the items the current user participates in that have stayed with their custodian longer
+// than the dwell SLA of its role, grouped by custodian and optionally only those of one custodian. Packaged items
+// are left out, they dwell with their container. Products are held to the dwell SLAs of their manufacturer and
+// containers to those of the organization that created them.
+func (s *SmartContract) getSLABreaches(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) > 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 0 or 1")
+	}
+	var custodian string
+	if len(args) == 1 {
+		custodian = args[0]
+	}
+
+	slasOf := dwellSLAsLoader(stub)
+	containers, products, err := getAllItems(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	now := int64(s.clock.Now().UTC().Unix())
+	dwells := []Dwell{}
+	for _, container := range containers {
+		if container.ContainerID == "" && container.AccessibleBy(identity) {
+			slas, err := slasOf(container.Organization)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			dwells = append(dwells, NewDwell(container.ID, container.Custodian, container.CustodyStart(), now, slas))
+		}
+	}
+	for _, product := range products {
+		if product.ContainerID == "" && product.AccessibleBy(identity) && !product.Sold && product.ConsumedBy == "" {
+			slas, err := slasOf(product.Manufacturer)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			dwells = append(dwells, NewDwell(product.ID, product.Custodian, product.CustodyStart(), now, slas))
+		}
+	}
+
+	byCustodian := map[string]*SLABreaches{}
+	for _, dwell := range dwells {
+		if !dwell.Breached || (custodian != "" && dwell.Custodian != custodian) {
+			continue
+		}
+		if byCustodian[dwell.Custodian] == nil {
+			byCustodian[dwell.Custodian] = &SLABreaches{Custodian: dwell.Custodian, Items: []Dwell{}}
+		}
+		byCustodian[dwell.Custodian].Items = append(byCustodian[dwell.Custodian].Items, dwell)
+	}
+	breaches := []SLABreaches{}
+	for _, custodianBreaches := range byCustodian {
+		sort.Slice(custodianBreaches.Items, func(i, j int) bool {
+			return custodianBreaches.Items[i].Seconds > custodianBreaches.Items[j].Seconds
+		})
+		breaches = append(breaches, *custodianBreaches)
+	}
+	sort.Slice(breaches, func(i, j int) bool { return breaches[i].Custodian < breaches[j].Custodian })
+
+	breachesBytes, _ := json.Marshal(breaches)
+	return shim.Success(breachesBytes)
+}
+
+// getDwellStatistics retrieves the statistics of the completed custody intervals of the organization of the current
+// user and of its counterparties, the organizations participating in the items it participates in, or of one of them
+func (s *SmartContract) getDwellStatistics(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) > 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 0 or 1")
+	}
+	counterparties, err := getCounterparties(stub, identity)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	iterator, err := stub.GetStateByPartialCompositeKey(dwellStatisticsKey, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer iterator.Close()
+	statistics := []DwellStatistics{}
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		var stats DwellStatistics
+		if err := json.Unmarshal(state.Value, &stats); err != nil {
+			return shim.Error(err.Error())
+		}
+		if counterparties[stats.Organization] {
+			statistics = append(statistics, stats)
+		}
+	}
+	statisticsBytes, _ := json.Marshal(statistics)
+	return shim.Success(statisticsBytes)
+}
+
+// recordCustodyIntervals aggregates completed custody intervals into the statistics of the organizations of their
+// custodians, each organization is written once per transaction
+func recordCustodyIntervals(stub shim.ChaincodeStubInterface, intervals []Dwell) error {
+	statistics := map[string]*DwellStatistics{}
+	organizations := []string{}
+	for _, interval := range intervals {
+		stats := statistics[interval.Organization]
+		if stats == nil {
+			key, _ := stub.CreateCompositeKey(dwellStatisticsKey, []string{interval.Organization})
+			statsBytes, err := stub.GetState(key)
+			if err != nil {
+				return err
+			}
+			stats = &DwellStatistics{Type: dwellStatisticsKey, Organization: interval.Organization}
+			if len(statsBytes) != 0 {
+				if err := json.Unmarshal(statsBytes, stats); err != nil {
+					return err
+				}
+			}
+			statistics[interval.Organization] = stats
+			organizations = append(organizations, interval.Organization)
+		}
+		stats.Add(interval)
+	}
+	for _, organization := range organizations {
+		key, _ := stub.CreateCompositeKey(dwellStatisticsKey, []string{organization})
+		statsBytes, _ := json.Marshal(statistics[organization])
+		if err := stub.PutState(key, statsBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getCounterparties returns the organization of the identity and the organizations of the participants of the items
+// the identity participates in, by the O of their subjects
+func getCounterparties(stub shim.ChaincodeStubInterface, identity *Identity) (map[string]bool, error) {
+	counterparties := map[string]bool{SubjectOrganization(identity.Cert.Subject.String()): true}
+	containers, products, err := getAllItems(stub)
+	if err != nil {
+		return nil, err
+	}
+	add := func(participants []string) {
+		for _, participant := range participants {
+			counterparties[SubjectOrganization(participant)] = true
+		}
+	}
+	for _, container := range containers {
+		if container.AccessibleBy(identity) {
+			add(container.Participants)
+		}
+	}
+	for _, product := range products {
+		if product.AccessibleBy(identity) {
+			add(product.Participants)
+		}
+	}
+	return counterparties, nil
+}
+
+// loadDwellSLAs returns the dwell SLAs stored by the organization, or the default SLAs if it set none. Items without
+// a manufacturer or an organization that created them are held to the default SLAs.
+func loadDwellSLAs(stub shim.ChaincodeStubInterface, organization string) (DwellSLAs, error) {
+	if organization == "" {
+		return DefaultDwellSLAs(), nil
+	}
+	key, _ := stub.CreateCompositeKey(dwellSLAsKey, []string{organization})
+	slasBytes, err := stub.GetState(key)
+	if err != nil || len(slasBytes) == 0 {
+		return DefaultDwellSLAs(), err
+	}
+	var slas DwellSLAs
+	err = json.Unmarshal(slasBytes, &slas)
+	return slas, err
+}
+
+// dwellSLAsLoader returns a function loading the dwell SLAs of an organization once per transaction
+func dwellSLAsLoader(stub shim.ChaincodeStubInterface) func(organization string) (DwellSLAs, error) {
+	loaded := map[string]DwellSLAs{}
+	return func(organization string) (DwellSLAs, error) {
+		if slas, ok := loaded[organization]; ok {
+			return slas, nil
+		}
+		slas, err := loadDwellSLAs(stub, organization)
+		if err != nil {
+			return slas, err
+		}
+		loaded[organization] = slas
+		return slas, nil
+	}
+}
+
+// getAllItems returns every product and container on the ledger
+func getAllItems(stub shim.ChaincodeStubInterface) ([]Container, []Product, error) {
+	iterator, err := stub.GetStateByRange("", "")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iterator.Close()
+	containers := []Container{}
+	products := []Product{}
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		var product Product
+		if err := json.Unmarshal(state.Value, &product); err == nil {
+			if product.Type == "product" {
+				products = append(products, product)
+			}
+			continue
+		}
+		var container Container
+		if err := json.Unmarshal(state.Value, &container); err == nil && container.Type == "container" {
+			containers = append(containers, container)
+		}
+	}
+	return containers, products, nil
+}
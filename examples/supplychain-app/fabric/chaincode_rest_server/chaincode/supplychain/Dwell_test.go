@@ -0,0 +1,142 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestDwell(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	shipper := org1Identity.subject()
+	carrier := carrierIdentity.subject()
+	receiver := manufacturerIdentity.subject()
+	now := time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC)
+	threeDaysAgo := now.Add(-72 * time.Hour)
+
+	getBreaches := func(args ...string) []SLABreaches {
+		var breaches []SLABreaches
+		json.Unmarshal(bed.mustInvoke("getSLABreaches", args...), &breaches)
+		return breaches
+	}
+	getDwell := func(trackingID string) Dwell {
+		var dwell Dwell
+		json.Unmarshal(bed.mustInvoke("getDwellTime", trackingID), &dwell)
+		return dwell
+	}
+	getStatistics := func(args ...string) []DwellStatistics {
+		var statistics []DwellStatistics
+		json.Unmarshal(bed.mustInvoke("getDwellStatistics", args...), &statistics)
+		return statistics
+	}
+
+	g.Describe("Dwell Time", func() {
+		//three days ago the shipper created the items and the carrier took the crate, the shipper kept the bolt
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, threeDaysAgo)
+			bed.as(org1Identity)
+			counterparties := `["` + carrier + `","` + receiver + `"]`
+			bed.mustInvoke("createProduct", `{"trackingID":"widget-1","productName":"Widget","counterparties":`+counterparties+`}`)
+			bed.mustInvoke("createProduct", `{"trackingID":"bolt-1","productName":"Bolt","counterparties":["`+carrier+`"]}`)
+			bed.mustInvoke("createContainer", `{"trackingID":"crate-1","counterparties":`+counterparties+`}`)
+			bed.mustInvoke("package", "crate-1", "widget-1")
+			bed.as(carrierIdentity)
+			bed.mustInvoke("claimContainer", "crate-1", "London")
+			bed.clock.Set(now)
+			bed.as(org1Identity)
+		})
+
+		g.It("should compute the dwell since the last custody change against the SLA of the role", func() {
+			dwell := getDwell("crate-1")
+			Expect(dwell.Role).To(Equal("Carrier"))
+			Expect(dwell.Organization).To(Equal("PartyB"))
+			Expect(dwell.Since).To(Equal(threeDaysAgo.Unix()))
+			Expect(dwell.Hours).To(BeEquivalentTo(72))
+			Expect(dwell.SLAHours).To(BeEquivalentTo(DefaultCarrierDwell))
+			Expect(dwell.Breached).To(BeTrue())
+
+			dwell = getDwell("bolt-1")
+			Expect(dwell.Role).To(Equal(""))
+			Expect(dwell.Breached).To(BeFalse())
+		})
+
+		g.It("should return the breaching containers per custodian", func() {
+			breaches := getBreaches()
+			Expect(breaches).To(HaveLen(1))
+			Expect(breaches[0].Custodian).To(Equal(carrier))
+			Expect(breaches[0].Items).To(HaveLen(1))
+			Expect(breaches[0].Items[0].TrackingID).To(Equal("crate-1"))
+			Expect(getBreaches(shipper)).To(BeEmpty())
+		})
+
+		g.It("should aggregate completed custody intervals per organization, packaged items dwell with their container", func() {
+			bed.as(manufacturerIdentity)
+			bed.mustInvoke("claimContainer", "crate-1", "Zurich")
+			Expect(getDwell("widget-1").Seconds).To(BeEquivalentTo(0))
+			Expect(getBreaches()).To(BeEmpty())
+
+			statistics := getStatistics("PartyB")
+			Expect(statistics).To(HaveLen(1))
+			Expect(statistics[0].Intervals).To(Equal(1))
+			Expect(statistics[0].Breaches).To(Equal(1))
+			Expect(statistics[0].AverageHours).To(BeEquivalentTo(72))
+			Expect(statistics[0].MaxSeconds).To(BeEquivalentTo(72 * 3600))
+		})
+
+		g.It("should let manufacturers configure the SLAs of their products and containers per role", func() {
+			bed.as(manufacturerIdentity)
+			response := bed.invoke("setDwellSLAs", `{"hours":{"Carrier":96}}`)
+			Expect(response.Status).To(BeEquivalentTo(403))
+
+			bed.as(org1Identity)
+			response = bed.invoke("setDwellSLAs", `{"hours":{"Carrier":0}}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+			bed.mustInvoke("setDwellSLAs", `{"hours":{"Carrier":96,"Org1":24}}`)
+
+			breaches := getBreaches(shipper)
+			Expect(breaches).To(HaveLen(1))
+			Expect(breaches[0].Items[0].TrackingID).To(Equal("bolt-1"))
+			Expect(breaches[0].Items[0].SLAHours).To(BeEquivalentTo(24))
+			Expect(getDwell("crate-1").SLAHours).To(BeEquivalentTo(96))
+			Expect(getBreaches(carrier)).To(BeEmpty())
+
+			var slas DwellSLAs
+			json.Unmarshal(bed.mustInvoke("getDwellSLAs", "Org1MSP"), &slas)
+			Expect(slas.Organization).To(Equal("Org1MSP"))
+			Expect(slas.Hours["Org1"]).To(BeEquivalentTo(24))
+		})
+
+		g.It("should not apply the SLAs of another manufacturer", func() {
+			bed.as(testIdentity{mspID: "Org2MSP", certPath: org1Identity.certPath})
+			bed.mustInvoke("setDwellSLAs", `{"hours":{"Org1":24}}`)
+
+			bed.as(org1Identity)
+			Expect(getBreaches(shipper)).To(BeEmpty())
+			Expect(getDwell("bolt-1").Breached).To(BeFalse())
+		})
+
+		g.It("should only return the statistics of the organization and its counterparties", func() {
+			bed.as(manufacturerIdentity)
+			bed.mustInvoke("claimContainer", "crate-1", "Zurich")
+
+			bed.as(retailerIdentity)
+			Expect(getStatistics()).To(BeEmpty())
+			Expect(getStatistics("PartyB")).To(BeEmpty())
+
+			bed.as(carrierIdentity)
+			statistics := getStatistics()
+			Expect(statistics).To(HaveLen(2))
+			Expect(statistics[0].Organization).To(Equal("PartyA"))
+			Expect(statistics[1].Organization).To(Equal("PartyB"))
+		})
+	})
+}
@@ -0,0 +1,203 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	. "github.com/chaincode/common"
+	"github.com/chaincode/epcis"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// exportEPCIS returns the history of a product, or a container and everything inside it, as an EPCIS 2.0 document
+func (s *SmartContract) exportEPCIS(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	//accept GS1 Digital Link URIs and element strings as trackingID
+	identifier, err := resolveIdentifier(stub, args[0])
+	if err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	trackingID := identifier.TrackingID
+
+	//collect the trackingID and, for containers, the tracking IDs of all contents
+	var trackingIDs []string
+	visited := map[string]bool{}
+	var collect func(trackingID string) peer.Response
+	collect = func(trackingID string) peer.Response {
+		if visited[trackingID] {
+			return shim.Success(nil)
+		}
+		visited[trackingID] = true
+		existingBytes, _ := stub.GetState(trackingID)
+		if len(existingBytes) == 0 {
+			return peer.Response{
+				Status:  404,
+				Message: fmt.Sprintf("Item with trackingID %s not found", trackingID),
+			}
+		}
+		trackingIDs = append(trackingIDs, trackingID)
+
+		var product Product
+		if err := json.Unmarshal(existingBytes, &product); err == nil {
+			if !product.AccessibleBy(identity) {
+				return peer.Response{
+					Status:  404,
+					Message: fmt.Sprintf("Item with trackingID %s not found", trackingID),
+				}
+			}
+			return shim.Success(nil)
+		}
+		var container Container
+		if err := json.Unmarshal(existingBytes, &container); err != nil {
+			return shim.Error(err.Error())
+		}
+		if !container.AccessibleBy(identity) {
+			return peer.Response{
+				Status:  404,
+				Message: fmt.Sprintf("Item with trackingID %s not found", trackingID),
+			}
+		}
+		for _, contentID := range container.Contents {
+			if response := collect(contentID); response.Status != shim.OK {
+				return response
+			}
+		}
+		return shim.Success(nil)
+	}
+	if response := collect(trackingID); response.Status != shim.OK {
+		return response
+	}
+
+	var events []epcis.Event
+	for _, trackingID := range trackingIDs {
+		revisions, err := epcisRevisions(stub, trackingID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		events = append(events, epcis.Events(revisions)...)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].EventTime < events[j].EventTime
+	})
+
+	documentBytes, _ := json.Marshal(epcis.NewDocument(events, s.clock.Now()))
+	return shim.Success(documentBytes)
+}
+
+// importEPCIS replays the commissioning, packing and title transfer events of an EPCIS 2.0 document as transactions
+func (s *SmartContract) importEPCIS(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 or 2")
+	}
+
+	var document epcis.Document
+	if err := json.Unmarshal([]byte(args[0]), &document); err != nil {
+		return shim.Error(err.Error())
+	}
+	var participants []string
+	if len(args) == 2 {
+		if err := json.Unmarshal([]byte(args[1]), &participants); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	calls, err := epcis.Import(document, participants)
+	if err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+
+	for _, call := range calls {
+		var response peer.Response
+		switch call.Function {
+		case "createProduct":
+			response = s.createProduct(stub, call.Args)
+		case "createContainer":
+			response = s.createContainer(stub, call.Args)
+		case "package":
+			response = s.packageItem(stub, call.Args)
+		case "transferOwnership":
+			//titles handed on with their container are transferred already
+			owner, err := itemOwner(stub, call.Args[0])
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			if owner == call.Args[1] {
+				continue
+			}
+			response = s.transferOwnership(stub, call.Args)
+		}
+		if response.Status != shim.OK {
+			return response
+		}
+	}
+
+	callsBytes, _ := json.Marshal(calls)
+	s.logger.Infof("Imported %d EPCIS transactions\n", len(calls))
+	return shim.Success(callsBytes)
+}
+
+// itemOwner returns the current owner of a product or container
+func itemOwner(stub shim.ChaincodeStubInterface, trackingID string) (string, error) {
+	containers, products, err := getContainerTree(stub, trackingID)
+	if err != nil {
+		return "", err
+	}
+	if len(containers) != 0 && containers[0].ID == trackingID {
+		return containers[0].CurrentOwner(), nil
+	}
+	return products[0].CurrentOwner(), nil
+}
+
+// epcisRevisions reads every revision of a trackingID from the ledger history
+func epcisRevisions(stub shim.ChaincodeStubInterface, trackingID string) ([]epcis.State, error) {
+	iterator, err := stub.GetHistoryForKey(trackingID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting history iterator: %s", err)
+	}
+	defer iterator.Close()
+
+	var revisions []epcis.State
+	for iterator.HasNext() {
+		record, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("Error accessing history: %s", err)
+		}
+		if record.IsDelete {
+			continue
+		}
+		var recordTime time.Time
+		if record.Timestamp != nil {
+			recordTime = time.Unix(record.Timestamp.Seconds, int64(record.Timestamp.Nanos))
+		}
+
+		var product Product
+		if err := json.Unmarshal(record.Value, &product); err == nil {
+			revisions = append(revisions, epcis.ProductState(product, recordTime))
+			continue
+		}
+		var container Container
+		if err := json.Unmarshal(record.Value, &container); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, epcis.ContainerState(container, recordTime))
+	}
+	return revisions, nil
+}
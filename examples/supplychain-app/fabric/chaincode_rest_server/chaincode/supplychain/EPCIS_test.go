@@ -0,0 +1,68 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+	"github.com/chaincode/epcis"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestEPCIS(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	manufacturer := org1Identity.subject()
+	carrier := carrierIdentity.subject()
+	now := time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC)
+
+	g.Describe("EPCIS", func() {
+		g.BeforeEach(func() {
+			bed = newLedgerTestbed(now)
+			bed.as(org1Identity)
+			bed.mustInvoke("createContainer", `{"trackingID":"crate-1","lastScannedAt":"Zurich","counterparties":["`+carrier+`"]}`)
+			bed.mustInvoke("createProduct", `{"trackingID":"widget-1","productName":"Widget","lot":"L1","lastScannedAt":"Zurich","counterparties":["`+carrier+`"]}`)
+			bed.mustInvoke("package", "crate-1", "widget-1")
+			bed.mustInvoke("transferOwnership", "crate-1", carrier, "INV-1")
+		})
+
+		g.It("should export commissioning, packing and title transfers of a container tree", func() {
+			var document epcis.Document
+			json.Unmarshal(bed.mustInvoke("exportEPCIS", "crate-1"), &document)
+
+			types := map[string]int{}
+			var transfer epcis.Event
+			for _, event := range document.Body.EventList {
+				types[event.Type]++
+				if event.Type == epcis.TransferEvent && event.EPCList[0] == epcis.EPC("widget-1") {
+					transfer = event
+				}
+			}
+			Expect(types).To(Equal(map[string]int{epcis.ObjectEvent: 2, epcis.AggregationEvent: 1, epcis.TransferEvent: 2}))
+			Expect(transfer.BizStep).To(Equal("accepting"))
+			Expect(transfer.SourceList).To(Equal([]epcis.Source{{Type: epcis.OwningParty, Source: epcis.PartyID(manufacturer)}}))
+			Expect(transfer.DestinationList).To(Equal([]epcis.Destination{{Type: epcis.OwningParty, Destination: epcis.PartyID(carrier)}}))
+		})
+
+		g.It("should import an exported document with its owner", func() {
+			document := bed.mustInvoke("exportEPCIS", "crate-1")
+			bed = newLedgerTestbed(now)
+			bed.as(org1Identity)
+			var calls []epcis.Call
+			json.Unmarshal(bed.mustInvoke("importEPCIS", string(document)), &calls)
+			Expect(calls).To(HaveLen(5))
+
+			var product Product
+			json.Unmarshal(bed.mustInvoke("getProduct", "widget-1"), &product)
+			Expect(product.Lot).To(Equal("L1"))
+			Expect(product.ContainerID).To(Equal("crate-1"))
+			Expect(product.Owner).To(Equal(carrier))
+			Expect(product.Custodian).To(Equal(manufacturer))
+		})
+	})
+}
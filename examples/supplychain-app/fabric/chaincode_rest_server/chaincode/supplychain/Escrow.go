@@ -0,0 +1,192 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// The Escrow settles the payment of purchase orders, the value of an accepted order is locked when the buyer funds it
+// and released to the seller as deliveries against it are confirmed
+type Escrow interface {
+	// Lock locks the amount from the buyer for the purchase order and returns the reference of the lock
+	Lock(stub shim.ChaincodeStubInterface, po PurchaseOrder, amount float64) (string, error)
+	// Release releases the amount of the lock of the purchase order to the seller
+	Release(stub shim.ChaincodeStubInterface, po PurchaseOrder, amount float64) error
+}
+
+// chaincodeEscrow settles through a token or escrow chaincode on the channel, invoking its lock and release functions
+type chaincodeEscrow struct {
+	chaincode string
+	channel   string
+}
+
+func newChaincodeEscrow(chaincode string, channel string) Escrow {
+	return &chaincodeEscrow{chaincode: chaincode, channel: channel}
+}
+
+// Lock invokes lock(purchaseOrderID, buyer, seller, amount, currency), the payload of the response is the reference
+func (escrow *chaincodeEscrow) Lock(stub shim.ChaincodeStubInterface, po PurchaseOrder, amount float64) (string, error) {
+	args := [][]byte{[]byte("lock"), []byte(po.ID), []byte(po.Buyer), []byte(po.Seller), []byte(formatAmount(amount)), []byte(po.Currency)}
+	response := stub.InvokeChaincode(escrow.chaincode, args, escrow.channel)
+	if response.Status != shim.OK {
+		return "", fmt.Errorf("Escrow chaincode %s failed to lock: %s", escrow.chaincode, response.Message)
+	}
+	if len(response.Payload) == 0 {
+		return po.ID, nil
+	}
+	return string(response.Payload), nil
+}
+
+// Release invokes release(reference, seller, amount)
+func (escrow *chaincodeEscrow) Release(stub shim.ChaincodeStubInterface, po PurchaseOrder, amount float64) error {
+	if po.Escrow == nil {
+		return errors.New("Purchase order has no escrow")
+	}
+	args := [][]byte{[]byte("release"), []byte(po.Escrow.Reference), []byte(po.Seller), []byte(formatAmount(amount))}
+	response := stub.InvokeChaincode(escrow.chaincode, args, escrow.channel)
+	if response.Status != shim.OK {
+		return fmt.Errorf("Escrow chaincode %s failed to release: %s", escrow.chaincode, response.Message)
+	}
+	return nil
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
+
+// fundPurchaseOrder locks the value of an accepted purchase order of which the current user is the buyer in the
+// escrow chaincode named in the order, so the funds are locked in a transaction signed by the buyer
+func (s *SmartContract) fundPurchaseOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	po, response := getPartyPurchaseOrder(stub, args[0], identity)
+	if po == nil {
+		return response
+	}
+	if po.Buyer != identity.Cert.Subject.String() {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, only the buyer can fund"),
+		}
+	}
+	if po.Status != PurchaseOrderAccepted {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Purchase order %s is %s", po.ID, po.Status),
+		}
+	}
+	if po.Escrow != nil {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Purchase order %s is funded", po.ID),
+		}
+	}
+	amount := po.Amount()
+	if po.Settlement == nil || amount <= 0 {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: purchase order %s has no escrow chaincode or no value ", po.ID),
+		}
+	}
+
+	reference, err := s.escrow(po.Settlement.Chaincode, po.Settlement.Channel).Lock(stub, *po, amount)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	po.Escrow = &EscrowAccount{
+		Chaincode: po.Settlement.Chaincode,
+		Channel:   po.Settlement.Channel,
+		Reference: reference,
+		Amount:    amount,
+		Status:    EscrowLocked,
+		Timestamp: int64(s.clock.Now().UTC().Unix()),
+	}
+	//quantities delivered before the order was funded are released right away
+	if err := s.releaseEscrow(stub, po); err != nil {
+		s.logger.Warningf("Release of purchase order %s is pending: %s\n", po.ID, err)
+	}
+	if err := putPurchaseOrder(stub, *po); err != nil {
+		return shim.Error(err.Error())
+	}
+	poBytes, _ := json.Marshal(po)
+
+	s.logger.Infof("Locked %s %s for purchase order %s\n", formatAmount(amount), po.Currency, po.ID)
+	return shim.Success(poBytes)
+}
+
+// releasePurchaseOrder retries the pending release of a purchase order of which the current user is the buyer or
+// seller, releasing the value of the quantities fulfilled since the last successful release
+func (s *SmartContract) releasePurchaseOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	po, response := getPartyPurchaseOrder(stub, args[0], identity)
+	if po == nil {
+		return response
+	}
+	if po.Escrow == nil || po.Escrow.Pending <= 0 {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Purchase order %s has no pending release", po.ID),
+		}
+	}
+
+	if err := s.releaseEscrow(stub, po); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := putPurchaseOrder(stub, *po); err != nil {
+		return shim.Error(err.Error())
+	}
+	poBytes, _ := json.Marshal(po)
+
+	s.logger.Infof("Released pending escrow of purchase order %s\n", po.ID)
+	return shim.Success(poBytes)
+}
+
+// releaseEscrow releases the value of the quantities of a purchase order fulfilled since the last release, through
+// the chaincode the funds were locked in. A release that fails is recorded as pending with its error and the error
+// returned, the next release includes the pending amount.
+func (s *SmartContract) releaseEscrow(stub shim.ChaincodeStubInterface, po *PurchaseOrder) error {
+	if po.Escrow == nil || po.Escrow.Status != EscrowLocked {
+		return nil
+	}
+	due := po.DueAmount()
+	if due <= 0 {
+		return nil
+	}
+	po.Escrow.Timestamp = int64(s.clock.Now().UTC().Unix())
+	if err := s.escrow(po.Escrow.Chaincode, po.Escrow.Channel).Release(stub, *po, due); err != nil {
+		po.Escrow.Pending = due
+		po.Escrow.Error = err.Error()
+		return err
+	}
+	po.Escrow.Released = RoundQuantity(po.Escrow.Released + due)
+	po.Escrow.Pending = 0
+	po.Escrow.Error = ""
+	if EqualQuantity(po.Escrow.Released, po.Escrow.Amount) {
+		po.Escrow.Status = EscrowReleased
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+	. "github.com/onsi/gomega"
+)
+
+// mockEscrow records the funds locked and released per purchase order instead of invoking an escrow chaincode
+type mockEscrow struct {
+	chaincode string
+	locked    map[string]float64
+	released  map[string]float64
+	fail      bool
+}
+
+func (escrow *mockEscrow) Lock(stub shim.ChaincodeStubInterface, po PurchaseOrder, amount float64) (string, error) {
+	if escrow.fail {
+		return "", errors.New("insufficient funds")
+	}
+	escrow.locked[po.ID] += amount
+	return "lock-" + po.ID, nil
+}
+
+func (escrow *mockEscrow) Release(stub shim.ChaincodeStubInterface, po PurchaseOrder, amount float64) error {
+	if escrow.fail {
+		return errors.New("escrow unavailable")
+	}
+	escrow.released[po.Escrow.Reference] += amount
+	return nil
+}
+
+// mockToken is a token chaincode that records the lock and release invocations it receives
+type mockToken struct {
+	calls []string
+	fail  bool
+}
+
+func (token *mockToken) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	return shim.Success(nil)
+}
+
+func (token *mockToken) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	if token.fail {
+		return shim.Error("insufficient funds")
+	}
+	function, args := stub.GetFunctionAndParameters()
+	token.calls = append(token.calls, function+"("+strings.Join(args, ",")+")")
+	if function == "lock" {
+		return shim.Success([]byte("lock-" + args[0]))
+	}
+	return shim.Success(nil)
+}
+
+func TestEscrow(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	var escrow *mockEscrow
+	chaincode := new(SmartContract)
+	seller := org1Identity.subject()
+	buyer := manufacturerIdentity.subject()
+	signature := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	gtin := "09506000134352"
+	widget1 := FormatSGTIN(gtin, "1")
+	widget2 := FormatSGTIN(gtin, "2")
+
+	//the seller packs two widgets and a bolt into a crate the buyer takes custody of, the seller keeps the title
+	startTestbed := func() {
+		bed = newTestbed(chaincode, time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC))
+		bed.as(org1Identity)
+		bed.mustInvoke("createContainer", `{"trackingID":"crate-1","counterparties":["`+buyer+`"]}`)
+		for _, product := range []struct{ id, name string }{{widget1, "Widget"}, {widget2, "Widget"}, {"bolt-1", "Bolt"}} {
+			bed.mustInvoke("createProduct", `{"trackingID":"`+product.id+`","productName":"`+product.name+`","counterparties":["`+buyer+`"]}`)
+			bed.mustInvoke("package", "crate-1", product.id)
+		}
+		bed.as(manufacturerIdentity)
+		bed.mustInvoke("claimContainer", "crate-1", "Zurich")
+	}
+	order := func(lines string, escrow string) {
+		bed.as(manufacturerIdentity)
+		bed.mustInvoke("createPurchaseOrder", `{"purchaseOrderID":"PO-1","seller":"`+seller+`","currency":"EUR","escrow":`+escrow+`,"lines":`+lines+`}`)
+		bed.as(org1Identity)
+		bed.mustInvoke("acceptPurchaseOrder", "PO-1")
+		bed.mustInvoke("linkPurchaseOrder", "PO-1", "crate-1")
+	}
+	fund := func() int32 {
+		bed.as(manufacturerIdentity)
+		return bed.invoke("fundPurchaseOrder", "PO-1").Status
+	}
+	deliver := func(scanned string) {
+		bed.as(manufacturerIdentity)
+		bed.mustInvoke("confirmDelivery", "crate-1", `{"scanned":`+scanned+`,"signatureHash":"`+signature+`","sender":"`+seller+`"}`)
+	}
+	getPurchaseOrder := func() PurchaseOrder {
+		var po PurchaseOrder
+		json.Unmarshal(bed.mustInvoke("getPurchaseOrder", "PO-1"), &po)
+		return po
+	}
+
+	g.Describe("Escrow", func() {
+		token := `{"chaincode":"token"}`
+
+		g.BeforeEach(func() {
+			startTestbed()
+			escrow = &mockEscrow{locked: map[string]float64{}, released: map[string]float64{}}
+			chaincode.escrow = func(name string, channel string) Escrow {
+				escrow.chaincode = name
+				return escrow
+			}
+		})
+
+		g.It("should lock the order value when the buyer funds it and release it as deliveries are confirmed", func() {
+			order(`[{"gtin":"`+gtin+`","quantity":3,"price":10},{"productName":"Bolt","quantity":1,"price":5}]`, token)
+			Expect(escrow.locked).To(BeEmpty())
+			Expect(fund()).To(BeEquivalentTo(200))
+			Expect(escrow.chaincode).To(Equal("token"))
+			Expect(escrow.locked["PO-1"]).To(BeEquivalentTo(35))
+			po := getPurchaseOrder()
+			Expect(po.Escrow.Reference).To(Equal("lock-PO-1"))
+			Expect(po.Escrow.Status).To(Equal(EscrowLocked))
+
+			deliver(`["` + widget1 + `","` + widget2 + `","bolt-1"]`)
+			Expect(escrow.released["lock-PO-1"]).To(BeEquivalentTo(25))
+			po = getPurchaseOrder()
+			Expect(po.Escrow.Released).To(BeEquivalentTo(25))
+			Expect(po.Escrow.Status).To(Equal(EscrowLocked))
+		})
+
+		g.It("should release the remaining funds once the order is fulfilled", func() {
+			order(`[{"gtin":"`+gtin+`","quantity":2,"price":10}]`, token)
+			fund()
+			deliver(`["` + widget1 + `","` + widget2 + `","bolt-1"]`)
+			Expect(escrow.released["lock-PO-1"]).To(BeEquivalentTo(20))
+			Expect(getPurchaseOrder().Escrow.Status).To(Equal(EscrowReleased))
+		})
+
+		g.It("should only let the buyer fund an accepted order once", func() {
+			order(`[{"gtin":"`+gtin+`","quantity":2,"price":10}]`, token)
+			Expect(bed.invoke("fundPurchaseOrder", "PO-1").Status).To(BeEquivalentTo(403))
+			Expect(fund()).To(BeEquivalentTo(200))
+			Expect(fund()).To(BeEquivalentTo(403))
+			Expect(escrow.locked["PO-1"]).To(BeEquivalentTo(20))
+		})
+
+		g.It("should not fund orders without an escrow chaincode", func() {
+			order(`[{"gtin":"`+gtin+`","quantity":2,"price":10}]`, "null")
+			Expect(fund()).To(BeEquivalentTo(400))
+			Expect(getPurchaseOrder().Escrow).To(BeNil())
+		})
+
+		g.It("should reject an escrow without a chaincode name", func() {
+			response := bed.invoke("createPurchaseOrder", `{"purchaseOrderID":"PO-1","seller":"`+seller+`","escrow":{"chaincode":" "},"lines":[{"gtin":"`+gtin+`","quantity":2}]}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+		})
+
+		g.It("should not fund the order if the funds cannot be locked", func() {
+			order(`[{"gtin":"`+gtin+`","quantity":2,"price":10}]`, token)
+			escrow.fail = true
+			Expect(fund()).To(BeEquivalentTo(500))
+			Expect(getPurchaseOrder().Escrow).To(BeNil())
+		})
+
+		g.It("should record a failed release as pending and retry it", func() {
+			order(`[{"gtin":"`+gtin+`","quantity":2,"price":10}]`, token)
+			fund()
+			escrow.fail = true
+			deliver(`["` + widget1 + `","` + widget2 + `","bolt-1"]`)
+			po := getPurchaseOrder()
+			Expect(po.Status).To(Equal(PurchaseOrderFulfilled))
+			Expect(po.Escrow.Pending).To(BeEquivalentTo(20))
+			Expect(po.Escrow.Error).To(Equal("escrow unavailable"))
+			Expect(po.Escrow.Released).To(BeEquivalentTo(0))
+
+			Expect(bed.invoke("releasePurchaseOrder", "PO-1").Status).To(BeEquivalentTo(500))
+
+			escrow.fail = false
+			bed.as(org1Identity)
+			bed.mustInvoke("releasePurchaseOrder", "PO-1")
+			Expect(escrow.released["lock-PO-1"]).To(BeEquivalentTo(20))
+			po = getPurchaseOrder()
+			Expect(po.Escrow.Pending).To(BeEquivalentTo(0))
+			Expect(po.Escrow.Error).To(BeEmpty())
+			Expect(po.Escrow.Status).To(Equal(EscrowReleased))
+
+			Expect(bed.invoke("releasePurchaseOrder", "PO-1").Status).To(BeEquivalentTo(403))
+		})
+	})
+
+	g.Describe("Chaincode escrow", func() {
+		var tokenChaincode *mockToken
+
+		g.BeforeEach(func() {
+			startTestbed()
+			chaincode.escrow = newChaincodeEscrow
+			tokenChaincode = &mockToken{calls: []string{}}
+			bed.stub.MockPeerChaincode("token/payments", shim.NewMockStub("token", tokenChaincode))
+		})
+
+		g.It("should invoke lock and release on the token chaincode of the order", func() {
+			order(`[{"gtin":"`+gtin+`","quantity":3,"price":10}]`, `{"chaincode":"token","channel":"payments"}`)
+			Expect(fund()).To(BeEquivalentTo(200))
+			Expect(tokenChaincode.calls).To(Equal([]string{"lock(PO-1," + buyer + "," + seller + ",30,EUR)"}))
+			po := getPurchaseOrder()
+			Expect(po.Escrow.Reference).To(Equal("lock-PO-1"))
+			Expect(po.Escrow.Channel).To(Equal("payments"))
+
+			deliver(`["` + widget1 + `","` + widget2 + `","bolt-1"]`)
+			Expect(tokenChaincode.calls).To(HaveLen(2))
+			Expect(tokenChaincode.calls[1]).To(Equal("release(lock-PO-1," + seller + ",20)"))
+			Expect(getPurchaseOrder().Escrow.Released).To(BeEquivalentTo(20))
+		})
+
+		g.It("should record a release the token chaincode rejects as pending", func() {
+			order(`[{"gtin":"`+gtin+`","quantity":3,"price":10}]`, `{"chaincode":"token","channel":"payments"}`)
+			fund()
+			tokenChaincode.fail = true
+			deliver(`["` + widget1 + `","` + widget2 + `","bolt-1"]`)
+			po := getPurchaseOrder()
+			Expect(po.Escrow.Pending).To(BeEquivalentTo(20))
+			Expect(po.Escrow.Error).To(ContainSubstring("insufficient funds"))
+		})
+	})
+}
@@ -0,0 +1,129 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// healthRulesKey is the composite key object type the health rules are stored under, by manufacturer organization
+const healthRulesKey = "healthRules"
+
+// setHealthRules replaces the allowed health transitions, reason codes and blocked actions of the products manufactured
+// by the organization of the current user
+func (s *SmartContract) setHealthRules(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if !identity.CanInvoke("setHealthRules") {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, cannot invoke setHealthRules"),
+		}
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	var rules HealthRules
+	if err := json.Unmarshal([]byte(args[0]), &rules); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := rules.Validate(); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	rules.Type = healthRulesKey
+	rules.Organization = identity.Organization
+
+	key, _ := stub.CreateCompositeKey(healthRulesKey, []string{identity.Organization})
+	rulesBytes, _ := json.Marshal(rules)
+	if err := stub.PutState(key, rulesBytes); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	s.logger.Infof("Updated health rules of %s\n", identity.Organization)
+	return shim.Success(rulesBytes)
+}
+
+// getHealthRules retrieves the health rules in effect for the products of a manufacturer organization, by default
+// the organization of the current user
+func (s *SmartContract) getHealthRules(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) > 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 0 or 1")
+	}
+	organization := identity.Organization
+	if len(args) == 1 {
+		organization = args[0]
+	}
+
+	rules, err := loadHealthRules(stub, organization)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	rulesBytes, _ := json.Marshal(rules)
+	return shim.Success(rulesBytes)
+}
+
+// loadHealthRules returns the health rules stored by the organization, or the default rules if it set none. Products
+// follow the rules of their manufacturer and containers those of the organization that created them, items without
+// either follow the default rules.
+func loadHealthRules(stub shim.ChaincodeStubInterface, organization string) (HealthRules, error) {
+	if organization == "" {
+		return DefaultHealthRules(), nil
+	}
+	key, _ := stub.CreateCompositeKey(healthRulesKey, []string{organization})
+	rulesBytes, err := stub.GetState(key)
+	if err != nil || len(rulesBytes) == 0 {
+		return DefaultHealthRules(), err
+	}
+	var rules HealthRules
+	err = json.Unmarshal(rulesBytes, &rules)
+	return rules, err
+}
+
+// checkHealth returns a 403 response if the health of any of the items blocks the action under the rules of its
+// manufacturer or, for containers, the organization that created it
+func checkHealth(stub shim.ChaincodeStubInterface, action string, containers []Container, products []Product) peer.Response {
+	for _, container := range containers {
+		rules, err := loadHealthRules(stub, container.Organization)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if err := rules.Allows(container.Health, action); err != nil {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("Container %s: %s", container.ID, err),
+			}
+		}
+	}
+	for _, product := range products {
+		rules, err := loadHealthRules(stub, product.Manufacturer)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if err := rules.Allows(product.Health, action); err != nil {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("Product %s: %s", product.ID, err),
+			}
+		}
+	}
+	return shim.Success(nil)
+}
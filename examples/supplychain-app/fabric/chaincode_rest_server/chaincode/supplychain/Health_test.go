@@ -0,0 +1,150 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestHealth(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	now := time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC)
+	manufacturer := manufacturerIdentity.subject()
+	carrier := carrierIdentity.subject()
+	org2Identity := testIdentity{mspID: "Org2MSP", certPath: org1Identity.certPath}
+
+	updateHealth := func(trackingID string, health string, reason string) int32 {
+		request := UpdateRequest{ID: trackingID, Health: health, Reason: reason}
+		requestBytes, _ := json.Marshal(request)
+		return bed.invoke("updateState", trackingID, string(requestBytes)).Status
+	}
+	getProduct := func(trackingID string) Product {
+		var product Product
+		json.Unmarshal(bed.mustInvoke("getProduct", trackingID), &product)
+		return product
+	}
+	getHealthRules := func(args ...string) HealthRules {
+		var rules HealthRules
+		json.Unmarshal(bed.mustInvoke("getHealthRules", args...), &rules)
+		return rules
+	}
+	restrictOK := func() string {
+		rules := DefaultHealthRules()
+		rules.Transitions[HealthOK] = []string{HealthDestroyed}
+		rulesBytes, _ := json.Marshal(rules)
+		return string(rulesBytes)
+	}
+
+	g.Describe("Health Transitions", func() {
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, now)
+			bed.as(org1Identity)
+			bed.mustInvoke("createProduct", `{"trackingID":"vaccine-1","productName":"Vaccine","counterparties":["`+carrier+`"]}`)
+		})
+
+		g.It("should change health with a reason code", func() {
+			Expect(updateHealth("vaccine-1", HealthQuarantined, "quality_hold")).To(BeEquivalentTo(200))
+
+			product := getProduct("vaccine-1")
+			Expect(product.Health).To(Equal(HealthQuarantined))
+			Expect(product.HealthReason).To(Equal("quality_hold"))
+		})
+
+		g.It("should return 400 without a known reason code", func() {
+			Expect(updateHealth("vaccine-1", HealthDamaged, "")).To(BeEquivalentTo(400))
+			Expect(updateHealth("vaccine-1", HealthDamaged, "dropped")).To(BeEquivalentTo(400))
+			Expect(getProduct("vaccine-1").Health).To(Equal(""))
+		})
+
+		g.It("should return 400 for an unknown health or a transition the rules do not allow", func() {
+			Expect(updateHealth("vaccine-1", "Healthy", "inspection")).To(BeEquivalentTo(400))
+
+			Expect(updateHealth("vaccine-1", HealthCompromised, "contamination")).To(BeEquivalentTo(200))
+			Expect(updateHealth("vaccine-1", HealthOK, "released")).To(BeEquivalentTo(400))
+		})
+
+		g.It("should keep destroyed items terminal", func() {
+			Expect(updateHealth("vaccine-1", HealthDestroyed, "disposal")).To(BeEquivalentTo(200))
+
+			Expect(updateHealth("vaccine-1", HealthOK, "recovered")).To(BeEquivalentTo(403))
+			Expect(bed.invoke("sellProduct", "vaccine-1").Status).To(BeEquivalentTo(403))
+		})
+
+		g.It("should not sell quarantined products", func() {
+			Expect(updateHealth("vaccine-1", HealthQuarantined, "quality_hold")).To(BeEquivalentTo(200))
+
+			Expect(bed.invoke("sellProduct", "vaccine-1").Status).To(BeEquivalentTo(403))
+			Expect(getProduct("vaccine-1").Sold).To(BeFalse())
+		})
+
+		g.It("should not claim quarantined products", func() {
+			Expect(updateHealth("vaccine-1", HealthQuarantined, "quality_hold")).To(BeEquivalentTo(200))
+
+			bed.as(carrierIdentity)
+			Expect(bed.invoke("claimProduct", "vaccine-1", "London").Status).To(BeEquivalentTo(403))
+			Expect(getProduct("vaccine-1").Custodian).To(Equal(org1Identity.subject()))
+		})
+	})
+
+	g.Describe("Health Rules", func() {
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, now)
+		})
+
+		g.It("should return the default rules until rules are set", func() {
+			bed.as(manufacturerIdentity)
+
+			Expect(getHealthRules()).To(Equal(DefaultHealthRules()))
+		})
+
+		g.It("should apply the rules of the manufacturer of a product", func() {
+			//Org1 and Org2 each make a vaccine which the manufacturer takes custody of
+			bed.as(org1Identity)
+			bed.mustInvoke("createProduct", `{"trackingID":"vaccine-1","productName":"Vaccine","counterparties":["`+manufacturer+`"]}`)
+			bed.as(org2Identity)
+			bed.mustInvoke("createProduct", `{"trackingID":"vaccine-2","productName":"Vaccine","counterparties":["`+manufacturer+`"]}`)
+			bed.as(manufacturerIdentity)
+			bed.mustInvoke("claimProduct", "vaccine-1", "Zurich")
+			bed.mustInvoke("claimProduct", "vaccine-2", "Zurich")
+
+			bed.as(org1Identity)
+			bed.mustInvoke("setHealthRules", restrictOK())
+
+			Expect(getHealthRules("Org2MSP")).To(Equal(DefaultHealthRules()))
+			stored := getHealthRules()
+			Expect(stored.Organization).To(Equal("Org1MSP"))
+			Expect(stored.Transitions[HealthOK]).To(Equal([]string{HealthDestroyed}))
+
+			bed.as(manufacturerIdentity)
+			Expect(updateHealth("vaccine-1", HealthQuarantined, "quality_hold")).To(BeEquivalentTo(400))
+			Expect(updateHealth("vaccine-2", HealthQuarantined, "quality_hold")).To(BeEquivalentTo(200))
+		})
+
+		g.It("should apply the rules of the organization that created a container", func() {
+			bed.as(org1Identity)
+			bed.mustInvoke("setHealthRules", restrictOK())
+			bed.mustInvoke("createContainer", `{"trackingID":"crate-1"}`)
+			bed.as(org2Identity)
+			bed.mustInvoke("createContainer", `{"trackingID":"crate-2"}`)
+
+			Expect(updateHealth("crate-1", HealthQuarantined, "quality_hold")).To(BeEquivalentTo(400))
+			Expect(updateHealth("crate-2", HealthQuarantined, "quality_hold")).To(BeEquivalentTo(200))
+		})
+
+		g.It("should return 403 if the identity cannot set health rules", func() {
+			bed.as(carrierIdentity)
+			rules, _ := json.Marshal(DefaultHealthRules())
+
+			Expect(bed.invoke("setHealthRules", string(rules)).Status).To(BeEquivalentTo(403))
+		})
+	})
+}
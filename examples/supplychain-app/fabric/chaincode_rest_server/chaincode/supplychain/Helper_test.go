@@ -0,0 +1,260 @@
+package supplychain
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	"github.com/hyperledger/fabric/protos/msp"
+	"github.com/hyperledger/fabric/protos/peer"
+	. "github.com/onsi/gomega"
+)
+
+// testIdentity is a test user, the certificate it signs with under an MSP
+type testIdentity struct {
+	mspID    string
+	certPath string
+}
+
+var (
+	org1Identity         = testIdentity{mspID: "Org1MSP", certPath: "../testdata/org1.pem"}
+	manufacturerIdentity = testIdentity{mspID: "PartyAMSP", certPath: "../testdata/manufacturer.pem"}
+	carrierIdentity      = testIdentity{mspID: "PartyBMSP", certPath: "../testdata/carrier.pem"}
+	retailerIdentity     = testIdentity{mspID: "PartyCMSP", certPath: "../testdata/retailer.pem"}
+)
+
+// subject returns the distinguished name of the certificate, custodians and participants are recorded by it
+func (id testIdentity) subject() string {
+	certBytes, _ := ioutil.ReadFile(id.certPath)
+	block, _ := pem.Decode(certBytes)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		panic(err)
+	}
+	return cert.Subject.String()
+}
+
+// testbed runs the chaincode on a mock stub with a mock clock, invoking it as one test identity after another in
+// numbered transactions so fixtures are built through the chaincode functions
+type testbed struct {
+	stub  *shim.MockStub
+	clock *clock.Mock
+	tx    int
+}
+
+// newTestbed initializes the chaincode with its clock set to now
+func newTestbed(chaincode *SmartContract, now time.Time) *testbed {
+	return startTestbed(chaincode, chaincode, now)
+}
+
+// newLedgerTestbed initializes a ledgerChaincode with its clock set to now, for tests of the history of keys and of
+// CouchDB queries
+func newLedgerTestbed(now time.Time) *testbed {
+	chaincode := newLedgerChaincode()
+	return startTestbed(chaincode, chaincode.SmartContract, now)
+}
+
+func startTestbed(chaincode shim.Chaincode, contract *SmartContract, now time.Time) *testbed {
+	stub := shim.NewMockStub("mockstub", chaincode)
+	stub.MockInit("init", nil)
+	contract.logger.SetLevel(shim.LogError)
+	mockClock := clock.NewMock()
+	mockClock.Set(now)
+	contract.clock = mockClock
+	return &testbed{stub: stub, clock: mockClock}
+}
+
+// as makes the identity the creator of the transactions that follow
+func (bed *testbed) as(id testIdentity) {
+	certBytes, _ := ioutil.ReadFile(id.certPath)
+	creator, _ := proto.Marshal(&msp.SerializedIdentity{Mspid: id.mspID, IdBytes: certBytes})
+	bed.stub.Creator = creator
+}
+
+// invoke invokes a function of the chaincode in the next transaction
+func (bed *testbed) invoke(function string, args ...string) peer.Response {
+	bed.tx++
+	invokeArgs := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+	return bed.stub.MockInvoke(fmt.Sprintf("tx%d", bed.tx), invokeArgs)
+}
+
+// mustInvoke invokes a function of the chaincode like invoke, failing the test unless it succeeds, and returns the
+// payload of the response
+func (bed *testbed) mustInvoke(function string, args ...string) []byte {
+	response := bed.invoke(function, args...)
+	ExpectWithOffset(1, response.Status).To(BeEquivalentTo(200), response.Message)
+	return response.Payload
+}
+
+// ledgerChaincode runs the chaincode against a stub that keeps the history of every key and answers CouchDB queries
+// with equality, $gt and sort, which shim.MockStub does not implement
+type ledgerChaincode struct {
+	*SmartContract
+	records map[string][]*queryresult.KeyModification
+}
+
+func newLedgerChaincode() *ledgerChaincode {
+	return &ledgerChaincode{SmartContract: new(SmartContract), records: map[string][]*queryresult.KeyModification{}}
+}
+
+// Invoke wraps the mock stub before passing the transaction on
+func (c *ledgerChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	return c.SmartContract.Invoke(&ledgerStub{MockStub: stub.(*shim.MockStub), records: c.records})
+}
+
+type ledgerStub struct {
+	*shim.MockStub
+	records map[string][]*queryresult.KeyModification
+}
+
+func (stub *ledgerStub) PutState(key string, value []byte) error {
+	if err := stub.MockStub.PutState(key, value); err != nil {
+		return err
+	}
+	txTimestamp, _ := stub.GetTxTimestamp()
+	if txTimestamp == nil {
+		txTimestamp = &timestamp.Timestamp{}
+	}
+	stub.records[key] = append(stub.records[key], &queryresult.KeyModification{
+		TxId:      stub.GetTxID(),
+		Value:     value,
+		Timestamp: txTimestamp,
+	})
+	return nil
+}
+
+func (stub *ledgerStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &historyIterator{records: stub.records[key]}, nil
+}
+
+type historyIterator struct {
+	records []*queryresult.KeyModification
+	next    int
+}
+
+func (iterator *historyIterator) HasNext() bool {
+	return iterator.next < len(iterator.records)
+}
+
+func (iterator *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if !iterator.HasNext() {
+		return nil, errors.New("No more history records")
+	}
+	iterator.next++
+	return iterator.records[iterator.next-1], nil
+}
+
+func (iterator *historyIterator) Close() error {
+	return nil
+}
+
+func (stub *ledgerStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	var request struct {
+		Selector map[string]interface{} `json:"selector"`
+		Sort     []map[string]string    `json:"sort"`
+	}
+	if err := json.Unmarshal([]byte(query), &request); err != nil {
+		return nil, err
+	}
+	iterator, err := stub.GetStateByRange("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+	var results []*queryresult.KV
+	var documents []map[string]interface{}
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var document map[string]interface{}
+		if json.Unmarshal(state.Value, &document) != nil || !selects(request.Selector, document) {
+			continue
+		}
+		results = append(results, state)
+		documents = append(documents, document)
+	}
+	sort.Sort(queryResults{results: results, documents: documents, sort: request.Sort})
+	return &queryIterator{results: results}, nil
+}
+
+func selects(selector map[string]interface{}, document map[string]interface{}) bool {
+	for field, condition := range selector {
+		value, ok := document[field]
+		if operators, isMap := condition.(map[string]interface{}); isMap {
+			if gt, isGt := operators["$gt"]; isGt && (!ok || fmt.Sprint(value) <= fmt.Sprint(gt)) {
+				return false
+			}
+			continue
+		}
+		if !ok && condition == false {
+			continue
+		}
+		if fmt.Sprint(value) != fmt.Sprint(condition) {
+			return false
+		}
+	}
+	return true
+}
+
+type queryResults struct {
+	results   []*queryresult.KV
+	documents []map[string]interface{}
+	sort      []map[string]string
+}
+
+func (q queryResults) Len() int {
+	return len(q.results)
+}
+
+func (q queryResults) Less(i, j int) bool {
+	for _, order := range q.sort {
+		for field := range order {
+			a, b := fmt.Sprint(q.documents[i][field]), fmt.Sprint(q.documents[j][field])
+			if a != b {
+				return a < b
+			}
+		}
+	}
+	return false
+}
+
+func (q queryResults) Swap(i, j int) {
+	q.results[i], q.results[j] = q.results[j], q.results[i]
+	q.documents[i], q.documents[j] = q.documents[j], q.documents[i]
+}
+
+type queryIterator struct {
+	results []*queryresult.KV
+	next    int
+}
+
+func (iterator *queryIterator) HasNext() bool {
+	return iterator.next < len(iterator.results)
+}
+
+func (iterator *queryIterator) Next() (*queryresult.KV, error) {
+	if !iterator.HasNext() {
+		return nil, errors.New("No more query results")
+	}
+	iterator.next++
+	return iterator.results[iterator.next-1], nil
+}
+
+func (iterator *queryIterator) Close() error {
+	return nil
+}
@@ -0,0 +1,209 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// identifierSchemeKey is the composite key object type identifier schemes are stored under
+const identifierSchemeKey = "identifierScheme"
+
+// ssccCounterKey is the composite key object type SSCC counters are stored under, by company prefix and extension
+// digit
+const ssccCounterKey = "ssccCounter"
+
+// setIdentifierScheme sets the trackingID rules and GS1 company prefix of the invokers organization
+func (s *SmartContract) setIdentifierScheme(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if !identity.CanInvoke("setIdentifierScheme") {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, cannot invoke setIdentifierScheme"),
+		}
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	var request IdentifierScheme
+	if err := json.Unmarshal([]byte(args[0]), &request); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := request.Validate(); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+
+	scheme, err := getIdentifierScheme(stub, identity.Organization)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	//the company prefix is registered to the first organization setting it, its counter only moves forward
+	if request.CompanyPrefix != "" {
+		counter, err := getSSCCCounter(stub, identity.Organization, request.CompanyPrefix, request.ExtensionDigit)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if counter.Organization != identity.Organization {
+			return peer.Response{
+				Status:  409,
+				Message: fmt.Sprintf("Error: GS1 company prefix %s is registered to %s ", request.CompanyPrefix, counter.Organization),
+			}
+		}
+		if err := putSSCCCounter(stub, counter); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	scheme.Scheme = request.Scheme
+	scheme.CompanyPrefix = request.CompanyPrefix
+	scheme.ExtensionDigit = request.ExtensionDigit
+
+	if err := putIdentifierScheme(stub, scheme); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	schemeBytes, _ := json.Marshal(scheme)
+	s.logger.Infof("Updated identifier scheme of %s\n", identity.Organization)
+	return shim.Success(schemeBytes)
+}
+
+// issueSSCC returns the next unused SSCC for the company prefix of the invokers organization
+func (s *SmartContract) issueSSCC(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 0 {
+		return shim.Error("Incorrect number of arguments. Expecting 0")
+	}
+
+	scheme, err := getIdentifierScheme(stub, identity.Organization)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if scheme.CompanyPrefix == "" {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("No GS1 company prefix set for %s", identity.Organization),
+		}
+	}
+
+	counter, err := getSSCCCounter(stub, identity.Organization, scheme.CompanyPrefix, scheme.ExtensionDigit)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	sscc, err := GenerateSSCC(counter.ExtensionDigit, counter.CompanyPrefix, counter.NextSerialReference)
+	if err != nil {
+		return peer.Response{
+			Status:  409,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	counter.NextSerialReference++
+	if err := putSSCCCounter(stub, counter); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	response := map[string]interface{}{
+		"sscc": sscc,
+	}
+	bytes, _ := json.Marshal(response)
+	s.logger.Infof("Issued SSCC: %s\n", sscc)
+	return shim.Success(bytes)
+}
+
+// resolveIdentifier returns the trackingID a scanned identifier refers to. Existing trackingIDs are taken as they
+// are, so items created before Digital Links were understood keep resolving, anything else is parsed as a GS1
+// Digital Link URI or element string.
+func resolveIdentifier(stub shim.ChaincodeStubInterface, input string) (Identifier, error) {
+	existingBytes, err := stub.GetState(input)
+	if err != nil {
+		return Identifier{}, err
+	}
+	if len(existingBytes) != 0 {
+		return Identifier{TrackingID: input}, nil
+	}
+	return ParseIdentifier(input)
+}
+
+// validateTrackingID checks a new trackingID against the identifier scheme of the invokers organization
+func validateTrackingID(stub shim.ChaincodeStubInterface, identity *Identity, trackingID string, isContainer bool) peer.Response {
+	scheme, err := getIdentifierScheme(stub, identity.Organization)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if scheme.Scheme != SchemeGS1 {
+		return shim.Success(nil)
+	}
+	if isContainer {
+		_, err = ParseSSCC(trackingID)
+	} else {
+		_, _, err = ParseSGTIN(trackingID)
+	}
+	if err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	return shim.Success(nil)
+}
+
+// getIdentifierScheme returns the identifier scheme of an organization, or an empty scheme if none is set
+func getIdentifierScheme(stub shim.ChaincodeStubInterface, organization string) (IdentifierScheme, error) {
+	scheme := IdentifierScheme{Type: identifierSchemeKey, Organization: organization}
+	key, err := stub.CreateCompositeKey(identifierSchemeKey, []string{organization})
+	if err != nil {
+		return scheme, err
+	}
+	schemeBytes, err := stub.GetState(key)
+	if err != nil || len(schemeBytes) == 0 {
+		return scheme, err
+	}
+	err = json.Unmarshal(schemeBytes, &scheme)
+	return scheme, err
+}
+
+// getSSCCCounter returns the SSCC counter of a company prefix and extension digit, or a new counter registered to
+// the organization if there is none
+func getSSCCCounter(stub shim.ChaincodeStubInterface, organization string, companyPrefix string, extensionDigit int) (SSCCCounter, error) {
+	counter := SSCCCounter{Type: ssccCounterKey, Organization: organization, CompanyPrefix: companyPrefix, ExtensionDigit: extensionDigit}
+	key, _ := stub.CreateCompositeKey(ssccCounterKey, []string{companyPrefix, strconv.Itoa(extensionDigit)})
+	counterBytes, err := stub.GetState(key)
+	if err != nil || len(counterBytes) == 0 {
+		return counter, err
+	}
+	err = json.Unmarshal(counterBytes, &counter)
+	return counter, err
+}
+
+func putSSCCCounter(stub shim.ChaincodeStubInterface, counter SSCCCounter) error {
+	key, _ := stub.CreateCompositeKey(ssccCounterKey, []string{counter.CompanyPrefix, strconv.Itoa(counter.ExtensionDigit)})
+	counterBytes, _ := json.Marshal(counter)
+	return stub.PutState(key, counterBytes)
+}
+
+func putIdentifierScheme(stub shim.ChaincodeStubInterface, scheme IdentifierScheme) error {
+	key, err := stub.CreateCompositeKey(identifierSchemeKey, []string{scheme.Organization})
+	if err != nil {
+		return err
+	}
+	schemeBytes, _ := json.Marshal(scheme)
+	return stub.PutState(key, schemeBytes)
+}
@@ -0,0 +1,161 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestIdentifier(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	now := time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC)
+	scheme := `{"scheme":"gs1","companyPrefix":"7610425","extensionDigit":3}`
+
+	issueSSCC := func() string {
+		var results map[string]interface{}
+		json.Unmarshal(bed.mustInvoke("issueSSCC"), &results)
+		return results["sscc"].(string)
+	}
+	scan := func(identifier string) map[string]interface{} {
+		var results map[string]interface{}
+		json.Unmarshal(bed.mustInvoke("scan", identifier), &results)
+		return results
+	}
+
+	g.Describe("GS1 identifiers", func() {
+		g.It("should validate check digits", func() {
+			Expect(ValidateGTIN("09506000134352")).To(BeNil())
+			Expect(ValidateGTIN("09506000134353")).NotTo(BeNil())
+			_, _, err := ParseSGTIN("(01)09506000134352(21)ABC-123")
+			Expect(err).To(BeNil())
+			_, _, err = ParseSGTIN("(01)09506000134353(21)ABC-123")
+			Expect(err).NotTo(BeNil())
+			_, err = ParseSSCC("(00)376104250021234569")
+			Expect(err).To(BeNil())
+			_, err = ParseSSCC("(00)376104250021234568")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	g.Describe("Issue SSCC", func() {
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, now)
+			bed.as(org1Identity)
+		})
+
+		g.It("should return 404 if no company prefix is set", func() {
+			Expect(bed.invoke("issueSSCC").Status).To(BeEquivalentTo(404))
+		})
+
+		g.It("should issue consecutive SSCCs for the company prefix", func() {
+			bed.mustInvoke("setIdentifierScheme", scheme)
+
+			Expect(issueSSCC()).To(Equal("(00)376104250000000000"))
+			Expect(issueSSCC()).To(Equal("(00)376104250000000017"))
+		})
+
+		g.It("should keep counting when the scheme is set again", func() {
+			bed.mustInvoke("setIdentifierScheme", scheme)
+			issueSSCC()
+			bed.mustInvoke("setIdentifierScheme", scheme)
+
+			Expect(issueSSCC()).To(Equal("(00)376104250000000017"))
+		})
+
+		g.It("should only let manufacturers set their own company prefix", func() {
+			bed.mustInvoke("setIdentifierScheme", scheme)
+
+			bed.as(manufacturerIdentity)
+			Expect(bed.invoke("setIdentifierScheme", scheme).Status).To(BeEquivalentTo(403))
+			bed.as(testIdentity{mspID: "Org2MSP", certPath: org1Identity.certPath})
+			Expect(bed.invoke("setIdentifierScheme", scheme).Status).To(BeEquivalentTo(409))
+		})
+
+		g.It("should reject invalid company prefixes", func() {
+			response := bed.invoke("setIdentifierScheme", `{"scheme":"gs1","companyPrefix":"61A4250"}`)
+
+			Expect(response.Status).To(BeEquivalentTo(400))
+		})
+	})
+
+	g.Describe("Create Container with GS1 scheme", func() {
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, now)
+			bed.as(org1Identity)
+			bed.mustInvoke("setIdentifierScheme", scheme)
+		})
+
+		g.It("should accept an SSCC trackingID", func() {
+			bed.mustInvoke("createContainer", `{"trackingID":"(00)376104250021234569","counterparties":[]}`)
+		})
+
+		g.It("should reject a trackingID with a bad check digit", func() {
+			response := bed.invoke("createContainer", `{"trackingID":"(00)376104250021234568","counterparties":[]}`)
+
+			Expect(response.Status).To(BeEquivalentTo(400))
+			Expect(response.Message).To(ContainSubstring("invalid check digit"))
+		})
+	})
+
+	g.Describe("Scan with GS1 identifiers", func() {
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, now)
+			bed.as(manufacturerIdentity)
+			bed.mustInvoke("createContainer", `{"trackingID":"(00)376104250021234569","counterparties":[]}`)
+		})
+
+		g.It("should resolve a Digital Link URI", func() {
+			Expect(scan("https://id.gs1.org/00/376104250021234569")).To(BeEquivalentTo(map[string]interface{}{
+				"status":     "owned",
+				"trackingID": "(00)376104250021234569",
+			}))
+		})
+
+		g.It("should return lot and expiry of an element string", func() {
+			Expect(scan("(01)09506000134352(17)251200(10)LOT-7(21)ABC-123")).To(BeEquivalentTo(map[string]interface{}{
+				"status":     "new",
+				"trackingID": "(01)09506000134352(21)ABC-123",
+				"lot":        "LOT-7",
+				"expiry":     "2025-12-31",
+			}))
+		})
+
+		g.It("should resolve a raw element string in getContainer", func() {
+			var result Container
+			json.Unmarshal(bed.mustInvoke("getContainer", "]d200376104250021234569"), &result)
+
+			Expect(result.ID).To(Equal("(00)376104250021234569"))
+		})
+
+		g.It("should reject AIs it does not understand", func() {
+			_, err := ParseIdentifier("(01)09506000134352(21)ABC(11)250101")
+			Expect(err).To(MatchError("Unsupported AI (11)"))
+
+			response := bed.invoke("scan", "https://id.gs1.org/01/09506000134352/21/ABC/22/X")
+			Expect(response.Status).To(BeEquivalentTo(400))
+		})
+
+		g.It("should resolve existing trackingIDs that look like GS1 identifiers as they are", func() {
+			bed.mustInvoke("createContainer", `{"trackingID":"(99)crate-7","counterparties":[]}`)
+
+			var result Container
+			json.Unmarshal(bed.mustInvoke("getContainer", "(99)crate-7"), &result)
+			Expect(result.ID).To(Equal("(99)crate-7"))
+		})
+
+		g.It("should return 400 for a GS1 identifier with a bad check digit", func() {
+			response := bed.invoke("scan", "https://example.com/01/09506000134353/21/ABC")
+
+			Expect(response.Status).To(BeEquivalentTo(400))
+		})
+	})
+}
@@ -0,0 +1,319 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// composite key object types of the inspection and certification records
+const (
+	inspectionKey    = "inspection"
+	certificationKey = "certification"
+)
+
+// quality is the quality status of an item, its active certifications and latest inspection
+type quality struct {
+	Certifications   []Certification `json:"certifications"`
+	LatestInspection *Inspection     `json:"latestInspection"`
+}
+
+// qualityStatus is the quality status of an item returned by getQualityStatus
+type qualityStatus struct {
+	TrackingID string `json:"trackingID"`
+	quality
+}
+
+// productView is the product returned by getProduct, along with its quality status
+type productView struct {
+	Product
+	quality
+}
+
+// recordInspection records the result of an inspection of a product or container held by the current user
+func (s *SmartContract) recordInspection(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	trackingID := args[0]
+
+	var request InspectionRequest
+	if err := json.Unmarshal([]byte(args[1]), &request); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := request.Validate(); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	if response := checkCustodian(stub, trackingID, identity); response.Status != 200 {
+		return response
+	}
+
+	inspection := Inspection{
+		Type:         inspectionKey,
+		ID:           stub.GetTxID(),
+		TrackingID:   trackingID,
+		Inspector:    identity.Cert.Subject.String(),
+		Organization: identity.Organization,
+		Checklist:    request.Checklist,
+		Passed:       request.Passed,
+		Attachments:  request.Attachments,
+		Notes:        request.Notes,
+		Timestamp:    int64(s.clock.Now().UTC().Unix()),
+	}
+	if inspection.Checklist == nil {
+		inspection.Checklist = []InspectionCheck{}
+	}
+	if inspection.Attachments == nil {
+		inspection.Attachments = []Attachment{}
+	}
+
+	key, _ := stub.CreateCompositeKey(inspectionKey, []string{trackingID, inspection.ID})
+	inspectionBytes, _ := json.Marshal(inspection)
+	if err := stub.PutState(key, inspectionBytes); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	s.logger.Infof("Recorded inspection %s of %s, passed: %t\n", inspection.ID, trackingID, inspection.Passed)
+	return shim.Success(inspectionBytes)
+}
+
+// addCertification attaches a certification with a validity window to a product or container held by the current user
+func (s *SmartContract) addCertification(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	trackingID := args[0]
+
+	var request CertificationRequest
+	if err := json.Unmarshal([]byte(args[1]), &request); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := request.Validate(); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	if response := checkCustodian(stub, trackingID, identity); response.Status != 200 {
+		return response
+	}
+
+	certification := Certification{
+		Type:              certificationKey,
+		ID:                stub.GetTxID(),
+		TrackingID:        trackingID,
+		Scheme:            request.Scheme,
+		CertificateNumber: request.CertificateNumber,
+		Issuer:            request.Issuer,
+		ValidFrom:         request.ValidFrom,
+		ValidUntil:        request.ValidUntil,
+		DocumentHash:      request.DocumentHash,
+		AddedBy:           identity.Cert.Subject.String(),
+		Timestamp:         int64(s.clock.Now().UTC().Unix()),
+	}
+
+	key, _ := stub.CreateCompositeKey(certificationKey, []string{trackingID, certification.ID})
+	certificationBytes, _ := json.Marshal(certification)
+	if err := stub.PutState(key, certificationBytes); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	s.logger.Infof("Added %s certification %s to %s\n", certification.Scheme, certification.ID, trackingID)
+	return shim.Success(certificationBytes)
+}
+
+// getInspections retrieves every inspection of a product or container
+func (s *SmartContract) getInspections(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	trackingID := args[0]
+
+	if !itemHasParticipant(stub, trackingID, identity.Cert.Subject.String()) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Item with trackingID %s not found", trackingID),
+		}
+	}
+	inspections, err := getInspectionRecords(stub, trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	inspectionsBytes, _ := json.Marshal(inspections)
+	return shim.Success(inspectionsBytes)
+}
+
+// getCertifications retrieves every certification of a product or container, active or not
+func (s *SmartContract) getCertifications(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	trackingID := args[0]
+
+	if !itemHasParticipant(stub, trackingID, identity.Cert.Subject.String()) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Item with trackingID %s not found", trackingID),
+		}
+	}
+	certifications, err := getCertificationRecords(stub, trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	certificationsBytes, _ := json.Marshal(certifications)
+	return shim.Success(certificationsBytes)
+}
+
+// getQualityStatus retrieves the active certifications and the latest inspection of a product or container
+func (s *SmartContract) getQualityStatus(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	trackingID := args[0]
+
+	if !itemHasParticipant(stub, trackingID, identity.Cert.Subject.String()) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Item with trackingID %s not found", trackingID),
+		}
+	}
+	status, err := s.getQuality(stub, trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	statusBytes, _ := json.Marshal(qualityStatus{TrackingID: trackingID, quality: status})
+	return shim.Success(statusBytes)
+}
+
+// getQuality returns the active certifications and the latest inspection of an item
+func (s *SmartContract) getQuality(stub shim.ChaincodeStubInterface, trackingID string) (quality, error) {
+	status := quality{Certifications: []Certification{}}
+	certifications, err := getCertificationRecords(stub, trackingID)
+	if err != nil {
+		return status, err
+	}
+	for _, certification := range certifications {
+		if certification.Active(s.clock.Now()) {
+			status.Certifications = append(status.Certifications, certification)
+		}
+	}
+	inspections, err := getInspectionRecords(stub, trackingID)
+	if err != nil {
+		return status, err
+	}
+	for i := range inspections {
+		if status.LatestInspection == nil || inspections[i].Timestamp >= status.LatestInspection.Timestamp {
+			status.LatestInspection = &inspections[i]
+		}
+	}
+	return status, nil
+}
+
+func getInspectionRecords(stub shim.ChaincodeStubInterface, trackingID string) ([]Inspection, error) {
+	inspections := []Inspection{}
+	iterator, err := stub.GetStateByPartialCompositeKey(inspectionKey, []string{trackingID})
+	if err != nil {
+		return inspections, err
+	}
+	defer iterator.Close()
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return inspections, err
+		}
+		var inspection Inspection
+		if err := json.Unmarshal(state.Value, &inspection); err != nil {
+			return inspections, err
+		}
+		inspections = append(inspections, inspection)
+	}
+	return inspections, nil
+}
+
+func getCertificationRecords(stub shim.ChaincodeStubInterface, trackingID string) ([]Certification, error) {
+	certifications := []Certification{}
+	iterator, err := stub.GetStateByPartialCompositeKey(certificationKey, []string{trackingID})
+	if err != nil {
+		return certifications, err
+	}
+	defer iterator.Close()
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return certifications, err
+		}
+		var certification Certification
+		if err := json.Unmarshal(state.Value, &certification); err != nil {
+			return certifications, err
+		}
+		certifications = append(certifications, certification)
+	}
+	return certifications, nil
+}
+
+// checkCustodian returns a 404 response if the product or container does not exist and a 403 response if it
+// is not held by the identity
+func checkCustodian(stub shim.ChaincodeStubInterface, trackingID string, identity *Identity) peer.Response {
+	itemBytes, _ := stub.GetState(trackingID)
+	if len(itemBytes) == 0 {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Item with trackingID %s not found", trackingID),
+		}
+	}
+	var custodian string
+	var product Product
+	if err := json.Unmarshal(itemBytes, &product); err == nil {
+		custodian = product.Custodian
+	} else {
+		var container Container
+		if err := json.Unmarshal(itemBytes, &container); err != nil {
+			return shim.Error(err.Error())
+		}
+		custodian = container.Custodian
+	}
+	if identity.Cert.Subject.String() != custodian {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, item not held by identity"),
+		}
+	}
+	return shim.Success(nil)
+}
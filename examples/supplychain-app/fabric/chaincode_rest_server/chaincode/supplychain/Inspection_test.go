@@ -0,0 +1,85 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestInspection(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	producer := org1Identity.subject()
+	photoHash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	g.Describe("Inspections and Certifications", func() {
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC))
+			bed.as(org1Identity)
+			bed.mustInvoke("createProduct", `{"trackingID":"tea-1","productName":"Green Tea","counterparties":[]}`)
+		})
+
+		g.It("should surface active certifications and the latest inspection on getProduct", func() {
+			bed.mustInvoke("addCertification", "tea-1", `{"scheme":"organic","certificateNumber":"EU-BIO-1","issuer":"CERES","validFrom":"2019-01-01","validUntil":"2019-12-31"}`)
+			bed.mustInvoke("addCertification", "tea-1", `{"scheme":"GMP","certificateNumber":"GMP-7","issuer":"Swissmedic","validFrom":"2018-01-01","validUntil":"2018-12-31"}`)
+
+			bed.mustInvoke("recordInspection", "tea-1", `{"checklist":[{"item":"seal intact","passed":true},{"item":"labelling","passed":false}],"passed":false}`)
+			bed.clock.Add(time.Hour)
+			var latest Inspection
+			json.Unmarshal(bed.mustInvoke("recordInspection", "tea-1", `{"checklist":[{"item":"seal intact","passed":true}],"passed":true,"attachments":[{"name":"pallet.jpg","hash":"`+photoHash+`"}]}`), &latest)
+
+			var view struct {
+				Certifications   []Certification `json:"certifications"`
+				LatestInspection Inspection      `json:"latestInspection"`
+			}
+			json.Unmarshal(bed.mustInvoke("getQualityStatus", "tea-1"), &view)
+			Expect(view.Certifications).To(HaveLen(1))
+			Expect(view.Certifications[0].Scheme).To(Equal("organic"))
+			Expect(view.LatestInspection.ID).To(Equal(latest.ID))
+			Expect(view.LatestInspection.Passed).To(BeTrue())
+			Expect(view.LatestInspection.Inspector).To(Equal(producer))
+			Expect(view.LatestInspection.Attachments).To(Equal([]Attachment{{Name: "pallet.jpg", Hash: photoHash}}))
+
+			var certifications []Certification
+			json.Unmarshal(bed.mustInvoke("getCertifications", "tea-1"), &certifications)
+			Expect(certifications).To(HaveLen(2))
+
+			var product struct {
+				ID               string          `json:"trackingID"`
+				Certifications   []Certification `json:"certifications"`
+				LatestInspection Inspection      `json:"latestInspection"`
+			}
+			json.Unmarshal(bed.mustInvoke("getProduct", "tea-1"), &product)
+			Expect(product.ID).To(Equal("tea-1"))
+			Expect(product.Certifications).To(Equal(view.Certifications))
+			Expect(product.LatestInspection.ID).To(Equal(latest.ID))
+		})
+
+		g.It("should return 400 for a passed inspection with a failed check or an invalid hash", func() {
+			response := bed.invoke("recordInspection", "tea-1", `{"checklist":[{"item":"seal intact","passed":false}],"passed":true}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+
+			response = bed.invoke("recordInspection", "tea-1", `{"passed":true,"attachments":[{"name":"pallet.jpg","hash":"abc"}]}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+		})
+
+		g.It("should return 400 for a certification with an invalid validity window", func() {
+			response := bed.invoke("addCertification", "tea-1", `{"scheme":"ISO 9001","certificateNumber":"Q-1","issuer":"TUV","validFrom":"2019-12-31","validUntil":"2019-01-01"}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+		})
+
+		g.It("should return 403 if the identity is not the custodian", func() {
+			bed.as(carrierIdentity)
+			response := bed.invoke("recordInspection", "tea-1", `{"passed":true}`)
+			Expect(response.Status).To(BeEquivalentTo(403))
+		})
+	})
+}
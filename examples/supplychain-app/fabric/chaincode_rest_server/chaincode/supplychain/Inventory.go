@@ -0,0 +1,121 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// inventoryReconciliationKey is the composite key object type recorded inventory reconciliations are stored under,
+// by custodian and reconciliationID
+const inventoryReconciliationKey = "inventoryReconciliation"
+
+// getInventory retrieves every product in stock and container held by the current user, grouped by location and by
+// the unpackaged items they are packed in
+func (s *SmartContract) getInventory(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 0 {
+		return shim.Error("Incorrect number of arguments. Expecting 0")
+	}
+
+	inventory, err := s.loadInventory(stub, identity)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	inventoryBytes, _ := json.Marshal(inventory)
+	return shim.Success(inventoryBytes)
+}
+
+// reconcileInventory compares a cycle count of the current user, optionally of one location, with its inventory
+// and reports the missing and unexpected items. The result is recorded on the ledger on request.
+func (s *SmartContract) reconcileInventory(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	var request InventoryCountRequest
+	if err := json.Unmarshal([]byte(args[0]), &request); err != nil {
+		return shim.Error(err.Error())
+	}
+	if request.Scanned == nil {
+		request.Scanned = []string{}
+	}
+
+	inventory, err := s.loadInventory(stub, identity)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	reconciliation := InventoryReconciliation{
+		Type:      inventoryReconciliationKey,
+		ID:        stub.GetTxID(),
+		Custodian: inventory.Custodian,
+		Location:  request.Location,
+		Scanned:   request.Scanned,
+		Recorded:  request.Record,
+		Timestamp: inventory.Timestamp,
+	}
+	reconciliation.Reconcile(inventory)
+
+	reconciliationBytes, _ := json.Marshal(reconciliation)
+	if request.Record {
+		key, _ := stub.CreateCompositeKey(inventoryReconciliationKey, []string{reconciliation.Custodian, reconciliation.ID})
+		if err := stub.PutState(key, reconciliationBytes); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	s.logger.Infof("Reconciled inventory, %d missing, %d unexpected\n", len(reconciliation.Missing), len(reconciliation.Unexpected))
+	return shim.Success(reconciliationBytes)
+}
+
+// getInventoryReconciliations retrieves the recorded inventory reconciliations of the current user
+func (s *SmartContract) getInventoryReconciliations(stub shim.ChaincodeStubInterface) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(inventoryReconciliationKey, []string{identity.Cert.Subject.String()})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer iterator.Close()
+	reconciliations := []InventoryReconciliation{}
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		var reconciliation InventoryReconciliation
+		if err := json.Unmarshal(state.Value, &reconciliation); err != nil {
+			return shim.Error(err.Error())
+		}
+		reconciliations = append(reconciliations, reconciliation)
+	}
+	reconciliationsBytes, _ := json.Marshal(reconciliations)
+	return shim.Success(reconciliationsBytes)
+}
+
+func (s *SmartContract) loadInventory(stub shim.ChaincodeStubInterface, identity *Identity) (Inventory, error) {
+	containers, products, err := getAllItems(stub)
+	if err != nil {
+		return Inventory{}, err
+	}
+	return NewInventory(identity.Cert.Subject.String(), containers, products, int64(s.clock.Now().UTC().Unix())), nil
+}
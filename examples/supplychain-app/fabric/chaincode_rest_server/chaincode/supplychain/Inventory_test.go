@@ -0,0 +1,93 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestInventory(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	warehouse := carrierIdentity.subject()
+
+	reconcile := func(request string) InventoryReconciliation {
+		var reconciliation InventoryReconciliation
+		json.Unmarshal(bed.mustInvoke("reconcileInventory", request), &reconciliation)
+		return reconciliation
+	}
+
+	g.Describe("Inventory", func() {
+		//the warehouse holds a pallet of widgets at Dock A, a bolt on Shelf 3 and a destroyed crate, it sold another bolt
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC))
+			bed.as(org1Identity)
+			for _, id := range []string{"pallet-1", "case-1", "crate-9"} {
+				bed.mustInvoke("createContainer", `{"trackingID":"`+id+`","lastScannedAt":"Zurich","counterparties":["`+warehouse+`"]}`)
+			}
+			for _, product := range [][2]string{{"widget-1", "Widget"}, {"widget-2", "Widget"}, {"bolt-1", "Bolt"}, {"bolt-2", "Bolt"}, {"bolt-3", "Bolt"}} {
+				bed.mustInvoke("createProduct", `{"trackingID":"`+product[0]+`","productName":"`+product[1]+`","lastScannedAt":"Zurich","counterparties":["`+warehouse+`"]}`)
+			}
+			bed.mustInvoke("package", "case-1", "widget-1")
+			bed.mustInvoke("package", "case-1", "widget-2")
+			bed.mustInvoke("package", "pallet-1", "case-1")
+
+			bed.as(carrierIdentity)
+			bed.mustInvoke("claimContainer", "pallet-1", "Dock A")
+			bed.mustInvoke("claimContainer", "crate-9", "Dock A")
+			bed.mustInvoke("updateState", "crate-9", `{"trackingID":"crate-9","health":"destroyed","reason":"disposal"}`)
+			bed.mustInvoke("claimProduct", "bolt-1", "Shelf 3")
+			bed.mustInvoke("claimProduct", "bolt-2", "Shelf 3")
+			bed.mustInvoke("sellProduct", "bolt-2")
+		})
+
+		g.It("should group the items in stock by location and container root", func() {
+			var inventory Inventory
+			json.Unmarshal(bed.mustInvoke("getInventory"), &inventory)
+			Expect(inventory.Items).To(Equal(5))
+			Expect(inventory.Locations).To(Equal([]InventoryLocation{
+				{Location: "Dock A", Items: []string{"case-1", "pallet-1", "widget-1", "widget-2"}},
+				{Location: "Shelf 3", Items: []string{"bolt-1"}},
+			}))
+			Expect(inventory.Roots).To(Equal([]InventoryRoot{
+				{TrackingID: "bolt-1", Location: "Shelf 3", Contents: []string{}},
+				{TrackingID: "pallet-1", Location: "Dock A", Contents: []string{"case-1", "widget-1", "widget-2"}},
+			}))
+		})
+
+		g.It("should count the contents of scanned containers at a location", func() {
+			reconciliation := reconcile(`{"scanned":["pallet-1","widget-9"],"location":"Dock A"}`)
+			Expect(reconciliation.Expected).To(Equal(4))
+			Expect(reconciliation.Missing).To(BeEmpty())
+			Expect(reconciliation.Unexpected).To(Equal([]string{"widget-9"}))
+			Expect(string(bed.mustInvoke("getInventoryReconciliations"))).To(Equal("[]"))
+		})
+
+		g.It("should leave destroyed containers out of stock", func() {
+			reconciliation := reconcile(`{"scanned":["pallet-1","crate-9"],"location":"Dock A"}`)
+			Expect(reconciliation.Expected).To(Equal(4))
+			Expect(reconciliation.Missing).To(BeEmpty())
+			Expect(reconciliation.Unexpected).To(Equal([]string{"crate-9"}))
+		})
+
+		g.It("should report missing items and record the reconciliation on request", func() {
+			reconciliation := reconcile(`{"scanned":["case-1","bolt-3"],"record":true}`)
+			Expect(reconciliation.Missing).To(Equal([]string{"bolt-1", "pallet-1"}))
+			Expect(reconciliation.Unexpected).To(Equal([]string{"bolt-3"}))
+
+			var reconciliations []InventoryReconciliation
+			json.Unmarshal(bed.mustInvoke("getInventoryReconciliations"), &reconciliations)
+			Expect(reconciliations).To(HaveLen(1))
+			Expect(reconciliations[0].ID).To(Equal(reconciliation.ID))
+			Expect(reconciliations[0].Custodian).To(Equal(warehouse))
+		})
+	})
+}
@@ -0,0 +1,99 @@
+package supplychain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// getProductsByLot retrieves all products of a lot/batch the current user is a participant of
+func (s *SmartContract) getProductsByLot(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	query := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"docType": "product",
+			"lot":     args[0],
+		},
+		"use_index": []string{"_design/lot-index", "lot-index"},
+	}
+	return queryProducts(stub, identity, query)
+}
+
+// getInventoryByExpiry retrieves the unsold products held by the current user that have an
+// expiry date, earliest expiry first, to be shipped first-expired-first-out
+func (s *SmartContract) getInventoryByExpiry(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 0 {
+		return shim.Error("Incorrect number of arguments. Expecting 0")
+	}
+
+	query := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"docType":   "product",
+			"custodian": identity.Cert.Subject.String(),
+			"expiry":    map[string]interface{}{"$gt": ""},
+			"sold":      false,
+		},
+		"sort": []map[string]string{
+			{"docType": "asc"},
+			{"custodian": "asc"},
+			{"expiry": "asc"},
+		},
+		"use_index": []string{"_design/expiry-index", "expiry-index"},
+	}
+	return queryProducts(stub, identity, query)
+}
+
+// queryProducts runs a CouchDB query and returns the matching products accessible by the identity
+func queryProducts(stub shim.ChaincodeStubInterface, identity *Identity, query map[string]interface{}) peer.Response {
+	queryBytes, _ := json.Marshal(query)
+	iterator, err := stub.GetQueryResult(string(queryBytes))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting query iterator: %s", err.Error()))
+	}
+	defer iterator.Close()
+
+	// Create array
+	var buffer bytes.Buffer
+	buffer.WriteString("[")
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return shim.Error(fmt.Sprintf("Error accessing state: %s", err))
+		}
+
+		// Don't return products issuer isn't a party to
+		var product Product
+		if err := json.Unmarshal(state.Value, &product); err != nil {
+			return shim.Error(fmt.Sprintf("Error unmarshalling product: %s", err))
+		}
+		if product.AccessibleBy(identity) {
+			if buffer.Len() != 1 {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString(string(state.Value))
+		}
+	}
+	buffer.WriteString("]")
+
+	return shim.Success(buffer.Bytes())
+}
@@ -0,0 +1,108 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestLot(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	carrier := carrierIdentity.subject()
+
+	createProduct := func(id string, lot string, expiry string) {
+		bed.mustInvoke("createProduct", `{"trackingID":"`+id+`","productName":"Insulin","lot":"`+lot+`","expiry":"`+expiry+`","counterparties":["`+carrier+`"]}`)
+	}
+	products := func(function string, args ...string) []string {
+		var result []Product
+		json.Unmarshal(bed.mustInvoke(function, args...), &result)
+		ids := []string{}
+		for _, product := range result {
+			ids = append(ids, product.ID)
+		}
+		return ids
+	}
+
+	g.Describe("Lot", func() {
+		g.BeforeEach(func() {
+			bed = newLedgerTestbed(time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC))
+			bed.as(org1Identity)
+		})
+
+		g.Describe("Lot queries", func() {
+			g.It("should retrieve the products of a lot", func() {
+				createProduct("insulin-1", "LOT-7", "2019-06-01")
+				createProduct("insulin-2", "LOT-8", "2019-06-01")
+				createProduct("insulin-3", "LOT-7", "")
+
+				Expect(products("getProductsByLot", "LOT-7")).To(ConsistOf("insulin-1", "insulin-3"))
+				Expect(products("getProductsByLot", "LOT-9")).To(BeEmpty())
+			})
+
+			g.It("should not retrieve products of a lot the user is not a participant of", func() {
+				createProduct("insulin-1", "LOT-7", "2019-06-01")
+				bed.as(manufacturerIdentity)
+
+				Expect(products("getProductsByLot", "LOT-7")).To(BeEmpty())
+			})
+
+			g.It("should list unsold inventory with an expiry, earliest expiry first", func() {
+				createProduct("insulin-1", "LOT-7", "2019-09-01")
+				createProduct("insulin-2", "LOT-8", "2019-04-01")
+				createProduct("insulin-3", "LOT-9", "")
+				createProduct("insulin-4", "LOT-9", "2019-05-01")
+				createProduct("insulin-5", "LOT-9", "2019-03-20")
+				bed.mustInvoke("sellProduct", "insulin-5")
+
+				Expect(products("getInventoryByExpiry")).To(Equal([]string{"insulin-2", "insulin-4", "insulin-1"}))
+			})
+		})
+
+		g.Describe("Expiry", func() {
+			g.It("should mark the product as sold", func() {
+				createProduct("insulin-1", "LOT-7", "2019-03-14")
+
+				bed.mustInvoke("sellProduct", "insulin-1")
+
+				var result Product
+				json.Unmarshal(bed.mustInvoke("getProduct", "insulin-1"), &result)
+				Expect(result.Sold).To(BeTrue())
+			})
+
+			g.It("should not create an expired product", func() {
+				response := bed.invoke("createProduct", `{"trackingID":"insulin-1","productName":"Insulin","expiry":"2019-03-13"}`)
+
+				Expect(response.Status).NotTo(BeEquivalentTo(200))
+			})
+
+			g.It("should not sell an expired product", func() {
+				createProduct("insulin-1", "LOT-7", "2019-03-14")
+				bed.clock.Add(24 * time.Hour)
+
+				response := bed.invoke("sellProduct", "insulin-1")
+
+				Expect(response.Status).To(BeEquivalentTo(403))
+				Expect(response.Message).To(Equal("Product insulin-1 expired on 2019-03-14"))
+			})
+
+			g.It("should not package an expired product", func() {
+				createProduct("insulin-1", "LOT-7", "2019-03-14")
+				bed.mustInvoke("createContainer", `{"trackingID":"box-1","counterparties":[]}`)
+				bed.clock.Add(24 * time.Hour)
+
+				response := bed.invoke("package", "box-1", "insulin-1")
+
+				Expect(response.Status).To(BeEquivalentTo(403))
+				Expect(response.Message).To(ContainSubstring("expired on 2019-03-14"))
+			})
+		})
+	})
+}
@@ -0,0 +1,224 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// ownershipKey is the composite key object type ownership transfers are stored under, by the trackingID of every
+// transferred item and transferID
+const ownershipKey = "ownership"
+
+// transferOwnership transfers the title of a product or container owned by the current user, and of everything
+// packaged into it that the current user owns, to a new owner. Custody is not affected.
+func (s *SmartContract) transferOwnership(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 2 or 3")
+	}
+	trackingID, newOwner := args[0], args[1]
+	var reference string
+	if len(args) == 3 {
+		reference = args[2]
+	}
+	owner := identity.Cert.Subject.String()
+	if strings.TrimSpace(newOwner) == "" || newOwner == owner {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: a new owner other than the current one is required "),
+		}
+	}
+
+	if !itemHasParticipant(stub, trackingID, owner) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Item %s Not Found", trackingID),
+		}
+	}
+	containers, products, err := getContainerTree(stub, trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	//the transferred item is the first of the tree, its contents follow
+	var current string
+	if len(containers) != 0 && containers[0].ID == trackingID {
+		current = containers[0].CurrentOwner()
+	} else {
+		current = products[0].CurrentOwner()
+	}
+	if current != owner {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, item not owned by identity"),
+		}
+	}
+
+	timestamp := int64(s.clock.Now().UTC().Unix())
+	transfer := OwnershipTransfer{
+		Type:       ownershipKey,
+		ID:         stub.GetTxID(),
+		TrackingID: trackingID,
+		Items:      []string{},
+		From:       owner,
+		To:         newOwner,
+		Reference:  reference,
+		Timestamp:  timestamp,
+	}
+	for _, container := range containers {
+		if container.CurrentOwner() != owner {
+			continue
+		}
+		container.Owner = newOwner
+		if !container.HasParticipant(newOwner) {
+			container.Participants = append(container.Participants, newOwner)
+		}
+		container.Timestamp = timestamp
+		containerBytes, _ := json.Marshal(container)
+		if err := stub.PutState(container.ID, containerBytes); err != nil {
+			return shim.Error(err.Error())
+		}
+		transfer.Items = append(transfer.Items, container.ID)
+	}
+	for _, product := range products {
+		if product.CurrentOwner() != owner {
+			continue
+		}
+		product.Owner = newOwner
+		if !product.HasParticipant(newOwner) {
+			product.Participants = append(product.Participants, newOwner)
+		}
+		product.Timestamp = timestamp
+		if response := putProducts(stub, product); response.Status != shim.OK {
+			return response
+		}
+		transfer.Items = append(transfer.Items, product.ID)
+	}
+
+	transferBytes, _ := json.Marshal(transfer)
+	for _, id := range transfer.Items {
+		key, _ := stub.CreateCompositeKey(ownershipKey, []string{id, transfer.ID})
+		if err := stub.PutState(key, transferBytes); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	s.logger.Infof("Transferred ownership of %s and %d items\n", trackingID, len(transfer.Items)-1)
+	return shim.Success(transferBytes)
+}
+
+// getOwnershipHistory retrieves the ownership transfers of an item the current user participates in
+func (s *SmartContract) getOwnershipHistory(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	trackingID := args[0]
+
+	if !itemHasParticipant(stub, trackingID, identity.Cert.Subject.String()) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Item %s Not Found", trackingID),
+		}
+	}
+	iterator, err := stub.GetStateByPartialCompositeKey(ownershipKey, []string{trackingID})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer iterator.Close()
+	transfers := []OwnershipTransfer{}
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		var transfer OwnershipTransfer
+		if err := json.Unmarshal(state.Value, &transfer); err != nil {
+			return shim.Error(err.Error())
+		}
+		transfers = append(transfers, transfer)
+	}
+	transfersBytes, _ := json.Marshal(transfers)
+	return shim.Success(transfersBytes)
+}
+
+// redirectShipment changes the destination of a shipment that has not arrived, only the owner of every container
+// in the shipment can redirect it
+func (s *SmartContract) redirectShipment(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	shipmentID, destination := args[0], args[1]
+	if strings.TrimSpace(destination) == "" {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: a destination is required "),
+		}
+	}
+
+	shipment, err := getShipment(stub, shipmentID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	owner := identity.Cert.Subject.String()
+	if shipment == nil {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Shipment %s Not Found", shipmentID),
+		}
+	}
+	for _, containerID := range shipment.Containers {
+		containerBytes, _ := stub.GetState(containerID)
+		var container Container
+		if err := json.Unmarshal(containerBytes, &container); err != nil || container.CurrentOwner() != owner {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("You are not authorized to perform this transaction, container %s not owned by identity", containerID),
+			}
+		}
+	}
+	if shipment.ArrivedAt != 0 {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Shipment %s has already arrived", shipmentID),
+		}
+	}
+
+	timestamp := int64(s.clock.Now().UTC().Unix())
+	shipment.Redirects = append(shipment.Redirects, Redirect{
+		From:      shipment.Destination,
+		To:        destination,
+		By:        owner,
+		Timestamp: timestamp,
+	})
+	shipment.Destination = destination
+	shipment.Timestamp = timestamp
+	if err := putShipment(stub, *shipment); err != nil {
+		return shim.Error(err.Error())
+	}
+	shipmentBytes, _ := json.Marshal(shipment)
+
+	s.logger.Infof("Redirected shipment %s to %s\n", shipmentID, destination)
+	return shim.Success(shipmentBytes)
+}
@@ -0,0 +1,95 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestOwnership(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	seller := org1Identity.subject()
+	carrier := carrierIdentity.subject()
+	buyer := manufacturerIdentity.subject()
+
+	getProduct := func() Product {
+		var product Product
+		json.Unmarshal(bed.mustInvoke("getProduct", "widget-1"), &product)
+		return product
+	}
+
+	g.Describe("Ownership", func() {
+		//the seller ships a crate with a widget which the carrier takes custody of
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC))
+			bed.as(org1Identity)
+			bed.mustInvoke("createProduct", `{"trackingID":"widget-1","productName":"Widget","counterparties":["`+carrier+`"]}`)
+			bed.mustInvoke("createContainer", `{"trackingID":"crate-1","counterparties":["`+carrier+`"]}`)
+			bed.mustInvoke("package", "crate-1", "widget-1")
+			bed.mustInvoke("createShipment", `{"shipmentID":"shipment-1","containers":["crate-1"],"origin":"Zurich","destination":"London","expectedCustodians":["`+carrier+`"],"eta":"2019-03-16T12:00:00Z"}`)
+			bed.as(carrierIdentity)
+			bed.mustInvoke("claimContainer", "crate-1", "Zurich")
+			bed.as(org1Identity)
+		})
+
+		g.It("should transfer the title of a container and its contents without moving custody", func() {
+			var transfer OwnershipTransfer
+			json.Unmarshal(bed.mustInvoke("transferOwnership", "crate-1", buyer, "INV-7"), &transfer)
+			Expect(transfer.Items).To(Equal([]string{"crate-1", "widget-1"}))
+			Expect(transfer.Reference).To(Equal("INV-7"))
+
+			product := getProduct()
+			Expect(product.Owner).To(Equal(buyer))
+			Expect(product.Custodian).To(Equal(carrier))
+			Expect(product.Participants).To(ContainElement(buyer))
+
+			bed.as(carrierIdentity)
+			var transfers []OwnershipTransfer
+			json.Unmarshal(bed.mustInvoke("getOwnershipHistory", "widget-1"), &transfers)
+			Expect(transfers).To(HaveLen(1))
+			Expect(transfers[0].From).To(Equal(seller))
+		})
+
+		g.It("should not let the custodian transfer the title", func() {
+			bed.as(carrierIdentity)
+			Expect(bed.invoke("transferOwnership", "crate-1", buyer).Status).To(BeEquivalentTo(403))
+			Expect(bed.invoke("transferOwnership", "crate-1", " ").Status).To(BeEquivalentTo(400))
+		})
+
+		g.It("should list products by owner or custodian", func() {
+			var products []Product
+			json.Unmarshal(bed.mustInvoke("getProductsBy", FilterOwner, seller), &products)
+			Expect(products).To(HaveLen(1))
+			Expect(string(bed.mustInvoke("getProductsBy", FilterCustodian, seller))).To(Equal("[]"))
+			var containers []Container
+			json.Unmarshal(bed.mustInvoke("getContainersBy", FilterCustodian, carrier), &containers)
+			Expect(containers).To(HaveLen(1))
+
+			Expect(bed.invoke("getProductsBy", "shipper", seller).Status).To(BeEquivalentTo(400))
+		})
+
+		g.It("should let the owner recall and redirect but not the custodian", func() {
+			bed.as(carrierIdentity)
+			Expect(bed.invoke("recallProduct", "widget-1").Status).To(BeEquivalentTo(403))
+			Expect(bed.invoke("redirectShipment", "shipment-1", "Paris").Status).To(BeEquivalentTo(403))
+
+			bed.as(org1Identity)
+			bed.mustInvoke("recallProduct", "widget-1")
+			Expect(getProduct().Recalled).To(BeTrue())
+			var shipment Shipment
+			json.Unmarshal(bed.mustInvoke("redirectShipment", "shipment-1", "Paris"), &shipment)
+			Expect(shipment.Destination).To(Equal("Paris"))
+			Expect(shipment.Redirects).To(HaveLen(1))
+			Expect(shipment.Redirects[0].From).To(Equal("London"))
+		})
+	})
+}
@@ -0,0 +1,250 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// containerTypeKey is the composite key object type container types are stored under, by organization and name
+const containerTypeKey = "containerType"
+
+// packingRulesKey is the composite key object type the packing rules are stored under, by organization
+const packingRulesKey = "packingRules"
+
+// defineContainerType creates a container type of the organization of the current user with its capacity, a defined
+// container type cannot be redefined as containers of it were packed against its capacity
+func (s *SmartContract) defineContainerType(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if !identity.CanInvoke("defineContainerType") {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, cannot invoke defineContainerType"),
+		}
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	var containerType ContainerType
+	if err := json.Unmarshal([]byte(args[0]), &containerType); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := containerType.Validate(); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	containerType.Type = containerTypeKey
+	containerType.Organization = identity.Organization
+
+	key, _ := stub.CreateCompositeKey(containerTypeKey, []string{containerType.Organization, containerType.Name})
+	existingBytes, err := stub.GetState(key)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(existingBytes) != 0 {
+		return peer.Response{
+			Status:  409,
+			Message: fmt.Sprintf("Error: container type %s of %s is defined ", containerType.Name, containerType.Organization),
+		}
+	}
+	containerTypeBytes, _ := json.Marshal(containerType)
+	if err := stub.PutState(key, containerTypeBytes); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	s.logger.Infof("Defined container type %s of %s\n", containerType.Name, containerType.Organization)
+	return shim.Success(containerTypeBytes)
+}
+
+// getContainerTypes retrieves every container type defined, or those of one organization
+func (s *SmartContract) getContainerTypes(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) > 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 0 or 1")
+	}
+	iterator, err := stub.GetStateByPartialCompositeKey(containerTypeKey, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer iterator.Close()
+	containerTypes := []ContainerType{}
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		var containerType ContainerType
+		if err := json.Unmarshal(state.Value, &containerType); err != nil {
+			return shim.Error(err.Error())
+		}
+		containerTypes = append(containerTypes, containerType)
+	}
+	containerTypesBytes, _ := json.Marshal(containerTypes)
+	return shim.Success(containerTypesBytes)
+}
+
+// setPackingRules replaces the hazmat and temperature compatibility rules of items packaged together by the
+// organization of the current user, the default incompatible hazmat classes cannot be removed
+func (s *SmartContract) setPackingRules(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if !identity.CanInvoke("setPackingRules") {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, cannot invoke setPackingRules"),
+		}
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	var rules PackingRules
+	if err := json.Unmarshal([]byte(args[0]), &rules); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := rules.Validate(); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	rules.AddDefaultHazmat()
+	rules.Type = packingRulesKey
+	rules.Organization = identity.Organization
+
+	key, _ := stub.CreateCompositeKey(packingRulesKey, []string{identity.Organization})
+	rulesBytes, _ := json.Marshal(rules)
+	if err := stub.PutState(key, rulesBytes); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	s.logger.Infof("Updated packing rules of %s\n", identity.Organization)
+	return shim.Success(rulesBytes)
+}
+
+// getPackingRules retrieves the packing rules in effect for the organization of the current user, or of the
+// organization given
+func (s *SmartContract) getPackingRules(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) > 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 0 or 1")
+	}
+	organization := identity.Organization
+	if len(args) == 1 {
+		organization = args[0]
+	}
+
+	rules, err := loadPackingRules(stub, organization)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	rulesBytes, _ := json.Marshal(rules)
+	return shim.Success(rulesBytes)
+}
+
+// checkPacking checks packaging the added item into the container against the capacity of its type and the packing
+// rules of the organization packaging, and then every container the container is packaged into against its own as
+// the added item adds to their weight and volume and mixes with their contents. Violations are returned as conflicts
+// explaining the constraint that failed.
+func checkPacking(stub shim.ChaincodeStubInterface, organization string, container Container, added PackedItem) peer.Response {
+	rules, err := loadPackingRules(stub, organization)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	childID := ""
+	visited := map[string]bool{}
+	for !visited[container.ID] {
+		visited[container.ID] = true
+		var containerType *ContainerType
+		if container.ContainerType != "" {
+			defined, err := getContainerType(stub, container.ContainerTypeOrganization, container.ContainerType)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			containerType = &defined
+		}
+		//the content the added item is packaged into counts as the added item at the containers above
+		contents := []PackedItem{}
+		for _, contentID := range container.Contents {
+			containers, products, err := getContainerTree(stub, contentID)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			content := NewPackedItem(contentID, containers, products)
+			if contentID == childID {
+				added = content.Including(added)
+				continue
+			}
+			contents = append(contents, content)
+		}
+		if err := CheckPacking(container.ID, containerType, rules, contents, added); err != nil {
+			return peer.Response{
+				Status:  409,
+				Message: err.Error(),
+			}
+		}
+
+		if container.ContainerID == "" {
+			break
+		}
+		parentBytes, err := stub.GetState(container.ContainerID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		childID = container.ID
+		if err := json.Unmarshal(parentBytes, &container); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	return shim.Success(nil)
+}
+
+// getContainerType returns a container type of an organization
+func getContainerType(stub shim.ChaincodeStubInterface, organization string, name string) (ContainerType, error) {
+	key, _ := stub.CreateCompositeKey(containerTypeKey, []string{organization, name})
+	containerTypeBytes, err := stub.GetState(key)
+	if err != nil {
+		return ContainerType{}, err
+	}
+	if len(containerTypeBytes) == 0 {
+		return ContainerType{}, fmt.Errorf("Container type %s of %s is not defined", name, organization)
+	}
+	var containerType ContainerType
+	err = json.Unmarshal(containerTypeBytes, &containerType)
+	return containerType, err
+}
+
+// loadPackingRules returns the packing rules stored by the organization, or the default rules if it set none. Stored
+// rules already hold the default incompatible hazmat classes, setPackingRules adds them.
+func loadPackingRules(stub shim.ChaincodeStubInterface, organization string) (PackingRules, error) {
+	key, _ := stub.CreateCompositeKey(packingRulesKey, []string{organization})
+	rulesBytes, err := stub.GetState(key)
+	if err != nil || len(rulesBytes) == 0 {
+		return DefaultPackingRules(), err
+	}
+	var rules PackingRules
+	err = json.Unmarshal(rulesBytes, &rules)
+	return rules, err
+}
@@ -0,0 +1,158 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestPacking(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	packer := testIdentity{mspID: "PartyAMSP", certPath: org1Identity.certPath}
+	otherOrganization := testIdentity{mspID: "Org2MSP", certPath: org1Identity.certPath}
+	products := map[string]string{
+		"widget-1":  `{"productName":"Widget","misc":{"weight":4,"volume":2}}`,
+		"widget-2":  `{"productName":"Widget","misc":{"weight":4,"volume":2}}`,
+		"widget-3":  `{"productName":"Widget","misc":{"weight":4,"volume":2}}`,
+		"fuel-1":    `{"productName":"Fuel","misc":{"hazmatClass":"3"}}`,
+		"peroxide":  `{"productName":"Peroxide","misc":{"hazmatClass":"5.1"}}`,
+		"vaccine-1": `{"productName":"Vaccine","misc":{"temperatureClass":"frozen"}}`,
+		"insulin-1": `{"productName":"Insulin","misc":{"temperatureClass":"chilled"}}`,
+	}
+
+	pack := func(containerID string, contentID string) (int32, string) {
+		response := bed.invoke("package", containerID, contentID)
+		return response.Status, response.Message
+	}
+	defineContainer := func(containerID string, containerType string) {
+		bed.mustInvoke("defineContainerType", containerType)
+		var defined ContainerType
+		json.Unmarshal([]byte(containerType), &defined)
+		bed.mustInvoke("createContainer", `{"trackingID":"`+containerID+`","containerType":"`+defined.Name+`"}`)
+	}
+
+	g.Describe("Packing", func() {
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC))
+			bed.as(packer)
+			for id, request := range products {
+				var product map[string]interface{}
+				json.Unmarshal([]byte(request), &product)
+				product["trackingID"] = id
+				productBytes, _ := json.Marshal(product)
+				bed.mustInvoke("createProduct", string(productBytes))
+			}
+			bed.mustInvoke("createContainer", `{"trackingID":"crate-1"}`)
+		})
+
+		g.It("should let manufacturers define container types of their organization once", func() {
+			bed.as(manufacturerIdentity)
+			response := bed.invoke("defineContainerType", `{"name":"tote","maxItems":2}`)
+			Expect(response.Status).To(BeEquivalentTo(403))
+
+			bed.as(packer)
+			response = bed.invoke("defineContainerType", `{"name":"tote","maxItems":-1}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+			bed.mustInvoke("defineContainerType", `{"name":"tote","maxItems":2}`)
+			response = bed.invoke("defineContainerType", `{"name":"tote","maxItems":20}`)
+			Expect(response.Status).To(BeEquivalentTo(409))
+			bed.as(otherOrganization)
+			bed.mustInvoke("defineContainerType", `{"name":"tote","maxItems":20}`)
+
+			var containerTypes []ContainerType
+			json.Unmarshal(bed.mustInvoke("getContainerTypes", "PartyAMSP"), &containerTypes)
+			Expect(containerTypes).To(HaveLen(1))
+			Expect(containerTypes[0].Organization).To(Equal("PartyAMSP"))
+			Expect(containerTypes[0].MaxItems).To(Equal(2))
+			json.Unmarshal(bed.mustInvoke("getContainerTypes"), &containerTypes)
+			Expect(containerTypes).To(HaveLen(2))
+
+			bed.as(packer)
+			response = bed.invoke("createContainer", `{"trackingID":"bin-1","containerType":"bin"}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+			bed.mustInvoke("createContainer", `{"trackingID":"tote-2","containerType":"tote","containerTypeOrganization":"Org2MSP"}`)
+			var container Container
+			json.Unmarshal(bed.mustInvoke("getContainer", "tote-2"), &container)
+			Expect(container.ContainerTypeOrganization).To(Equal("Org2MSP"))
+		})
+
+		g.It("should enforce the item count and weight of the container type", func() {
+			defineContainer("tote-1", `{"name":"tote","maxItems":2,"maxWeight":10}`)
+			bed.mustInvoke("package", "crate-1", "widget-2")
+			bed.mustInvoke("package", "crate-1", "widget-3")
+			bed.mustInvoke("package", "tote-1", "widget-1")
+			status, message := pack("tote-1", "crate-1")
+			Expect(status).To(BeEquivalentTo(409))
+			Expect(message).To(Equal("Container tote-1 of type tote holds at most weight 10, packaging crate-1 would make 12"))
+
+			defineContainer("bin-1", `{"name":"bin","maxItems":1}`)
+			bed.mustInvoke("package", "bin-1", "crate-1")
+			status, message = pack("bin-1", "tote-1")
+			Expect(status).To(BeEquivalentTo(409))
+			Expect(message).To(Equal("Container bin-1 of type bin holds at most 1 items"))
+		})
+
+		g.It("should check the containers the container is packaged into", func() {
+			defineContainer("tote-1", `{"name":"tote","maxWeight":10,"temperatureClass":"chilled"}`)
+			bed.mustInvoke("package", "tote-1", "fuel-1")
+			bed.mustInvoke("package", "tote-1", "crate-1")
+			bed.mustInvoke("package", "crate-1", "widget-1")
+			bed.mustInvoke("package", "crate-1", "widget-2")
+
+			status, message := pack("crate-1", "widget-3")
+			Expect(status).To(BeEquivalentTo(409))
+			Expect(message).To(Equal("Container tote-1 of type tote holds at most weight 10, packaging widget-3 would make 12"))
+			status, message = pack("crate-1", "peroxide")
+			Expect(status).To(BeEquivalentTo(409))
+			Expect(message).To(Equal("Hazmat class 5.1 of peroxide cannot be mixed with hazmat class 3 of fuel-1"))
+			status, message = pack("crate-1", "vaccine-1")
+			Expect(status).To(BeEquivalentTo(409))
+			Expect(message).To(Equal("Temperature class frozen of vaccine-1 does not match temperature class chilled of container tote-1"))
+		})
+
+		g.It("should refuse mixing incompatible hazmat classes", func() {
+			bed.mustInvoke("package", "crate-1", "fuel-1")
+			status, message := pack("crate-1", "peroxide")
+			Expect(status).To(BeEquivalentTo(409))
+			Expect(message).To(Equal("Hazmat class 5.1 of peroxide cannot be mixed with hazmat class 3 of fuel-1"))
+		})
+
+		g.It("should keep the default hazmat classes apart under the rules of every organization", func() {
+			var rules PackingRules
+			json.Unmarshal(bed.mustInvoke("setPackingRules", `{"incompatibleHazmat":[["2.3","3"]],"matchTemperature":false}`), &rules)
+			Expect(rules.Organization).To(Equal("PartyAMSP"))
+			Expect(rules.IncompatibleHazmat).To(Equal([][2]string{{"2.3", "3"}, {"1", "3"}, {"1", "5.1"}, {"3", "5.1"}}))
+
+			bed.mustInvoke("package", "crate-1", "fuel-1")
+			status, _ := pack("crate-1", "peroxide")
+			Expect(status).To(BeEquivalentTo(409))
+			bed.mustInvoke("package", "crate-1", "vaccine-1")
+			bed.mustInvoke("package", "crate-1", "insulin-1")
+
+			json.Unmarshal(bed.mustInvoke("getPackingRules", "Org2MSP"), &rules)
+			Expect(rules.MatchTemperature).To(BeTrue())
+		})
+
+		g.It("should require matching temperature classes", func() {
+			bed.mustInvoke("package", "crate-1", "vaccine-1")
+			status, message := pack("crate-1", "insulin-1")
+			Expect(status).To(BeEquivalentTo(409))
+			Expect(message).To(Equal("Temperature class chilled of insulin-1 does not match temperature class frozen of vaccine-1"))
+
+			defineContainer("tote-1", `{"name":"tote","temperatureClass":"chilled"}`)
+			status, message = pack("tote-1", "crate-1")
+			Expect(status).To(BeEquivalentTo(409))
+			Expect(message).To(Equal("Temperature class frozen of vaccine-1 does not match temperature class chilled of container tote-1"))
+			bed.mustInvoke("package", "tote-1", "insulin-1")
+		})
+	})
+}
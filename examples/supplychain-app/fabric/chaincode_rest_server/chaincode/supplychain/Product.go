@@ -35,16 +35,68 @@ func (s *SmartContract) createProduct(stub shim.ChaincodeStubInterface, args []s
 	if err := json.Unmarshal(argBytes, &request); err != nil {
 		return shim.Error(err.Error())
 	}
+	product, validation := s.newProduct(stub, identity, request)
+	if validation.Status != shim.OK {
+		return validation
+	}
+
+	// Put new Product onto blockchain
+	productAsBytes, _ := json.Marshal(product)
+	if err := stub.PutState(product.ID, productAsBytes); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	response := map[string]interface{}{
+		"generatedID": product.ID,
+	}
+	bytes, _ := json.Marshal(response)
+
+	s.logger.Infof("Wrote Product: %s\n", product.ID)
+	return shim.Success(bytes)
+}
+
+//newProduct validates a product request and returns the new Product held by the identity
+func (s *SmartContract) newProduct(stub shim.ChaincodeStubInterface, identity *Identity, request ProductRequest) (Product, peer.Response) {
+	//Check the trackingID against the organizations identifier scheme
+	if response := validateTrackingID(stub, identity, request.ID, false); response.Status != shim.OK {
+		return Product{}, response
+	}
+	//Check lot and expiry date
+	if request.Lot != "" {
+		if err := ValidateLot(request.Lot); err != nil {
+			return Product{}, peer.Response{
+				Status:  400,
+				Message: fmt.Sprintf("Error: %s ", err),
+			}
+		}
+	}
+	if request.Expiry != "" {
+		if err := ValidateExpiry(request.Expiry); err != nil {
+			return Product{}, peer.Response{
+				Status:  400,
+				Message: fmt.Sprintf("Error: %s ", err),
+			}
+		}
+	}
+	//Check the quantity of bulk products
+	if request.Unit != "" || request.Quantity != 0 {
+		if err := ValidateQuantity(request.Quantity, request.Unit); err != nil {
+			return Product{}, peer.Response{
+				Status:  400,
+				Message: fmt.Sprintf("Error: %s ", err),
+			}
+		}
+	}
 	//Check if product  state using id as key exsists
 	testProductAsBytes, err := stub.GetState(request.ID)
 	if err != nil {
-		return shim.Error(err.Error())
+		return Product{}, shim.Error(err.Error())
 	}
 	// Return 403 if item exisits
 	if len(testProductAsBytes) != 0 {
-		return peer.Response{
+		return Product{}, peer.Response{
 			Status:  403,
-			Message: fmt.Sprintf("Existing Product %s Found", args[0]),
+			Message: fmt.Sprintf("Existing Product %s Found", request.ID),
 		}
 	}
 
@@ -59,25 +111,27 @@ func (s *SmartContract) createProduct(stub shim.ChaincodeStubInterface, args []s
 		Recalled:     false,
 		ContainerID:  "",
 		Custodian:    identity.Cert.Subject.String(),
+		Owner:        identity.Cert.Subject.String(),
 		Timestamp:    int64(s.clock.Now().UTC().Unix()),
 		Participants: request.Participants,
+		Lot:          request.Lot,
+		Expiry:       request.Expiry,
+		Quantity:     request.Quantity,
+		Unit:         request.Unit,
+		Created:      request.Quantity,
+		Manufacturer: identity.Organization,
+		Prescription: request.Prescription,
 	}
-
-	product.Participants = append(product.Participants, identity.Cert.Subject.String())
-
-	// Put new Product onto blockchain
-	productAsBytes, _ := json.Marshal(product)
-	if err := stub.PutState(product.ID, productAsBytes); err != nil {
-		return shim.Error(err.Error())
-	}
-
-	response := map[string]interface{}{
-		"generatedID": product.ID,
+	product.CustodySince = product.Timestamp
+	if product.Expired(s.clock.Now()) {
+		return Product{}, peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Product %s expired on %s", product.ID, product.Expiry),
+		}
 	}
-	bytes, _ := json.Marshal(response)
 
-	s.logger.Infof("Wrote Product: %s\n", product.ID)
-	return shim.Success(bytes)
+	product.Participants = append(product.Participants, identity.Cert.Subject.String())
+	return product, shim.Success(nil)
 }
 
 //getAllProducts retrieves all products on the ledger
@@ -91,7 +145,32 @@ func (s *SmartContract) getAllProducts(stub shim.ChaincodeStubInterface, args []
 	if len(args) != 0 {
 		return shim.Error("Incorrect number of arguments. Expecting 0")
 	}
+	return listProducts(stub, identity, "", "")
+}
+
+//getProductsBy retrieves the products owned or held by the supplied subject
+func (s *SmartContract) getProductsBy(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//Get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
 
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	if err := ValidateFilter(args[0]); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	return listProducts(stub, identity, args[0], args[1])
+}
+
+//listProducts lists the products accessible by the identity, filtered by owner or custodian unless the
+//field is empty
+func listProducts(stub shim.ChaincodeStubInterface, identity *Identity, field string, subject string) peer.Response {
 	// Get iterator for all entries
 	iterator, err := stub.GetStateByRange("", "")
 	if err != nil {
@@ -114,7 +193,7 @@ func (s *SmartContract) getAllProducts(stub shim.ChaincodeStubInterface, args []
 		if err != nil && err.Error() != "Not a Product" {
 			return shim.Error(err.Error())
 		}
-		if product.AccessibleBy(identity) {
+		if product.AccessibleBy(identity) && (field == "" || product.HeldBy(field, subject)) {
 			if buffer.Len() != 1 {
 				buffer.WriteString(",")
 			}
@@ -126,7 +205,7 @@ func (s *SmartContract) getAllProducts(stub shim.ChaincodeStubInterface, args []
 	return shim.Success(buffer.Bytes())
 }
 
-//getSingleProducts retrieves all products on the ledger
+//getSingleProducts retrieves a single product on the ledger along with its active certifications and latest inspection
 func (s *SmartContract) getSingleProduct(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	//get user identity
 	identity, err := GetInvokerIdentity(stub)
@@ -137,9 +216,18 @@ func (s *SmartContract) getSingleProduct(stub shim.ChaincodeStubInterface, args
 	if len(args) != 1 {
 		return shim.Error("Incorrect number of arguments. Expecting 1")
 	}
+	//accept GS1 Digital Link URIs and element strings as trackingID
+	identifier, err := resolveIdentifier(stub, args[0])
+	if err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	trackingID := identifier.TrackingID
 
 	//get single state using id as key
-	productAsBytes, err := stub.GetState(args[0])
+	productAsBytes, err := stub.GetState(trackingID)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
@@ -147,7 +235,7 @@ func (s *SmartContract) getSingleProduct(stub shim.ChaincodeStubInterface, args
 	if len(productAsBytes) == 0 {
 		return peer.Response{
 			Status:  404,
-			Message: fmt.Sprintf("Product %s Not Found", args[0]),
+			Message: fmt.Sprintf("Product %s Not Found", trackingID),
 		}
 	}
 
@@ -164,10 +252,16 @@ func (s *SmartContract) getSingleProduct(stub shim.ChaincodeStubInterface, args
 	if !product.AccessibleBy(identity) {
 		return peer.Response{
 			Status:  404,
-			Message: fmt.Sprintf("Product %s Not Found", args[0]),
+			Message: fmt.Sprintf("Product %s Not Found", trackingID),
 		}
 	}
-	return shim.Success(productAsBytes)
+	//surface the active certifications and latest inspection result
+	status, err := s.getQuality(stub, trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	viewBytes, _ := json.Marshal(productView{Product: product, quality: status})
+	return shim.Success(viewBytes)
 }
 
 //getContainerlessProducts retrieves all products on the ledger where containerID is empty
@@ -224,8 +318,8 @@ func (s *SmartContract) updateProductCustodian(stub shim.ChaincodeStubInterface,
 		shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
 	}
 
-	if len(args) != 2 {
-		return shim.Error("Incorrect number of arguments. Expecting 2")
+	if len(args) != 2 && len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 2 or 4")
 	}
 	trackingID := args[0]
 	newLocation := args[1]
@@ -277,11 +371,47 @@ func (s *SmartContract) updateProductCustodian(stub shim.ChaincodeStubInterface,
 			}
 		}
 	}
+	if response := checkHealth(stub, ActionClaim, nil, []Product{product}); response.Status != 200 {
+		return response
+	}
+	if product.ConsumedBy != "" {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Product was consumed by %s", product.ConsumedBy),
+		}
+	}
+
+	//claim part of a bulk product by splitting the claimed quantity off first
+	if len(args) == 4 {
+		split, response := s.splitQuantity(stub, identity, &product, args[2], args[3])
+		if response.Status != shim.OK {
+			return response
+		}
+		if response := putProducts(stub, product); response.Status != shim.OK {
+			return response
+		}
+		product = split
+		trackingID = split.ID
+	}
 
-	//change custodian
+	//change custodian, completing the custody interval of the previous one
+	slas, err := loadDwellSLAs(stub, product.Manufacturer)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	seller := product.Custodian
+	timestamp := int64(s.clock.Now().UTC().Unix())
+	interval := NewDwell(product.ID, seller, product.CustodyStart(), timestamp, slas)
 	product.Custodian = newCustodian
 	product.Location = newLocation
-	product.Timestamp = int64(s.clock.Now().UTC().Unix())
+	product.Timestamp = timestamp
+	product.CustodySince = timestamp
+	if err := recordTransactionInformation(stub, product, seller); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := recordCustodyIntervals(stub, []Dwell{interval}); err != nil {
+		return shim.Error(err.Error())
+	}
 
 	newBytes, _ := json.Marshal(product)
 
@@ -293,3 +423,67 @@ func (s *SmartContract) updateProductCustodian(stub shim.ChaincodeStubInterface,
 	return shim.Success([]byte(trackingID))
 
 }
+
+//sellProduct marks a product held by the current user as sold
+func (s *SmartContract) sellProduct(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	trackingID := args[0]
+
+	existingBytes, _ := stub.GetState(trackingID)
+	if len(existingBytes) == 0 {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Item with trackingID %s not found", trackingID),
+		}
+	}
+	var product Product
+	if err := json.Unmarshal(existingBytes, &product); err != nil {
+		return shim.Error(err.Error())
+	}
+	if identity.Cert.Subject.String() != product.Custodian {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, product not held by identity"),
+		}
+	}
+	if product.ContainerID != "" {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Product needs to be unpackaged before it can be sold"),
+		}
+	}
+	if product.Sold || product.Recalled || product.ConsumedBy != "" {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Product %s is sold, recalled or consumed", trackingID),
+		}
+	}
+	if product.Expired(s.clock.Now()) {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Product %s expired on %s", trackingID, product.Expiry),
+		}
+	}
+	if response := checkHealth(stub, ActionSell, nil, []Product{product}); response.Status != 200 {
+		return response
+	}
+
+	product.Sold = true
+	product.Timestamp = int64(s.clock.Now().UTC().Unix())
+	product.SoldAt = product.Timestamp
+	newBytes, _ := json.Marshal(product)
+	if err := stub.PutState(trackingID, newBytes); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	s.logger.Infof("Sold product: %s\n", trackingID)
+	return shim.Success([]byte(trackingID))
+}
@@ -0,0 +1,315 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// purchaseOrderKey is the composite key object type purchase orders are stored under, by purchaseOrderID
+const purchaseOrderKey = "purchaseOrder"
+
+// createPurchaseOrder issues a purchase order of the current user as buyer to a seller
+func (s *SmartContract) createPurchaseOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	var request PurchaseOrderRequest
+	if err := json.Unmarshal([]byte(args[0]), &request); err != nil {
+		return shim.Error(err.Error())
+	}
+	buyer := identity.Cert.Subject.String()
+	if err := request.Validate(buyer); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+
+	existing, err := getPurchaseOrder(stub, request.ID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if existing != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Existing Purchase Order %s Found", request.ID),
+		}
+	}
+
+	po := PurchaseOrder{
+		Type:       purchaseOrderKey,
+		ID:         request.ID,
+		Buyer:      buyer,
+		Seller:     request.Seller,
+		Lines:      request.Lines,
+		Currency:   request.Currency,
+		Settlement: request.Escrow,
+		Status:     PurchaseOrderIssued,
+		Shipments:  []string{},
+		Containers: []string{},
+		Exceptions: []PurchaseOrderException{},
+		Timestamp:  int64(s.clock.Now().UTC().Unix()),
+	}
+	if err := putPurchaseOrder(stub, po); err != nil {
+		return shim.Error(err.Error())
+	}
+	poBytes, _ := json.Marshal(po)
+
+	s.logger.Infof("Issued purchase order %s with %d lines\n", po.ID, len(po.Lines))
+	return shim.Success(poBytes)
+}
+
+// acceptPurchaseOrder accepts an issued purchase order of which the current user is the seller, agreeing to the
+// escrow chaincode the buyer named
+func (s *SmartContract) acceptPurchaseOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	po, response := getPartyPurchaseOrder(stub, args[0], identity)
+	if po == nil {
+		return response
+	}
+	if po.Seller != identity.Cert.Subject.String() {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, only the seller can accept"),
+		}
+	}
+	if po.Status != PurchaseOrderIssued {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Purchase order %s is %s", po.ID, po.Status),
+		}
+	}
+
+	po.Status = PurchaseOrderAccepted
+	po.Timestamp = int64(s.clock.Now().UTC().Unix())
+	if err := putPurchaseOrder(stub, *po); err != nil {
+		return shim.Error(err.Error())
+	}
+	poBytes, _ := json.Marshal(po)
+
+	s.logger.Infof("Accepted purchase order %s\n", po.ID)
+	return shim.Success(poBytes)
+}
+
+// linkPurchaseOrder links a shipment shipped by, or a container held or owned by, the seller of an accepted purchase
+// order to it, deliveries of linked containers are matched against the order
+func (s *SmartContract) linkPurchaseOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	poID, id := args[0], args[1]
+
+	po, response := getPartyPurchaseOrder(stub, poID, identity)
+	if po == nil {
+		return response
+	}
+	seller := identity.Cert.Subject.String()
+	if po.Seller != seller {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, only the seller can link"),
+		}
+	}
+	if po.Status != PurchaseOrderAccepted {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Purchase order %s is %s", po.ID, po.Status),
+		}
+	}
+
+	shipment, err := getShipment(stub, id)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if shipment != nil {
+		if shipment.Shipper != seller {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("You are not authorized to perform this transaction, shipment not shipped by identity"),
+			}
+		}
+		if shipment.PurchaseOrder != "" && shipment.PurchaseOrder != po.ID {
+			return peer.Response{
+				Status:  400,
+				Message: fmt.Sprintf("Error: shipment %s is linked to purchase order %s ", id, shipment.PurchaseOrder),
+			}
+		}
+		shipment.PurchaseOrder = po.ID
+		if err := putShipment(stub, *shipment); err != nil {
+			return shim.Error(err.Error())
+		}
+		po.LinkShipment(id)
+	} else {
+		containerBytes, _ := stub.GetState(id)
+		var container Container
+		if len(containerBytes) == 0 || json.Unmarshal(containerBytes, &container) != nil || !container.AccessibleBy(identity) {
+			return peer.Response{
+				Status:  404,
+				Message: fmt.Sprintf("Shipment or Container %s Not Found", id),
+			}
+		}
+		if container.Custodian != seller && container.CurrentOwner() != seller {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("You are not authorized to perform this transaction, container not held or owned by identity"),
+			}
+		}
+		if container.PurchaseOrder != "" && container.PurchaseOrder != po.ID {
+			return peer.Response{
+				Status:  400,
+				Message: fmt.Sprintf("Error: container %s is linked to purchase order %s ", id, container.PurchaseOrder),
+			}
+		}
+		container.PurchaseOrder = po.ID
+		containerBytes, _ = json.Marshal(container)
+		if err := stub.PutState(container.ID, containerBytes); err != nil {
+			return shim.Error(err.Error())
+		}
+		po.LinkContainer(id)
+	}
+
+	po.Timestamp = int64(s.clock.Now().UTC().Unix())
+	if err := putPurchaseOrder(stub, *po); err != nil {
+		return shim.Error(err.Error())
+	}
+	poBytes, _ := json.Marshal(po)
+
+	s.logger.Infof("Linked %s to purchase order %s\n", id, po.ID)
+	return shim.Success(poBytes)
+}
+
+// getSinglePurchaseOrder retrieves a purchase order of which the current user is the buyer or seller
+func (s *SmartContract) getSinglePurchaseOrder(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	po, response := getPartyPurchaseOrder(stub, args[0], identity)
+	if po == nil {
+		return response
+	}
+	poBytes, _ := json.Marshal(po)
+	return shim.Success(poBytes)
+}
+
+// matchPurchaseOrder matches a delivery of a container linked, directly or through its shipment, to a purchase order
+// against the order. Shipped quantities are the products packaged into the container, received quantities the
+// products scanned on arrival that are not damaged. The value of the fulfilled quantities is released from escrow, a
+// release that fails is recorded as pending and does not fail the delivery. Only accepted purchase orders are matched.
+func (s *SmartContract) matchPurchaseOrder(stub shim.ChaincodeStubInterface, poID string, container Container, delivery *Delivery) error {
+	po, err := getPurchaseOrder(stub, poID)
+	if err != nil || po == nil || po.Status != PurchaseOrderAccepted {
+		return err
+	}
+
+	_, shippedProducts, err := getContainerTree(stub, container.ID)
+	if err != nil {
+		return err
+	}
+	shipped := lineQuantities(shippedProducts, nil)
+
+	damaged := map[string]bool{}
+	for _, id := range delivery.Damaged {
+		damaged[id] = true
+	}
+	receivedProducts := []Product{}
+	for _, id := range delivery.Received {
+		//unknown items scanned on arrival are reported as extra by the delivery
+		_, products, err := getContainerTree(stub, id)
+		if err != nil {
+			continue
+		}
+		receivedProducts = append(receivedProducts, products...)
+	}
+	received := lineQuantities(receivedProducts, damaged)
+
+	delivery.PurchaseOrder = po.ID
+	delivery.Exceptions = po.Match(delivery.ID, shipped, received, delivery.Timestamp)
+	if err := s.releaseEscrow(stub, po); err != nil {
+		s.logger.Warningf("Release of purchase order %s is pending: %s\n", po.ID, err)
+	}
+	return putPurchaseOrder(stub, *po)
+}
+
+// lineQuantities sums the quantities of the products by line key, leaving out the products excluded
+func lineQuantities(products []Product, excluded map[string]bool) map[string]float64 {
+	quantities := map[string]float64{}
+	seen := map[string]bool{}
+	for _, product := range products {
+		if seen[product.ID] || excluded[product.ID] {
+			continue
+		}
+		seen[product.ID] = true
+		key := LineKey(product)
+		quantities[key] = RoundQuantity(quantities[key] + LineQuantity(product))
+	}
+	return quantities
+}
+
+// getPartyPurchaseOrder retrieves a purchase order, as not found unless the identity is its buyer or seller
+func getPartyPurchaseOrder(stub shim.ChaincodeStubInterface, poID string, identity *Identity) (*PurchaseOrder, peer.Response) {
+	po, err := getPurchaseOrder(stub, poID)
+	if err != nil {
+		return nil, shim.Error(err.Error())
+	}
+	subject := identity.Cert.Subject.String()
+	if po == nil || (po.Buyer != subject && po.Seller != subject) {
+		return nil, peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Purchase Order %s Not Found", poID),
+		}
+	}
+	return po, shim.Success(nil)
+}
+
+func getPurchaseOrder(stub shim.ChaincodeStubInterface, poID string) (*PurchaseOrder, error) {
+	key, _ := stub.CreateCompositeKey(purchaseOrderKey, []string{poID})
+	poBytes, err := stub.GetState(key)
+	if err != nil || len(poBytes) == 0 {
+		return nil, err
+	}
+	var po PurchaseOrder
+	if err := json.Unmarshal(poBytes, &po); err != nil {
+		return nil, err
+	}
+	return &po, nil
+}
+
+func putPurchaseOrder(stub shim.ChaincodeStubInterface, po PurchaseOrder) error {
+	key, _ := stub.CreateCompositeKey(purchaseOrderKey, []string{po.ID})
+	poBytes, _ := json.Marshal(po)
+	return stub.PutState(key, poBytes)
+}
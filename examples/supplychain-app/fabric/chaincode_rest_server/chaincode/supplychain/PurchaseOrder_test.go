@@ -0,0 +1,132 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestPurchaseOrder(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	seller := org1Identity.subject()
+	buyer := manufacturerIdentity.subject()
+	signature := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	gtin := "09506000134352"
+	widget1 := FormatSGTIN(gtin, "1")
+	widget2 := FormatSGTIN(gtin, "2")
+
+	order := func(lines string) {
+		bed.as(manufacturerIdentity)
+		bed.mustInvoke("createPurchaseOrder", `{"purchaseOrderID":"PO-1","seller":"`+seller+`","lines":`+lines+`}`)
+		bed.as(org1Identity)
+		bed.mustInvoke("acceptPurchaseOrder", "PO-1")
+		bed.mustInvoke("linkPurchaseOrder", "PO-1", "crate-1")
+	}
+	deliver := func(scanned string) Delivery {
+		bed.as(manufacturerIdentity)
+		var delivery Delivery
+		json.Unmarshal(bed.mustInvoke("confirmDelivery", "crate-1", `{"scanned":`+scanned+`,"signatureHash":"`+signature+`","sender":"`+seller+`"}`), &delivery)
+		return delivery
+	}
+	getPurchaseOrder := func() PurchaseOrder {
+		var po PurchaseOrder
+		json.Unmarshal(bed.mustInvoke("getPurchaseOrder", "PO-1"), &po)
+		return po
+	}
+
+	g.Describe("Purchase Orders", func() {
+		//the seller packs two widgets and a bolt into a crate the buyer takes custody of, the seller keeps the title
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC))
+			bed.as(org1Identity)
+			bed.mustInvoke("createContainer", `{"trackingID":"crate-1","counterparties":["`+buyer+`"]}`)
+			for _, product := range []struct{ id, name string }{{widget1, "Widget"}, {widget2, "Widget"}, {"bolt-1", "Bolt"}} {
+				bed.mustInvoke("createProduct", `{"trackingID":"`+product.id+`","productName":"`+product.name+`","counterparties":["`+buyer+`"]}`)
+				bed.mustInvoke("package", "crate-1", product.id)
+			}
+			bed.as(manufacturerIdentity)
+			bed.mustInvoke("claimContainer", "crate-1", "Zurich")
+		})
+
+		g.It("should be issued by the buyer and accepted and linked by the seller", func() {
+			bed.mustInvoke("createPurchaseOrder", `{"purchaseOrderID":"PO-1","seller":"`+seller+`","lines":[{"gtin":"9506000134352","quantity":2}]}`)
+			Expect(bed.invoke("acceptPurchaseOrder", "PO-1").Status).To(BeEquivalentTo(403))
+
+			bed.as(org1Identity)
+			Expect(bed.invoke("linkPurchaseOrder", "PO-1", "crate-1").Status).To(BeEquivalentTo(403))
+			bed.mustInvoke("acceptPurchaseOrder", "PO-1")
+			bed.mustInvoke("linkPurchaseOrder", "PO-1", "crate-1")
+
+			po := getPurchaseOrder()
+			Expect(po.Status).To(Equal(PurchaseOrderAccepted))
+			Expect(po.Lines[0].GTIN).To(Equal(gtin))
+			Expect(po.Containers).To(Equal([]string{"crate-1"}))
+			var container Container
+			json.Unmarshal(bed.mustInvoke("getContainer", "crate-1"), &container)
+			Expect(container.PurchaseOrder).To(Equal("PO-1"))
+		})
+
+		g.It("should return 400 for invalid line items", func() {
+			response := bed.invoke("createPurchaseOrder", `{"purchaseOrderID":"PO-1","seller":"`+seller+`","lines":[{"gtin":"9506000134353","quantity":2}]}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+			response = bed.invoke("createPurchaseOrder", `{"purchaseOrderID":"PO-1","seller":"`+seller+`","lines":[{"productName":"Bolt","quantity":0}]}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+		})
+
+		g.It("should record fulfilled quantities and raise a partial delivery", func() {
+			order(`[{"gtin":"` + gtin + `","quantity":3},{"productName":"Bolt","quantity":1}]`)
+			delivery := deliver(`["` + widget1 + `","` + widget2 + `","bolt-1"]`)
+			Expect(delivery.PurchaseOrder).To(Equal("PO-1"))
+			Expect(delivery.Exceptions).To(HaveLen(1))
+			Expect(delivery.Exceptions[0].Type).To(Equal(ExceptionPartialDelivery))
+			Expect(delivery.Exceptions[0].Ordered).To(BeEquivalentTo(3))
+			Expect(delivery.Exceptions[0].Received).To(BeEquivalentTo(2))
+
+			po := getPurchaseOrder()
+			Expect(po.Status).To(Equal(PurchaseOrderAccepted))
+			Expect(po.Lines[0].Fulfilled).To(BeEquivalentTo(2))
+			Expect(po.Lines[1].Fulfilled).To(BeEquivalentTo(1))
+		})
+
+		g.It("should not raise partial deliveries for lines the delivery did not carry", func() {
+			order(`[{"gtin":"` + gtin + `","quantity":2},{"productName":"Bolt","quantity":1},{"productName":"Nut","quantity":5}]`)
+			delivery := deliver(`["` + widget1 + `","` + widget2 + `","bolt-1"]`)
+			Expect(delivery.Exceptions).To(BeEmpty())
+
+			po := getPurchaseOrder()
+			Expect(po.Status).To(Equal(PurchaseOrderAccepted))
+			Expect(po.Lines[2].Fulfilled).To(BeEquivalentTo(0))
+		})
+
+		g.It("should raise over deliveries and shipment mismatches", func() {
+			order(`[{"gtin":"` + gtin + `","quantity":1},{"productName":"Bolt","quantity":1}]`)
+			delivery := deliver(`["` + widget1 + `","` + widget2 + `"]`)
+			types := []string{}
+			for _, exception := range delivery.Exceptions {
+				types = append(types, exception.Type)
+			}
+			Expect(types).To(Equal([]string{ExceptionOverDelivery, ExceptionShipmentMismatch, ExceptionPartialDelivery}))
+			Expect(delivery.Exceptions[1].Shipped).To(BeEquivalentTo(1))
+			Expect(delivery.Exceptions[1].Received).To(BeEquivalentTo(0))
+			Expect(getPurchaseOrder().Status).To(Equal(PurchaseOrderAccepted))
+		})
+
+		g.It("should raise unexpected items and fulfill the order", func() {
+			order(`[{"gtin":"` + gtin + `","quantity":2}]`)
+			delivery := deliver(`["` + widget1 + `","` + widget2 + `","bolt-1"]`)
+			Expect(delivery.Exceptions).To(HaveLen(1))
+			Expect(delivery.Exceptions[0].Type).To(Equal(ExceptionUnexpectedItem))
+			Expect(delivery.Exceptions[0].Product).To(Equal("Bolt"))
+			Expect(getPurchaseOrder().Status).To(Equal(PurchaseOrderFulfilled))
+		})
+	})
+}
@@ -0,0 +1,239 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// splitProduct moves part of the quantity of a bulk product held by the current user into a new product, taking the
+// quantity and the new trackingID in the same order as package and claim
+func (s *SmartContract) splitProduct(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3")
+	}
+	trackingID := args[0]
+
+	productBytes, _ := stub.GetState(trackingID)
+	var product Product
+	if len(productBytes) == 0 || json.Unmarshal(productBytes, &product) != nil {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Product %s Not Found", trackingID),
+		}
+	}
+	if identity.Cert.Subject.String() != product.Custodian {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, product not held by identity"),
+		}
+	}
+
+	split, response := s.splitQuantity(stub, identity, &product, args[1], args[2])
+	if response.Status != shim.OK {
+		return response
+	}
+	if response := putProducts(stub, product, split); response.Status != shim.OK {
+		return response
+	}
+
+	result := map[string]interface{}{
+		"generatedID": split.ID,
+		"remaining":   product.Quantity,
+	}
+	bytes, _ := json.Marshal(result)
+
+	s.logger.Infof("Split %v %s of %s into %s\n", split.Quantity, split.Unit, trackingID, split.ID)
+	return shim.Success(bytes)
+}
+
+// getMassBalance accounts for the quantity created with a bulk product across every product split from it
+func (s *SmartContract) getMassBalance(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	trackingID := args[0]
+
+	productBytes, _ := stub.GetState(trackingID)
+	var product Product
+	if len(productBytes) == 0 || json.Unmarshal(productBytes, &product) != nil || !product.AccessibleBy(identity) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Product %s Not Found", trackingID),
+		}
+	}
+	if !product.Bulk() {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: product %s does not carry a quantity ", trackingID),
+		}
+	}
+
+	balance, err := massBalance(stub, product)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	balanceBytes, _ := json.Marshal(balance)
+	return shim.Success(balanceBytes)
+}
+
+// splitQuantity moves the supplied quantity of a bulk product into a new product with the supplied trackingID, the
+// new product is held by the same custodian and keeps its lineage through splitFrom. Neither product is written.
+func (s *SmartContract) splitQuantity(stub shim.ChaincodeStubInterface, identity *Identity, product *Product, quantityArg string, splitID string) (Product, peer.Response) {
+	if !product.Bulk() {
+		return Product{}, peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: product %s does not carry a quantity and cannot be split ", product.ID),
+		}
+	}
+	quantity, err := ParseQuantity(quantityArg)
+	if err == nil {
+		err = ValidateQuantity(quantity, product.Unit)
+	}
+	if err != nil {
+		return Product{}, peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	if quantity > product.Quantity || EqualQuantity(quantity, product.Quantity) {
+		return Product{}, peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: cannot split %v %s from %v %s, transfer the whole product instead ", quantity, product.Unit, product.Quantity, product.Unit),
+		}
+	}
+	if product.ContainerID != "" {
+		return Product{}, peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Product %s needs to be unpackaged before it can be split", product.ID),
+		}
+	}
+	if product.ConsumedBy != "" || product.Sold || product.Recalled || product.Health == HealthDestroyed {
+		return Product{}, peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Product %s is consumed, sold, recalled or destroyed", product.ID),
+		}
+	}
+	if product.Expired(s.clock.Now()) {
+		return Product{}, peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Product %s expired on %s", product.ID, product.Expiry),
+		}
+	}
+	if response := validateTrackingID(stub, identity, splitID, false); response.Status != shim.OK {
+		return Product{}, response
+	}
+	existingBytes, err := stub.GetState(splitID)
+	if err != nil {
+		return Product{}, shim.Error(err.Error())
+	}
+	if len(existingBytes) != 0 {
+		return Product{}, peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Existing Product %s Found", splitID),
+		}
+	}
+
+	timestamp := int64(s.clock.Now().UTC().Unix())
+	split := Product{
+		ID:           splitID,
+		Type:         "product",
+		Name:         product.Name,
+		Health:       product.Health,
+		HealthReason: product.HealthReason,
+		Metadata:     product.Metadata,
+		Custodian:    product.Custodian,
+		Owner:        product.Owner,
+		CustodySince: product.CustodyStart(),
+		Location:     product.Location,
+		Timestamp:    timestamp,
+		Participants: append([]string{}, product.Participants...),
+		Lot:          product.Lot,
+		Expiry:       product.Expiry,
+		Quantity:     quantity,
+		Unit:         product.Unit,
+		SplitFrom:    product.ID,
+		Manufacturer: product.Manufacturer,
+		PublicFields: product.PublicFields,
+		Prescription: product.Prescription,
+	}
+
+	//quantity is only moved between the two records, never created or lost
+	remaining := RoundQuantity(product.Quantity - quantity)
+	if !EqualQuantity(remaining+quantity, product.Quantity) {
+		return Product{}, shim.Error(fmt.Sprintf("Mass balance violated splitting %s", product.ID))
+	}
+	product.Quantity = remaining
+	product.Splits = append(product.Splits, splitID)
+	product.Timestamp = timestamp
+	return split, shim.Success(nil)
+}
+
+// massBalance sums the quantities of every product split from the product the supplied product was split from
+func massBalance(stub shim.ChaincodeStubInterface, product Product) (MassBalance, error) {
+	root := product
+	for root.SplitFrom != "" {
+		rootBytes, err := stub.GetState(root.SplitFrom)
+		if err != nil {
+			return MassBalance{}, err
+		}
+		id := root.SplitFrom
+		if len(rootBytes) == 0 || json.Unmarshal(rootBytes, &root) != nil {
+			return MassBalance{}, fmt.Errorf("Product %s Not Found", id)
+		}
+	}
+
+	products, err := traceProductTree(stub, root.ID, func(product Product) []string {
+		return product.Splits
+	})
+	if err != nil {
+		return MassBalance{}, err
+	}
+	balance := MassBalance{
+		TrackingID: root.ID,
+		Unit:       root.Unit,
+		Created:    root.Created,
+		Records:    len(products),
+	}
+	for _, product := range products {
+		switch {
+		case product.Health == HealthDestroyed:
+			balance.Destroyed = RoundQuantity(balance.Destroyed + product.Quantity)
+		case product.ConsumedBy != "":
+			balance.Consumed = RoundQuantity(balance.Consumed + product.Quantity)
+		case product.Sold:
+			balance.Sold = RoundQuantity(balance.Sold + product.Quantity)
+		default:
+			balance.Available = RoundQuantity(balance.Available + product.Quantity)
+		}
+	}
+	balance.Balanced = EqualQuantity(balance.Available+balance.Consumed+balance.Sold+balance.Destroyed, balance.Created)
+	return balance, nil
+}
+
+// putProducts writes the supplied products to the ledger
+func putProducts(stub shim.ChaincodeStubInterface, products ...Product) peer.Response {
+	for _, product := range products {
+		productBytes, _ := json.Marshal(product)
+		if err := stub.PutState(product.ID, productBytes); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	return shim.Success(nil)
+}
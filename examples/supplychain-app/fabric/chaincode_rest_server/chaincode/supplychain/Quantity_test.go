@@ -0,0 +1,113 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestQuantity(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	producer := org1Identity.subject()
+	carrier := carrierIdentity.subject()
+
+	getProduct := func(trackingID string) Product {
+		var product Product
+		json.Unmarshal(bed.mustInvoke("getProduct", trackingID), &product)
+		return product
+	}
+	getBalance := func(trackingID string) MassBalance {
+		var balance MassBalance
+		json.Unmarshal(bed.mustInvoke("getMassBalance", trackingID), &balance)
+		return balance
+	}
+
+	g.Describe("Bulk Products", func() {
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC))
+			bed.as(org1Identity)
+			bed.mustInvoke("createContainer", `{"trackingID":"drum-1","counterparties":[]}`)
+			bed.mustInvoke("createProduct", `{"trackingID":"api-1","productName":"API Powder","lot":"B42","quantity":1000,"unit":"kg","counterparties":["`+carrier+`"]}`)
+		})
+
+		g.It("should split part of the quantity into a new product keeping its lineage", func() {
+			Expect(string(bed.mustInvoke("splitProduct", "api-1", "250.5", "api-2"))).To(Equal(`{"generatedID":"api-2","remaining":749.5}`))
+			Expect(getProduct("api-1").Splits).To(Equal([]string{"api-2"}))
+			split := getProduct("api-2")
+			Expect(split.Quantity).To(Equal(250.5))
+			Expect(split.Unit).To(Equal("kg"))
+			Expect(split.Lot).To(Equal("B42"))
+			Expect(split.SplitFrom).To(Equal("api-1"))
+			Expect(split.Custodian).To(Equal(producer))
+		})
+
+		g.It("should package and claim partial quantities", func() {
+			bed.mustInvoke("package", "drum-1", "api-1", "200", "api-2")
+			Expect(getProduct("api-2").ContainerID).To(Equal("drum-1"))
+			Expect(getProduct("api-1").ContainerID).To(Equal(""))
+			var container Container
+			json.Unmarshal(bed.mustInvoke("getContainer", "drum-1"), &container)
+			Expect(container.Contents).To(Equal([]string{"api-2"}))
+
+			bed.as(carrierIdentity)
+			bed.mustInvoke("claimProduct", "api-1", "London", "300", "api-3")
+			Expect(getProduct("api-3").Custodian).To(Equal(carrier))
+			Expect(getProduct("api-1").Custodian).To(Equal(producer))
+			Expect(getProduct("api-1").Quantity).To(Equal(500.0))
+
+			Expect(getBalance("api-3")).To(Equal(MassBalance{
+				TrackingID: "api-1",
+				Unit:       "kg",
+				Created:    1000,
+				Available:  1000,
+				Records:    3,
+				Balanced:   true,
+			}))
+		})
+
+		g.It("should account for consumed and destroyed quantities in the mass balance", func() {
+			bed.mustInvoke("splitProduct", "api-1", "0.125", "api-2")
+			bed.mustInvoke("assembleProduct", `{"trackingID":"tablet-1","productName":"Tablets","inputs":["api-2"]}`)
+
+			balance := getBalance("api-1")
+			Expect(balance.Available).To(Equal(999.875))
+			Expect(balance.Consumed).To(Equal(0.125))
+			Expect(balance.Balanced).To(BeTrue())
+
+			bed.mustInvoke("destroyAsset", "api-1", `{"method":"incineration","witness":"`+carrier+`","reason":"contaminated"}`)
+			balance = getBalance("api-1")
+			Expect(balance.Available).To(Equal(0.0))
+			Expect(balance.Destroyed).To(Equal(999.875))
+			Expect(balance.Balanced).To(BeTrue())
+		})
+
+		g.It("should return 400 for quantities that cannot be split off", func() {
+			for i, quantity := range []string{"1000", "1500", "0", "-5", "0.0000001", "lots"} {
+				response := bed.invoke("splitProduct", "api-1", quantity, "api-2")
+				Expect(response.Status).To(BeEquivalentTo(400), quantity, i)
+			}
+			Expect(bed.invoke("getProduct", "api-2").Status).To(BeEquivalentTo(404))
+			Expect(getProduct("api-1").Quantity).To(Equal(1000.0))
+		})
+
+		g.It("should return 400 for a product with an unknown unit", func() {
+			response := bed.invoke("createProduct", `{"trackingID":"api-9","productName":"API Powder","quantity":5,"unit":"bushel"}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+		})
+
+		g.It("should return 403 if the identity does not hold the product", func() {
+			bed.as(carrierIdentity)
+			response := bed.invoke("splitProduct", "api-1", "10", "api-2")
+			Expect(response.Status).To(BeEquivalentTo(403))
+		})
+	})
+}
@@ -0,0 +1,389 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// rmaKey is the composite key object type return merchandise authorizations are stored under
+const rmaKey = "rma"
+
+// returnPolicyKey is the composite key object type return policies are stored under, by manufacturer organization
+const returnPolicyKey = "returnPolicy"
+
+// setReturnPolicy sets the number of days after a sale a sold product manufactured by the organization of the
+// current user can be returned
+func (s *SmartContract) setReturnPolicy(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if !identity.CanInvoke("setReturnPolicy") {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, cannot invoke setReturnPolicy"),
+		}
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	windowDays, err := strconv.Atoi(args[0])
+	policy := ReturnPolicy{Type: returnPolicyKey, Organization: identity.Organization, WindowDays: windowDays}
+	if err == nil {
+		err = policy.Validate()
+	}
+	if err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+
+	key, _ := stub.CreateCompositeKey(returnPolicyKey, []string{identity.Organization})
+	policyBytes, _ := json.Marshal(policy)
+	if err := stub.PutState(key, policyBytes); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	s.logger.Infof("Updated return window of %s to %d days\n", identity.Organization, policy.WindowDays)
+	return shim.Success(policyBytes)
+}
+
+// requestReturn requests the return of a product held by the current user to its manufacturer, sold products can
+// be returned within the return window of the manufacturer
+func (s *SmartContract) requestReturn(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	trackingID := args[0]
+	reason := args[1]
+	if strings.TrimSpace(reason) == "" {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: a reason is required "),
+		}
+	}
+
+	productBytes, _ := stub.GetState(trackingID)
+	var product Product
+	if len(productBytes) == 0 || json.Unmarshal(productBytes, &product) != nil {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Product %s Not Found", trackingID),
+		}
+	}
+	if identity.Cert.Subject.String() != product.Custodian {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, product not held by identity"),
+		}
+	}
+	if product.RMA != "" {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Product %s is already being returned under %s", trackingID, product.RMA),
+		}
+	}
+	if product.ConsumedBy != "" || product.Health == HealthDestroyed {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Product %s is consumed or destroyed", trackingID),
+		}
+	}
+	if product.Sold {
+		policy, err := loadReturnPolicy(stub, product.Manufacturer)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		//products sold before the sale time was recorded fall back to their last update
+		soldAt := product.SoldAt
+		if soldAt == 0 {
+			soldAt = product.Timestamp
+		}
+		if !policy.Allows(soldAt, s.clock.Now()) {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("Product %s was sold more than %d days ago", trackingID, policy.WindowDays),
+			}
+		}
+	}
+
+	rma := RMA{
+		Type:       rmaKey,
+		ID:         stub.GetTxID(),
+		TrackingID: trackingID,
+		Requester:  identity.Cert.Subject.String(),
+		Reason:     reason,
+		Status:     ReturnRequested,
+		Timestamp:  int64(s.clock.Now().UTC().Unix()),
+	}
+	product.RMA = rma.ID
+	product.Returns = append(product.Returns, rma.ID)
+	product.Timestamp = rma.Timestamp
+	if response := putProducts(stub, product); response.Status != shim.OK {
+		return response
+	}
+	if err := putRMA(stub, rma); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	response := map[string]interface{}{
+		"generatedID": rma.ID,
+	}
+	bytes, _ := json.Marshal(response)
+
+	s.logger.Infof("Requested return %s of %s\n", rma.ID, trackingID)
+	return shim.Success(bytes)
+}
+
+// approveReturn approves a requested return, the product is shipped back to the approving manufacturer through the
+// normal custody steps tagged with the RMA
+func (s *SmartContract) approveReturn(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	return s.decideReturn(stub, args, ReturnApproved)
+}
+
+// rejectReturn rejects a requested return
+func (s *SmartContract) rejectReturn(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	return s.decideReturn(stub, args, ReturnRejected)
+}
+
+// receiveReturn receives a returned product held by the current user with an outcome of restock, refurbish or destroy,
+// a destroyed product is issued a certificate of destruction from the destruction request passed as third argument
+func (s *SmartContract) receiveReturn(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 2 && len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 2 or 3")
+	}
+	outcome := args[1]
+	if !ValidOutcome(outcome) {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: unknown outcome %s, expecting one of %v ", outcome, ReturnOutcomes),
+		}
+	}
+	var destruction DestructionRequest
+	if outcome == OutcomeDestroy {
+		if len(args) != 3 {
+			return shim.Error("Incorrect number of arguments. Expecting a destruction request to destroy the product")
+		}
+		if err := json.Unmarshal([]byte(args[2]), &destruction); err != nil {
+			return shim.Error(err.Error())
+		}
+		if err := destruction.Validate(identity.Cert.Subject.String()); err != nil {
+			return peer.Response{
+				Status:  400,
+				Message: fmt.Sprintf("Error: %s ", err),
+			}
+		}
+	}
+
+	rma, product, response := getAccessibleRMA(stub, identity, args[0])
+	if rma == nil {
+		return response
+	}
+	if rma.Status != ReturnApproved {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Return %s is %s", rma.ID, rma.Status),
+		}
+	}
+	receiver := identity.Cert.Subject.String()
+	if receiver != rma.Receiver || receiver != product.Custodian {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, return not held by its receiver"),
+		}
+	}
+	if product.ContainerID != "" {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Product %s needs to be unpackaged before it can be received", product.ID),
+		}
+	}
+
+	timestamp := int64(s.clock.Now().UTC().Unix())
+	rma.Status = ReturnReceived
+	rma.Outcome = outcome
+	rma.Timestamp = timestamp
+	product.Sold = false
+	product.RMA = ""
+	product.Timestamp = timestamp
+	if outcome == OutcomeDestroy {
+		if product.Health == HealthDestroyed {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("Item %s is already destroyed", product.ID),
+			}
+		}
+		if response := s.destroyItems(stub, identity, product.ID, destruction, nil, []Product{*product}); response.Status != shim.OK {
+			return response
+		}
+	} else if response := putProducts(stub, *product); response.Status != shim.OK {
+		return response
+	}
+	if err := putRMA(stub, *rma); err != nil {
+		return shim.Error(err.Error())
+	}
+	rmaBytes, _ := json.Marshal(rma)
+
+	s.logger.Infof("Received return %s of %s to %s\n", rma.ID, product.ID, outcome)
+	return shim.Success(rmaBytes)
+}
+
+// getSingleReturn retrieves a return merchandise authorization by rmaID
+func (s *SmartContract) getSingleReturn(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	rma, _, response := getAccessibleRMA(stub, identity, args[0])
+	if rma == nil {
+		return response
+	}
+	rmaBytes, _ := json.Marshal(rma)
+	return shim.Success(rmaBytes)
+}
+
+// decideReturn approves or rejects a requested return of a product manufactured by the organization of the current
+// user
+func (s *SmartContract) decideReturn(stub shim.ChaincodeStubInterface, args []string, status string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	function := "approveReturn"
+	if status == ReturnRejected {
+		function = "rejectReturn"
+	}
+	if !identity.CanInvoke(function) {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, cannot invoke %s", function),
+		}
+	}
+
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 or 2")
+	}
+
+	rma, product, response := getAccessibleRMA(stub, identity, args[0])
+	if rma == nil {
+		return response
+	}
+	manufacturer, err := resolveManufacturer(stub, product, identity)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if manufacturer != identity.Organization {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, product not manufactured by identity"),
+		}
+	}
+	if rma.Status != ReturnRequested {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Return %s is already %s", rma.ID, rma.Status),
+		}
+	}
+
+	rma.Status = status
+	rma.Timestamp = int64(s.clock.Now().UTC().Unix())
+	if len(args) == 2 {
+		rma.Note = args[1]
+	}
+	if status == ReturnApproved {
+		rma.Receiver = identity.Cert.Subject.String()
+	} else {
+		product.RMA = ""
+		product.Timestamp = rma.Timestamp
+		if response := putProducts(stub, *product); response.Status != shim.OK {
+			return response
+		}
+	}
+	if err := putRMA(stub, *rma); err != nil {
+		return shim.Error(err.Error())
+	}
+	rmaBytes, _ := json.Marshal(rma)
+
+	s.logger.Infof("Return %s is %s\n", rma.ID, rma.Status)
+	return shim.Success(rmaBytes)
+}
+
+// loadReturnPolicy returns the return policy stored by the manufacturer organization, or the default policy if it set
+// none
+func loadReturnPolicy(stub shim.ChaincodeStubInterface, manufacturer string) (ReturnPolicy, error) {
+	if manufacturer == "" {
+		return ReturnPolicy{Type: returnPolicyKey, WindowDays: DefaultReturnWindow}, nil
+	}
+	key, _ := stub.CreateCompositeKey(returnPolicyKey, []string{manufacturer})
+	policyBytes, err := stub.GetState(key)
+	if err != nil || len(policyBytes) == 0 {
+		return ReturnPolicy{Type: returnPolicyKey, WindowDays: DefaultReturnWindow}, err
+	}
+	var policy ReturnPolicy
+	err = json.Unmarshal(policyBytes, &policy)
+	return policy, err
+}
+
+// getAccessibleRMA returns a 404 response if the return or its product does not exist or the identity is not a
+// participant of the product
+func getAccessibleRMA(stub shim.ChaincodeStubInterface, identity *Identity, rmaID string) (*RMA, *Product, peer.Response) {
+	key, _ := stub.CreateCompositeKey(rmaKey, []string{rmaID})
+	rmaBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, nil, shim.Error(err.Error())
+	}
+	var rma RMA
+	var product Product
+	if len(rmaBytes) == 0 || json.Unmarshal(rmaBytes, &rma) != nil {
+		return nil, nil, peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Return %s Not Found", rmaID),
+		}
+	}
+	productBytes, _ := stub.GetState(rma.TrackingID)
+	if len(productBytes) == 0 || json.Unmarshal(productBytes, &product) != nil || !product.AccessibleBy(identity) {
+		return nil, nil, peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Return %s Not Found", rmaID),
+		}
+	}
+	return &rma, &product, shim.Success(nil)
+}
+
+func putRMA(stub shim.ChaincodeStubInterface, rma RMA) error {
+	key, _ := stub.CreateCompositeKey(rmaKey, []string{rma.ID})
+	rmaBytes, _ := json.Marshal(rma)
+	return stub.PutState(key, rmaBytes)
+}
@@ -0,0 +1,144 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestReturn(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	now := time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC)
+	producer := org1Identity.subject()
+	store := carrierIdentity.subject()
+	org2Identity := testIdentity{mspID: "Org2MSP", certPath: org1Identity.certPath}
+
+	//sellUnit has Org1 make a unit that the store takes custody of and sells at soldAt
+	sellUnit := func(soldAt time.Time) {
+		bed = newTestbed(chaincode, soldAt)
+		bed.as(org1Identity)
+		bed.mustInvoke("createProduct", `{"trackingID":"unit-1","productName":"Blood Pressure Monitor","counterparties":["`+store+`"]}`)
+		bed.as(carrierIdentity)
+		bed.mustInvoke("claimProduct", "unit-1", "London")
+		bed.mustInvoke("sellProduct", "unit-1")
+		bed.clock.Set(now)
+	}
+	getProduct := func() Product {
+		var product Product
+		json.Unmarshal(bed.mustInvoke("getProduct", "unit-1"), &product)
+		return product
+	}
+	requestReturn := func() int32 {
+		return bed.invoke("requestReturn", "unit-1", "display defective").Status
+	}
+
+	g.Describe("Return Merchandise Authorization", func() {
+		g.BeforeEach(func() {
+			sellUnit(time.Date(2019, 3, 10, 9, 0, 0, 0, time.UTC))
+		})
+
+		g.It("should return a sold product to its manufacturer and restock it", func() {
+			Expect(requestReturn()).To(BeEquivalentTo(200))
+			rmaID := fmt.Sprintf("tx%d", bed.tx)
+			Expect(getProduct().RMA).To(Equal(rmaID))
+
+			bed.as(org1Identity)
+			bed.mustInvoke("approveReturn", rmaID)
+			Expect(bed.invoke("receiveReturn", rmaID, OutcomeRestock).Status).To(BeEquivalentTo(403))
+
+			bed.mustInvoke("claimProduct", "unit-1", "Zurich")
+			Expect(getProduct().RMA).To(Equal(rmaID))
+			var rma RMA
+			json.Unmarshal(bed.mustInvoke("receiveReturn", rmaID, OutcomeRestock), &rma)
+			Expect(rma.Status).To(Equal(ReturnReceived))
+			Expect(rma.Receiver).To(Equal(producer))
+			Expect(rma.Outcome).To(Equal(OutcomeRestock))
+			product := getProduct()
+			Expect(product.Sold).To(BeFalse())
+			Expect(product.RMA).To(Equal(""))
+			Expect(product.Returns).To(Equal([]string{rmaID}))
+		})
+
+		g.It("should destroy a returned product received for destruction", func() {
+			requestReturn()
+			rmaID := getProduct().RMA
+			bed.as(org1Identity)
+			bed.mustInvoke("approveReturn", rmaID)
+			bed.mustInvoke("claimProduct", "unit-1", "Zurich")
+			Expect(bed.invoke("receiveReturn", rmaID, OutcomeDestroy).Status).To(BeEquivalentTo(500))
+
+			destruction := `{"method":"shredding","witness":"` + store + `","reason":"display defective"}`
+			bed.mustInvoke("receiveReturn", rmaID, OutcomeDestroy, destruction)
+			certificateID := fmt.Sprintf("tx%d", bed.tx)
+			product := getProduct()
+			Expect(product.Health).To(Equal(HealthDestroyed))
+			Expect(product.HealthReason).To(Equal(ReasonDisposal))
+			Expect(product.RMA).To(Equal(""))
+
+			var certificate DestructionCertificate
+			json.Unmarshal(bed.mustInvoke("getDestructionCertificate", "unit-1"), &certificate)
+			Expect(certificate.ID).To(Equal(certificateID))
+			Expect(certificate.Items).To(Equal([]string{"unit-1"}))
+			Expect(certificate.Witness).To(Equal(store))
+		})
+
+		g.It("should only return sold products within the return window", func() {
+			sellUnit(time.Date(2019, 1, 10, 9, 0, 0, 0, time.UTC))
+			Expect(requestReturn()).To(BeEquivalentTo(403))
+
+			bed.as(org1Identity)
+			bed.mustInvoke("setReturnPolicy", "90")
+			bed.as(carrierIdentity)
+			Expect(requestReturn()).To(BeEquivalentTo(200))
+			Expect(requestReturn()).To(BeEquivalentTo(403))
+		})
+
+		g.It("should only apply the return policy of the manufacturer of the product", func() {
+			sellUnit(time.Date(2019, 1, 10, 9, 0, 0, 0, time.UTC))
+			bed.as(org2Identity)
+			bed.mustInvoke("setReturnPolicy", "90")
+			bed.as(carrierIdentity)
+			Expect(requestReturn()).To(BeEquivalentTo(403))
+		})
+
+		g.It("should only let the manufacturer of the product approve or reject its return", func() {
+			requestReturn()
+			rmaID := getProduct().RMA
+			bed.as(org2Identity)
+			Expect(bed.invoke("approveReturn", rmaID).Status).To(BeEquivalentTo(403))
+			Expect(bed.invoke("rejectReturn", rmaID).Status).To(BeEquivalentTo(403))
+			Expect(getProduct().RMA).To(Equal(rmaID))
+		})
+
+		g.It("should clear the return of a rejected request", func() {
+			requestReturn()
+			rmaID := getProduct().RMA
+			Expect(bed.invoke("approveReturn", rmaID).Status).To(BeEquivalentTo(403))
+
+			bed.as(org1Identity)
+			bed.mustInvoke("rejectReturn", rmaID, "damage caused by user")
+			Expect(getProduct().RMA).To(Equal(""))
+			Expect(getProduct().Returns).To(Equal([]string{rmaID}))
+
+			var rma RMA
+			json.Unmarshal(bed.mustInvoke("getReturn", rmaID), &rma)
+			Expect(rma.Status).To(Equal(ReturnRejected))
+			Expect(rma.Note).To(Equal("damage caused by user"))
+		})
+
+		g.It("should return 400 for an unknown outcome or a missing reason", func() {
+			Expect(bed.invoke("requestReturn", "unit-1", " ").Status).To(BeEquivalentTo(400))
+			Expect(bed.invoke("receiveReturn", "tx1", "resell").Status).To(BeEquivalentTo(400))
+		})
+	})
+}
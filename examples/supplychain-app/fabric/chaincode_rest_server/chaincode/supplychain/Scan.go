@@ -0,0 +1,230 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// scanKey is the composite key object type recorded scans are stored under, by trackingID and scanID
+const scanKey = "scan"
+
+// recordScan scans an item like scan and records the scan event with its location, identity and device, flagging
+// impossible travel since the previous recorded scan, scans after a sale or destruction and scans by non-participants.
+// Scans of a shipped container by its participants are checked against the planned route of the shipment.
+// Every recorded scan is signed by a registered device over trackingID|location|timestamp, a timestamp within
+// MaxScanAge of the transaction and newer than the last scan of the device.
+func (s *SmartContract) recordScan(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	var request ScanRequest
+	if err := json.Unmarshal([]byte(args[0]), &request); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := request.Validate(int64(s.clock.Now().UTC().Unix())); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	//only accept scans signed by a registered device, newer than its last scan so they cannot be replayed
+	device, verified := verifyDeviceSignature(stub, request.DeviceID, request.Payload(), request.Signature)
+	if device == nil {
+		return verified
+	}
+	if request.Timestamp <= device.LastScan {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: scan at %d is not newer than the last scan of device %s ", request.Timestamp, device.ID),
+		}
+	}
+	device.LastScan = request.Timestamp
+	if err := putDevice(stub, *device); err != nil {
+		return shim.Error(err.Error())
+	}
+	location := request.Location
+
+	scanResponse := s.scan(stub, []string{request.TrackingID})
+	if scanResponse.Status != shim.OK {
+		return scanResponse
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(scanResponse.Payload, &response); err != nil {
+		return shim.Error(err.Error())
+	}
+	response["device"] = device.ID
+	status, _ := response["status"].(string)
+	//items that are not on the ledger have no scans to compare with
+	if status == "new" {
+		bytes, _ := json.Marshal(response)
+		return shim.Success(bytes)
+	}
+	identifier, _ := resolveIdentifier(stub, request.TrackingID)
+	trackingID := identifier.TrackingID
+
+	var sold, destroyed, participant bool
+	itemBytes, _ := stub.GetState(trackingID)
+	var product Product
+	if err := json.Unmarshal(itemBytes, &product); err == nil {
+		sold, destroyed = product.Sold, product.Health == HealthDestroyed
+		participant = product.HasParticipant(identity.Cert.Subject.String())
+	} else {
+		var container Container
+		if err := json.Unmarshal(itemBytes, &container); err != nil {
+			return shim.Error(err.Error())
+		}
+		destroyed = container.Health == HealthDestroyed
+		participant = container.AccessibleBy(identity)
+		if participant {
+			deviations, err := s.trackShipment(stub, container, "", location)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			if len(deviations) != 0 {
+				response["deviations"] = deviations
+			}
+		}
+	}
+
+	scans, err := getScans(stub, trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	var previous *ScanEvent
+	for i := range scans {
+		if previous == nil || scans[i].Timestamp >= previous.Timestamp {
+			previous = &scans[i]
+		}
+	}
+
+	event := ScanEvent{
+		Type:         scanKey,
+		ID:           stub.GetTxID(),
+		TrackingID:   trackingID,
+		Location:     location,
+		Scanner:      identity.Cert.Subject.String(),
+		Organization: identity.Organization,
+		DeviceID:     device.ID,
+		Status:       status,
+		Timestamp:    int64(s.clock.Now().UTC().Unix()),
+	}
+	event.Detect(previous, sold, destroyed, participant)
+	key, _ := stub.CreateCompositeKey(scanKey, []string{trackingID, event.ID})
+	eventBytes, _ := json.Marshal(event)
+	if err := stub.PutState(key, eventBytes); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	response["scanID"] = event.ID
+	if event.Suspicious() {
+		s.logger.Warningf("Suspicious scan of %s at %s: %v\n", trackingID, location, event.Anomalies)
+		response["anomalies"] = event.Anomalies
+	}
+	bytes, _ := json.Marshal(response)
+	return shim.Success(bytes)
+}
+
+// getScanHistory retrieves the recorded scans of an item the current user participates in
+func (s *SmartContract) getScanHistory(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	trackingID := args[0]
+
+	if !itemHasParticipant(stub, trackingID, identity.Cert.Subject.String()) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Item %s Not Found", trackingID),
+		}
+	}
+	scans, err := getScans(stub, trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	scansBytes, _ := json.Marshal(scans)
+	return shim.Success(scansBytes)
+}
+
+// getSuspiciousItems retrieves the items the current user participates in that have flagged recorded scans, with
+// the flagged scans
+func (s *SmartContract) getSuspiciousItems(stub shim.ChaincodeStubInterface) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(scanKey, []string{})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer iterator.Close()
+	items := []SuspiciousItem{}
+	participant := map[string]bool{}
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		var event ScanEvent
+		if err := json.Unmarshal(state.Value, &event); err != nil {
+			return shim.Error(err.Error())
+		}
+		if !event.Suspicious() {
+			continue
+		}
+		if _, ok := participant[event.TrackingID]; !ok {
+			participant[event.TrackingID] = itemHasParticipant(stub, event.TrackingID, identity.Cert.Subject.String())
+		}
+		if !participant[event.TrackingID] {
+			continue
+		}
+		//scans of an item are adjacent as they share the key prefix
+		if len(items) == 0 || items[len(items)-1].TrackingID != event.TrackingID {
+			items = append(items, SuspiciousItem{TrackingID: event.TrackingID, Anomalies: []string{}})
+		}
+		items[len(items)-1].Add(event)
+	}
+	itemsBytes, _ := json.Marshal(items)
+	return shim.Success(itemsBytes)
+}
+
+// getScans returns the recorded scans of an item
+func getScans(stub shim.ChaincodeStubInterface, trackingID string) ([]ScanEvent, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(scanKey, []string{trackingID})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+	scans := []ScanEvent{}
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var event ScanEvent
+		if err := json.Unmarshal(state.Value, &event); err != nil {
+			return nil, err
+		}
+		scans = append(scans, event)
+	}
+	return scans, nil
+}
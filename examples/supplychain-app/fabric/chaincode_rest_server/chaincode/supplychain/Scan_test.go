@@ -0,0 +1,100 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestScan(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	holder := manufacturerIdentity.subject()
+	zurich := "47.38/8.54/Zurich"
+	london := "51.50/-0.13/London"
+	scannerKey := newDeviceKey()
+
+	recordScan := func(location string) map[string]interface{} {
+		request := ScanRequest{TrackingID: "bag-1", Location: location, DeviceID: "scanner-1", Timestamp: bed.clock.Now().Unix()}
+		var result map[string]interface{}
+		json.Unmarshal(bed.mustInvoke("recordScan", string(signScan(scannerKey, request))), &result)
+		return result
+	}
+	getSuspiciousItems := func() []SuspiciousItem {
+		var items []SuspiciousItem
+		json.Unmarshal(bed.mustInvoke("getSuspiciousItems"), &items)
+		return items
+	}
+
+	g.Describe("Recorded Scans", func() {
+		//the holder takes a handbag of Org1 and registers the scanner it checks it with
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC))
+			bed.as(org1Identity)
+			bed.mustInvoke("createProduct", `{"trackingID":"bag-1","productName":"Handbag","counterparties":["`+holder+`"]}`)
+			bed.as(manufacturerIdentity)
+			bed.mustInvoke("claimProduct", "bag-1", "Zurich")
+			requestBytes, _ := json.Marshal(DeviceRequest{ID: "scanner-1", PublicKey: publicKeyPEM(&scannerKey.PublicKey)})
+			bed.mustInvoke("registerDevice", string(requestBytes))
+		})
+
+		g.It("should record scans without anomalies", func() {
+			result := recordScan(zurich)
+			Expect(result["status"]).To(Equal("owned"))
+			Expect(result["scanID"]).To(Equal(fmt.Sprintf("tx%d", bed.tx)))
+			Expect(result["device"]).To(Equal("scanner-1"))
+			Expect(result).NotTo(HaveKey("anomalies"))
+
+			bed.clock.Add(3 * time.Hour)
+			Expect(recordScan(london)).NotTo(HaveKey("anomalies"))
+
+			var scans []ScanEvent
+			json.Unmarshal(bed.mustInvoke("getScanHistory", "bag-1"), &scans)
+			Expect(scans).To(HaveLen(2))
+			Expect(scans[1].Scanner).To(Equal(holder))
+			Expect(scans[1].DeviceID).To(Equal("scanner-1"))
+			Expect(getSuspiciousItems()).To(BeEmpty())
+		})
+
+		g.It("should flag a cloned code scanned in two cities at once", func() {
+			recordScan(zurich)
+			bed.clock.Add(10 * time.Minute)
+			result := recordScan(london)
+			Expect(result["anomalies"]).To(ConsistOf(AnomalyImpossibleTravel))
+
+			items := getSuspiciousItems()
+			Expect(items).To(HaveLen(1))
+			Expect(items[0].TrackingID).To(Equal("bag-1"))
+			Expect(items[0].Scans).To(HaveLen(1))
+			Expect(items[0].Scans[0].Location).To(Equal(london))
+		})
+
+		g.It("should flag scans after a sale and by non-participants", func() {
+			bed.mustInvoke("sellProduct", "bag-1")
+			bed.as(carrierIdentity)
+			result := recordScan(london)
+			Expect(result["anomalies"]).To(ConsistOf(AnomalyAfterSale, AnomalyNonParticipant))
+			Expect(getSuspiciousItems()).To(BeEmpty())
+
+			bed.as(manufacturerIdentity)
+			items := getSuspiciousItems()
+			Expect(items).To(HaveLen(1))
+			Expect(items[0].Anomalies).To(Equal([]string{AnomalyAfterSale, AnomalyNonParticipant}))
+		})
+
+		g.It("should require a location", func() {
+			request := ScanRequest{TrackingID: "bag-1", Location: " ", DeviceID: "scanner-1", Timestamp: bed.clock.Now().Unix()}
+			response := bed.invoke("recordScan", string(signScan(scannerKey, request)))
+			Expect(response.Status).To(BeEquivalentTo(400))
+		})
+	})
+}
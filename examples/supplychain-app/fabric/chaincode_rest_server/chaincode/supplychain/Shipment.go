@@ -0,0 +1,215 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// shipmentKey is the composite key object type shipments are stored under
+const shipmentKey = "shipment"
+
+// createShipment plans the route of containers held by the current user from an origin to a destination
+func (s *SmartContract) createShipment(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	var request ShipmentRequest
+	if err := json.Unmarshal([]byte(args[0]), &request); err != nil {
+		return shim.Error(err.Error())
+	}
+	eta, err := request.Validate()
+	if err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+
+	existing, err := getShipment(stub, request.ID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if existing != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Existing Shipment %s Found", request.ID),
+		}
+	}
+
+	//every container must be held by the shipper and not be on its way in another shipment
+	containers := []Container{}
+	for _, containerID := range request.Containers {
+		containerBytes, _ := stub.GetState(containerID)
+		var container Container
+		if len(containerBytes) == 0 || json.Unmarshal(containerBytes, &container) != nil {
+			return peer.Response{
+				Status:  404,
+				Message: fmt.Sprintf("Container %s Not Found", containerID),
+			}
+		}
+		if identity.Cert.Subject.String() != container.Custodian {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("You are not authorized to perform this transaction, container %s not held by identity", containerID),
+			}
+		}
+		if container.ShipmentID != "" {
+			current, err := getShipment(stub, container.ShipmentID)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			if current != nil && current.ArrivedAt == 0 {
+				return peer.Response{
+					Status:  403,
+					Message: fmt.Sprintf("Container %s is in shipment %s which has not arrived", containerID, current.ID),
+				}
+			}
+		}
+		containers = append(containers, container)
+	}
+
+	shipper := identity.Cert.Subject.String()
+	shipment := Shipment{
+		Type:               shipmentKey,
+		ID:                 request.ID,
+		Containers:         request.Containers,
+		Origin:             request.Origin,
+		Destination:        request.Destination,
+		Waypoints:          request.Waypoints,
+		ExpectedCustodians: request.ExpectedCustodians,
+		ETA:                eta.UTC().Unix(),
+		Shipper:            shipper,
+		Participants:       append([]string{shipper}, request.ExpectedCustodians...),
+		Deviations:         []Deviation{},
+		Timestamp:          int64(s.clock.Now().UTC().Unix()),
+	}
+	if shipment.Waypoints == nil {
+		shipment.Waypoints = []string{}
+	}
+	if shipment.ExpectedCustodians == nil {
+		shipment.ExpectedCustodians = []string{}
+	}
+	for _, container := range containers {
+		container.ShipmentID = shipment.ID
+		containerBytes, _ := json.Marshal(container)
+		if err := stub.PutState(container.ID, containerBytes); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	if err := putShipment(stub, shipment); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	response := map[string]interface{}{
+		"generatedID": shipment.ID,
+	}
+	bytes, _ := json.Marshal(response)
+
+	s.logger.Infof("Wrote Shipment: %s\n", shipment.ID)
+	return shim.Success(bytes)
+}
+
+// getSingleShipment retrieves a shipment with its route deviations by shipmentID
+func (s *SmartContract) getSingleShipment(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	shipment, err := getShipment(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if shipment == nil || !shipment.AccessibleBy(identity) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Shipment %s Not Found", args[0]),
+		}
+	}
+	shipmentBytes, _ := json.Marshal(shipment)
+	return shim.Success(shipmentBytes)
+}
+
+// getShipmentExceptions retrieves the shipments of the current user that deviated from their route or are late
+func (s *SmartContract) getShipmentExceptions(stub shim.ChaincodeStubInterface) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(shipmentKey, []string{})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer iterator.Close()
+	shipments := []Shipment{}
+	for iterator.HasNext() {
+		state, err := iterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		var shipment Shipment
+		if err := json.Unmarshal(state.Value, &shipment); err != nil {
+			return shim.Error(err.Error())
+		}
+		if shipment.AccessibleBy(identity) && (len(shipment.Deviations) != 0 || shipment.Late(s.clock.Now())) {
+			shipments = append(shipments, shipment)
+		}
+	}
+	shipmentsBytes, _ := json.Marshal(shipments)
+	return shim.Success(shipmentsBytes)
+}
+
+// trackShipment checks a claim or scan of a container against the route of the shipment it is in, an empty custodian
+// or location is not checked. Deviations are recorded on the shipment rather than rejected.
+func (s *SmartContract) trackShipment(stub shim.ChaincodeStubInterface, container Container, custodian string, location string) ([]Deviation, error) {
+	if container.ShipmentID == "" {
+		return nil, nil
+	}
+	shipment, err := getShipment(stub, container.ShipmentID)
+	if err != nil || shipment == nil || shipment.ArrivedAt != 0 {
+		return nil, err
+	}
+	deviations := shipment.Track(container.ID, custodian, location, int64(s.clock.Now().UTC().Unix()))
+	for _, deviation := range deviations {
+		s.logger.Warningf("Shipment %s deviates from its route: %s %s\n", shipment.ID, deviation.Type, deviation.TrackingID)
+	}
+	return deviations, putShipment(stub, *shipment)
+}
+
+func getShipment(stub shim.ChaincodeStubInterface, shipmentID string) (*Shipment, error) {
+	key, _ := stub.CreateCompositeKey(shipmentKey, []string{shipmentID})
+	shipmentBytes, err := stub.GetState(key)
+	if err != nil || len(shipmentBytes) == 0 {
+		return nil, err
+	}
+	var shipment Shipment
+	if err := json.Unmarshal(shipmentBytes, &shipment); err != nil {
+		return nil, err
+	}
+	return &shipment, nil
+}
+
+func putShipment(stub shim.ChaincodeStubInterface, shipment Shipment) error {
+	key, _ := stub.CreateCompositeKey(shipmentKey, []string{shipment.ID})
+	shipmentBytes, _ := json.Marshal(shipment)
+	return stub.PutState(key, shipmentBytes)
+}
@@ -0,0 +1,137 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	"github.com/hyperledger/fabric/protos/peer"
+	. "github.com/onsi/gomega"
+)
+
+func TestShipment(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	carrier := carrierIdentity.subject()
+	producer := org1Identity.subject()
+	scannerKey := newDeviceKey()
+
+	getShipment := func() Shipment {
+		var shipment Shipment
+		json.Unmarshal(bed.mustInvoke("getShipment", "shipment-1"), &shipment)
+		return shipment
+	}
+	recordScan := func(location string) peer.Response {
+		request := ScanRequest{TrackingID: "pallet-1", Location: location, DeviceID: "scanner-1", Timestamp: bed.clock.Now().Unix()}
+		return bed.invoke("recordScan", string(signScan(scannerKey, request)))
+	}
+	getExceptions := func() []Shipment {
+		var shipments []Shipment
+		json.Unmarshal(bed.mustInvoke("getShipmentExceptions"), &shipments)
+		return shipments
+	}
+
+	g.Describe("Shipment Route", func() {
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC))
+			bed.as(manufacturerIdentity)
+			bed.mustInvoke("createContainer", `{"trackingID":"pallet-1","counterparties":["`+carrier+`","`+producer+`"]}`)
+			bed.mustInvoke("createShipment", `{"shipmentID":"shipment-1","containers":["pallet-1"],"origin":"Zurich","destination":"London","waypoints":["Basel","Frankfurt"],"expectedCustodians":["`+carrier+`"],"eta":"2019-03-16T12:00:00Z"}`)
+			deviceBytes, _ := json.Marshal(DeviceRequest{ID: "scanner-1", PublicKey: publicKeyPEM(&scannerKey.PublicKey)})
+			bed.mustInvoke("registerDevice", string(deviceBytes))
+		})
+
+		g.It("should follow claims and recorded scans along the planned route", func() {
+			response := recordScan("Basel")
+			Expect(response.Status).To(BeEquivalentTo(200))
+			Expect(string(response.Payload)).To(Equal(fmt.Sprintf(`{"device":"scanner-1","scanID":"tx%d","status":"owned"}`, bed.tx)))
+
+			bed.as(carrierIdentity)
+			bed.mustInvoke("claimContainer", "pallet-1", "Frankfurt")
+			bed.clock.Add(24 * time.Hour)
+			Expect(recordScan("london").Status).To(BeEquivalentTo(200))
+
+			shipment := getShipment()
+			Expect(shipment.WaypointsReached).To(Equal(2))
+			Expect(shipment.ArrivedAt).To(BeEquivalentTo(time.Date(2019, 3, 15, 12, 0, 0, 0, time.UTC).Unix()))
+			Expect(shipment.Deviations).To(BeEmpty())
+			Expect(getExceptions()).To(BeEmpty())
+		})
+
+		g.It("should flag unexpected custodians and locations as route deviations", func() {
+			bed.as(org1Identity)
+			bed.mustInvoke("claimContainer", "pallet-1", "Lyon")
+
+			bed.as(carrierIdentity)
+			response := recordScan("Lyon")
+			var scan map[string]interface{}
+			json.Unmarshal(response.Payload, &scan)
+			Expect(response.Status).To(BeEquivalentTo(200))
+			Expect(scan["deviations"]).To(HaveLen(1))
+
+			shipment := getShipment()
+			Expect(shipment.Deviations).To(Equal([]Deviation{
+				{Type: DeviationCustodian, TrackingID: "pallet-1", Custodian: producer, Location: "Lyon", Timestamp: shipment.Timestamp},
+				{Type: DeviationLocation, TrackingID: "pallet-1", Custodian: producer, Location: "Lyon", Timestamp: shipment.Timestamp},
+				{Type: DeviationLocation, TrackingID: "pallet-1", Location: "Lyon", Timestamp: shipment.Timestamp},
+			}))
+			Expect(getExceptions()).To(HaveLen(1))
+		})
+
+		g.It("should flag going back to an earlier waypoint", func() {
+			recordScan("Frankfurt")
+			bed.clock.Add(time.Minute)
+			recordScan("Basel")
+
+			shipment := getShipment()
+			Expect(shipment.WaypointsReached).To(Equal(2))
+			Expect(shipment.Deviations).To(HaveLen(1))
+			Expect(shipment.Deviations[0].Type).To(Equal(DeviationOrder))
+		})
+
+		g.It("should not track shipments from plain scans or from recorded scans by non-participants", func() {
+			Expect(bed.invoke("scan", "pallet-1", "Lyon").Status).To(BeEquivalentTo(500))
+
+			bed.as(retailerIdentity)
+			response := recordScan("Lyon")
+			var scan map[string]interface{}
+			json.Unmarshal(response.Payload, &scan)
+			Expect(response.Status).To(BeEquivalentTo(200))
+			Expect(scan["deviations"]).To(BeNil())
+			Expect(scan["anomalies"]).To(ContainElement(AnomalyNonParticipant))
+
+			bed.as(manufacturerIdentity)
+			shipment := getShipment()
+			Expect(shipment.WaypointsReached).To(Equal(0))
+			Expect(shipment.Deviations).To(BeEmpty())
+		})
+
+		g.It("should list shipments that have not arrived by their ETA", func() {
+			Expect(getExceptions()).To(BeEmpty())
+			bed.clock.Add(72 * time.Hour)
+			shipments := getExceptions()
+			Expect(shipments).To(HaveLen(1))
+			Expect(shipments[0].ID).To(Equal("shipment-1"))
+		})
+
+		g.It("should not ship a container that is already on its way", func() {
+			response := bed.invoke("createShipment", `{"shipmentID":"shipment-2","containers":["pallet-1"],"origin":"Zurich","destination":"Paris","eta":"2019-03-16T12:00:00Z"}`)
+			Expect(response.Status).To(BeEquivalentTo(403))
+
+			response = bed.invoke("createShipment", `{"shipmentID":"shipment-2","containers":["pallet-1"],"origin":"Zurich","destination":"Paris","eta":"tomorrow"}`)
+			Expect(response.Status).To(BeEquivalentTo(400))
+		})
+
+		g.It("should return 404 if the identity is not a party to the shipment", func() {
+			bed.as(org1Identity)
+			Expect(bed.invoke("getShipment", "shipment-1").Status).To(BeEquivalentTo(404))
+		})
+	})
+}
@@ -13,6 +13,7 @@ import (
 type SmartContract struct {
 	logger *shim.ChaincodeLogger
 	clock  clock.Clock
+	escrow func(chaincode string, channel string) Escrow
 }
 
 // Init is called during chaincode instantiation to initialize any
@@ -20,6 +21,7 @@ type SmartContract struct {
 func (s *SmartContract) Init(stub shim.ChaincodeStubInterface) peer.Response {
 	s.logger = shim.NewLogger("supplychain")
 	s.clock = clock.New()
+	s.escrow = newChaincodeEscrow
 	return shim.Success(nil)
 }
 
@@ -34,6 +36,30 @@ func (s *SmartContract) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
 		return s.Init(stub)
 	case "scan":
 		return s.scan(stub, args)
+	case "recordScan":
+		return s.recordScan(stub, args)
+	case "getScanHistory":
+		return s.getScanHistory(stub, args)
+	case "getSuspiciousItems":
+		return s.getSuspiciousItems(stub)
+	case "verifyProduct":
+		return s.verifyProduct(stub, args)
+	case "setPublicFields":
+		return s.setPublicFields(stub, args)
+	case "getTransactionInformation":
+		return s.getTransactionInformation(stub, args)
+	case "verificationRequest":
+		return s.verificationRequest(stub, args)
+	case "respondVerification":
+		return s.respondVerification(stub, args)
+	case "getVerificationRequest":
+		return s.getSingleVerificationRequest(stub, args)
+	case "reportSuspectProduct":
+		return s.reportSuspectProduct(stub, args)
+	case "concludeInvestigation":
+		return s.concludeInvestigation(stub, args)
+	case "getInvestigation":
+		return s.getSingleInvestigation(stub, args)
 	case "createProduct":
 		return s.createProduct(stub, args)
 	case "getProduct":
@@ -41,14 +67,92 @@ func (s *SmartContract) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
 			return s.getSingleProduct(stub, args)
 		}
 		return s.getAllProducts(stub, args)
+	case "getProductsBy":
+		return s.getProductsBy(stub, args)
 	case "getContainerlessProducts":
 		return s.getContainerlessProducts(stub)
+	case "getProductsByLot":
+		return s.getProductsByLot(stub, args)
+	case "getInventoryByExpiry":
+		return s.getInventoryByExpiry(stub, args)
+	case "assembleProduct":
+		return s.assembleProduct(stub, args)
+	case "traceComponents":
+		return s.traceComponents(stub, args)
+	case "traceProducts":
+		return s.traceProducts(stub, args)
+	case "recallProduct":
+		return s.recallProduct(stub, args)
+	case "splitProduct":
+		return s.splitProduct(stub, args)
+	case "getMassBalance":
+		return s.getMassBalance(stub, args)
+	case "transferOwnership":
+		return s.transferOwnership(stub, args)
+	case "getOwnershipHistory":
+		return s.getOwnershipHistory(stub, args)
+	case "redirectShipment":
+		return s.redirectShipment(stub, args)
+	case "sellProduct":
+		return s.sellProduct(stub, args)
+	case "setTelemetryRange":
+		return s.setTelemetryRange(stub, args)
+	case "recordTelemetry":
+		return s.recordTelemetry(stub, args)
+	case "getExcursions":
+		return s.getExcursions(stub, args)
+	case "registerDevice":
+		return s.registerDevice(stub, args)
+	case "bindDevice":
+		return s.bindDevice(stub, args)
+	case "rotateDeviceKey":
+		return s.rotateDeviceKey(stub, args)
+	case "revokeDevice":
+		return s.revokeDevice(stub, args)
+	case "getDevice":
+		return s.getSingleDevice(stub, args)
+	case "setHealthRules":
+		return s.setHealthRules(stub, args)
+	case "getHealthRules":
+		return s.getHealthRules(stub, args)
+	case "recordInspection":
+		return s.recordInspection(stub, args)
+	case "addCertification":
+		return s.addCertification(stub, args)
+	case "getQualityStatus":
+		return s.getQualityStatus(stub, args)
+	case "getInspections":
+		return s.getInspections(stub, args)
+	case "getCertifications":
+		return s.getCertifications(stub, args)
+	case "attachDocument":
+		return s.attachDocument(stub, args)
+	case "verifyDocument":
+		return s.verifyDocument(stub, args)
+	case "getDocuments":
+		return s.getDocuments(stub, args)
 	case "updateState":
 		return s.updateState(stub, args)
 	case "claimProduct":
 		return s.updateProductCustodian(stub, args)
 	case "claimContainer":
 		return s.updateContainerCustodian(stub, args)
+	case "setDwellSLAs":
+		return s.setDwellSLAs(stub, args)
+	case "getDwellSLAs":
+		return s.getDwellSLAs(stub, args)
+	case "getDwellTime":
+		return s.getDwellTime(stub, args)
+	case "getSLABreaches":
+		return s.getSLABreaches(stub, args)
+	case "getDwellStatistics":
+		return s.getDwellStatistics(stub, args)
+	case "getInventory":
+		return s.getInventory(stub, args)
+	case "reconcileInventory":
+		return s.reconcileInventory(stub, args)
+	case "getInventoryReconciliations":
+		return s.getInventoryReconciliations(stub)
 	case "createContainer":
 		return s.createContainer(stub, args)
 	case "getContainer":
@@ -56,14 +160,88 @@ func (s *SmartContract) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
 			return s.getSingleContainer(stub, args)
 		}
 		return s.getAllContainer(stub, args)
+	case "getContainersBy":
+		return s.getContainersBy(stub, args)
 	case "package":
 		return s.packageItem(stub, args)
+	case "defineContainerType":
+		return s.defineContainerType(stub, args)
+	case "getContainerTypes":
+		return s.getContainerTypes(stub, args)
+	case "setPackingRules":
+		return s.setPackingRules(stub, args)
+	case "getPackingRules":
+		return s.getPackingRules(stub, args)
 	case "unpackage":
 		return s.unpackageItem(stub, args)
+	case "createShipment":
+		return s.createShipment(stub, args)
+	case "getShipment":
+		return s.getSingleShipment(stub, args)
+	case "getShipmentExceptions":
+		return s.getShipmentExceptions(stub)
+	case "confirmDelivery":
+		return s.confirmDelivery(stub, args)
+	case "getDeliveries":
+		return s.getDeliveries(stub, args)
+	case "commentDiscrepancy":
+		return s.commentDiscrepancy(stub, args)
+	case "resolveDiscrepancy":
+		return s.resolveDiscrepancy(stub, args)
+	case "getDiscrepancy":
+		return s.getSingleDiscrepancy(stub, args)
+	case "createPurchaseOrder":
+		return s.createPurchaseOrder(stub, args)
+	case "acceptPurchaseOrder":
+		return s.acceptPurchaseOrder(stub, args)
+	case "linkPurchaseOrder":
+		return s.linkPurchaseOrder(stub, args)
+	case "getPurchaseOrder":
+		return s.getSinglePurchaseOrder(stub, args)
+	case "fundPurchaseOrder":
+		return s.fundPurchaseOrder(stub, args)
+	case "releasePurchaseOrder":
+		return s.releasePurchaseOrder(stub, args)
+	case "raiseDispute":
+		return s.raiseDispute(stub, args)
+	case "updateDispute":
+		return s.updateDispute(stub, args)
+	case "addDisputeEvidence":
+		return s.addDisputeEvidence(stub, args)
+	case "getDispute":
+		return s.getSingleDispute(stub, args)
+	case "getOpenDisputes":
+		return s.getOpenDisputes(stub)
+	case "setReturnPolicy":
+		return s.setReturnPolicy(stub, args)
+	case "requestReturn":
+		return s.requestReturn(stub, args)
+	case "approveReturn":
+		return s.approveReturn(stub, args)
+	case "rejectReturn":
+		return s.rejectReturn(stub, args)
+	case "receiveReturn":
+		return s.receiveReturn(stub, args)
+	case "getReturn":
+		return s.getSingleReturn(stub, args)
+	case "destroyAsset":
+		return s.destroyAsset(stub, args)
+	case "witnessDestruction":
+		return s.witnessDestruction(stub, args)
+	case "getDestructionCertificate":
+		return s.getDestructionCertificate(stub, args)
 	case "getIdentity":
 		return s.getIdentity(stub)
 	case "history":
 		return s.getHistory(stub, args)
+	case "exportEPCIS":
+		return s.exportEPCIS(stub, args)
+	case "importEPCIS":
+		return s.importEPCIS(stub, args)
+	case "setIdentifierScheme":
+		return s.setIdentifierScheme(stub, args)
+	case "issueSSCC":
+		return s.issueSSCC(stub, args)
 	default:
 		fmt.Printf("Function for Invoke invalid or missing: %s, %s", function, args)
 		return shim.Error(fmt.Sprintf("Function for Invoke invalid or missing: %s, %s", function, args))
@@ -0,0 +1,330 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// composite key object types of the telemetry records
+const (
+	telemetryRangeKey   = "telemetryRange"
+	excursionSummaryKey = "excursionSummary"
+)
+
+// setTelemetryRange sets the allowed readings of a product type, by product name, of the products manufactured by the
+// organization of the current user, or of a single container. Custodians can only narrow the range of a container.
+func (s *SmartContract) setTelemetryRange(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3")
+	}
+	scope := args[0]
+	key := args[1]
+
+	var allowed TelemetryRange
+	if err := json.Unmarshal([]byte(args[2]), &allowed); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := allowed.Validate(); err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+
+	rangeKey, _ := stub.CreateCompositeKey(telemetryRangeKey, []string{scope, key})
+	switch scope {
+	case "product":
+		if !identity.CanInvoke("setTelemetryRange") {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("You are not authorized to perform this transaction, cannot invoke setTelemetryRange"),
+			}
+		}
+		rangeKey, _ = stub.CreateCompositeKey(telemetryRangeKey, []string{scope, identity.Organization, key})
+	case "container":
+		containerBytes, _ := stub.GetState(key)
+		var container Container
+		if len(containerBytes) == 0 || json.Unmarshal(containerBytes, &container) != nil {
+			return peer.Response{
+				Status:  404,
+				Message: fmt.Sprintf("Container %s Not Found", key),
+			}
+		}
+		if identity.Cert.Subject.String() != container.Custodian {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("You are not authorized to perform this transaction, container not held by identity"),
+			}
+		}
+		existing, err := getRange(stub, rangeKey)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if existing != nil && !allowed.Within(*existing) {
+			return peer.Response{
+				Status:  403,
+				Message: fmt.Sprintf("You are not authorized to perform this transaction, the range of container %s can only be narrowed", key),
+			}
+		}
+	default:
+		return shim.Error(fmt.Sprintf("Unknown telemetry range scope %s, expecting product or container", scope))
+	}
+
+	rangeBytes, _ := json.Marshal(allowed)
+	if err := stub.PutState(rangeKey, rangeBytes); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	s.logger.Infof("Set telemetry range of %s %s\n", scope, key)
+	return shim.Success(rangeBytes)
+}
+
+// recordTelemetry records a batch of readings of a container, signed by the device bound to it. Readings outside
+// the allowed range of the container and the product types inside it mark the container and all its contents
+// as compromised.
+func (s *SmartContract) recordTelemetry(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 4")
+	}
+	containerID := args[0]
+	deviceID := args[2]
+
+	var readings []Reading
+	if err := json.Unmarshal([]byte(args[1]), &readings); err != nil {
+		return shim.Error(err.Error())
+	}
+	sort.Slice(readings, func(i, j int) bool { return readings[i].Timestamp < readings[j].Timestamp })
+
+	containerBytes, _ := stub.GetState(containerID)
+	var container Container
+	if len(containerBytes) == 0 || json.Unmarshal(containerBytes, &container) != nil {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Container %s Not Found", containerID),
+		}
+	}
+	if identity.Cert.Subject.String() != container.Custodian {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, container not held by identity"),
+		}
+	}
+
+	//only accept readings signed by the device bound to the container, newer than its last batch
+	device, response := verifyDeviceSignature(stub, deviceID, []byte(args[1]), args[3])
+	if device == nil {
+		return response
+	}
+	if device.ContainerID != containerID {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("Device %s is not bound to container %s", deviceID, containerID),
+		}
+	}
+	for i := range readings {
+		if readings[i].Timestamp <= device.LastReading {
+			return peer.Response{
+				Status:  400,
+				Message: fmt.Sprintf("Error: reading at %d is not newer than the last reading of device %s ", readings[i].Timestamp, deviceID),
+			}
+		}
+		if readings[i].DeviceID != "" && readings[i].DeviceID != deviceID {
+			return peer.Response{
+				Status:  400,
+				Message: fmt.Sprintf("Error: reading at %d is from device %s ", readings[i].Timestamp, readings[i].DeviceID),
+			}
+		}
+		readings[i].DeviceID = deviceID
+	}
+	for _, reading := range readings {
+		if reading.Timestamp > device.LastReading {
+			device.LastReading = reading.Timestamp
+		}
+	}
+	if err := putDevice(stub, *device); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	containers, products, err := getContainerTree(stub, containerID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	allowed, err := getTelemetryRange(stub, containers, products)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	summary, err := getExcursionSummary(stub, containerID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	excursions := DetectExcursions(containerID, readings, allowed, summary.Open(containerID))
+	reason := ExcursionReason(excursions)
+
+	//update the summary of every item in the container and mark them compromised on excursions, where the
+	//health rules of their manufacturer allow it, items the rules keep in their health are reported as skipped
+	var compromised []string
+	skipped := map[string]string{}
+	for _, item := range containers {
+		if err := updateExcursionSummary(stub, item.ID, readings, excursions); err != nil {
+			return shim.Error(err.Error())
+		}
+		if len(excursions) == 0 || NormalizeHealth(item.Health) == HealthCompromised {
+			continue
+		}
+		rules, err := loadHealthRules(stub, item.Organization)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if !rules.CanChange(item.Health, HealthCompromised) {
+			skipped[item.ID] = NormalizeHealth(item.Health)
+			continue
+		}
+		item.Health = HealthCompromised
+		item.HealthReason = reason
+		itemBytes, _ := json.Marshal(item)
+		if err := stub.PutState(item.ID, itemBytes); err != nil {
+			return shim.Error(err.Error())
+		}
+		compromised = append(compromised, item.ID)
+	}
+	for _, item := range products {
+		if err := updateExcursionSummary(stub, item.ID, readings, excursions); err != nil {
+			return shim.Error(err.Error())
+		}
+		if len(excursions) == 0 || NormalizeHealth(item.Health) == HealthCompromised {
+			continue
+		}
+		rules, err := loadHealthRules(stub, item.Manufacturer)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if !rules.CanChange(item.Health, HealthCompromised) {
+			skipped[item.ID] = NormalizeHealth(item.Health)
+			continue
+		}
+		item.Health = HealthCompromised
+		item.HealthReason = reason
+		itemBytes, _ := json.Marshal(item)
+		if err := stub.PutState(item.ID, itemBytes); err != nil {
+			return shim.Error(err.Error())
+		}
+		compromised = append(compromised, item.ID)
+	}
+	if len(skipped) != 0 {
+		s.logger.Warningf("Health rules kept %v from becoming compromised\n", skipped)
+	}
+
+	result := map[string]interface{}{
+		"device":      deviceID,
+		"readings":    len(readings),
+		"excursions":  excursions,
+		"compromised": compromised,
+		"skipped":     skipped,
+	}
+	bytes, _ := json.Marshal(result)
+
+	s.logger.Infof("Recorded %d readings for %s, %d excursions\n", len(readings), containerID, len(excursions))
+	return shim.Success(bytes)
+}
+
+// getExcursions retrieves the excursion summary of a product or container
+func (s *SmartContract) getExcursions(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	trackingID := args[0]
+
+	if !itemHasParticipant(stub, trackingID, identity.Cert.Subject.String()) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Item with trackingID %s not found", trackingID),
+		}
+	}
+
+	summary, err := getExcursionSummary(stub, trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	summaryBytes, _ := json.Marshal(summary)
+	return shim.Success(summaryBytes)
+}
+
+// getTelemetryRange returns the allowed range of a container tree, the intersection of the range of
+// every container and of the type of every product inside it set by its manufacturer
+func getTelemetryRange(stub shim.ChaincodeStubInterface, containers []Container, products []Product) (TelemetryRange, error) {
+	var allowed TelemetryRange
+	keys := [][]string{}
+	for _, container := range containers {
+		keys = append(keys, []string{"container", container.ID})
+	}
+	for _, product := range products {
+		keys = append(keys, []string{"product", product.Manufacturer, product.Name})
+	}
+	for _, key := range keys {
+		rangeKey, _ := stub.CreateCompositeKey(telemetryRangeKey, key)
+		other, err := getRange(stub, rangeKey)
+		if err != nil {
+			return allowed, err
+		}
+		if other != nil {
+			allowed.Intersect(*other)
+		}
+	}
+	return allowed, nil
+}
+
+func getRange(stub shim.ChaincodeStubInterface, rangeKey string) (*TelemetryRange, error) {
+	rangeBytes, err := stub.GetState(rangeKey)
+	if err != nil || len(rangeBytes) == 0 {
+		return nil, err
+	}
+	var allowed TelemetryRange
+	err = json.Unmarshal(rangeBytes, &allowed)
+	return &allowed, err
+}
+
+func getExcursionSummary(stub shim.ChaincodeStubInterface, trackingID string) (ExcursionSummary, error) {
+	summary := ExcursionSummary{Type: excursionSummaryKey, ID: trackingID, Excursions: []Excursion{}}
+	summaryKey, _ := stub.CreateCompositeKey(excursionSummaryKey, []string{trackingID})
+	summaryBytes, err := stub.GetState(summaryKey)
+	if err != nil || len(summaryBytes) == 0 {
+		return summary, err
+	}
+	err = json.Unmarshal(summaryBytes, &summary)
+	return summary, err
+}
+
+func updateExcursionSummary(stub shim.ChaincodeStubInterface, trackingID string, readings []Reading, excursions []Excursion) error {
+	summary, err := getExcursionSummary(stub, trackingID)
+	if err != nil {
+		return err
+	}
+	summary.Record(readings, excursions)
+	summaryKey, _ := stub.CreateCompositeKey(excursionSummaryKey, []string{trackingID})
+	summaryBytes, _ := json.Marshal(summary)
+	return stub.PutState(summaryKey, summaryBytes)
+}
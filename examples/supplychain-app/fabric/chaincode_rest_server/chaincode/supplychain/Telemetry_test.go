@@ -0,0 +1,169 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	"github.com/hyperledger/fabric/protos/peer"
+	. "github.com/onsi/gomega"
+)
+
+func TestTelemetry(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	loggerKey := newDeviceKey()
+	chaincode := new(SmartContract)
+	manufacturer := manufacturerIdentity.subject()
+
+	recordTelemetry := func(readings string) peer.Response {
+		return bed.invoke("recordTelemetry", "cooler-1", readings, "logger-1", signECDSA(loggerKey, readings))
+	}
+	getProduct := func() Product {
+		var product Product
+		json.Unmarshal(bed.mustInvoke("getProduct", "insulin-1"), &product)
+		return product
+	}
+	getContainer := func() Container {
+		var container Container
+		json.Unmarshal(bed.mustInvoke("getContainer", "cooler-1"), &container)
+		return container
+	}
+
+	g.Describe("Record Telemetry", func() {
+		//the holder of the cooler takes the insulin of Org1, packages it and binds a logger to the cooler
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC))
+			bed.as(org1Identity)
+			bed.mustInvoke("createProduct", `{"trackingID":"insulin-1","productName":"Insulin","counterparties":["`+manufacturer+`"]}`)
+			bed.as(manufacturerIdentity)
+			bed.mustInvoke("claimProduct", "insulin-1", "Zurich")
+			bed.mustInvoke("createContainer", `{"trackingID":"cooler-1","counterparties":[]}`)
+			bed.mustInvoke("package", "cooler-1", "insulin-1")
+
+			bed.mustInvoke("setTelemetryRange", "container", "cooler-1", `{"minTemperature":2,"maxTemperature":8}`)
+			request := DeviceRequest{ID: "logger-1", PublicKey: publicKeyPEM(&loggerKey.PublicKey), ContainerID: "cooler-1"}
+			requestBytes, _ := json.Marshal(request)
+			bed.mustInvoke("registerDevice", string(requestBytes))
+		})
+
+		g.It("should not compromise items for readings in range", func() {
+			response := recordTelemetry(`[{"timestamp":1,"temperature":4.5},{"timestamp":2,"temperature":7.9,"humidity":60}]`)
+
+			Expect(response.Status).To(BeEquivalentTo(200))
+			Expect(getProduct().Health).To(Equal(""))
+		})
+
+		g.It("should compromise the container and its contents on an excursion", func() {
+			response := recordTelemetry(`[{"timestamp":1,"temperature":4.5},{"timestamp":2,"temperature":9.5},{"timestamp":3,"temperature":11},{"timestamp":4,"temperature":6}]`)
+			Expect(response.Status).To(BeEquivalentTo(200))
+
+			Expect(getProduct().Health).To(Equal(HealthCompromised))
+			Expect(getContainer().Health).To(Equal(HealthCompromised))
+
+			var summary ExcursionSummary
+			json.Unmarshal(bed.mustInvoke("getExcursions", "insulin-1"), &summary)
+			Expect(summary.Readings).To(Equal(4))
+			Expect(summary.Excursions).To(Equal([]Excursion{{
+				ContainerID: "cooler-1",
+				Metric:      MetricTemperature,
+				Start:       2,
+				End:         3,
+				Readings:    2,
+				Worst:       11,
+			}}))
+		})
+
+		g.It("should continue an excursion still open at the end of the last batch", func() {
+			response := recordTelemetry(`[{"timestamp":3,"temperature":11},{"timestamp":1,"temperature":4.5},{"timestamp":2,"temperature":9.5}]`)
+			Expect(response.Status).To(BeEquivalentTo(200))
+			response = recordTelemetry(`[{"timestamp":5,"temperature":5},{"timestamp":4,"temperature":12}]`)
+			Expect(response.Status).To(BeEquivalentTo(200))
+
+			var summary ExcursionSummary
+			json.Unmarshal(bed.mustInvoke("getExcursions", "insulin-1"), &summary)
+			Expect(summary.Readings).To(Equal(5))
+			Expect(summary.Excursions).To(Equal([]Excursion{{
+				ContainerID: "cooler-1",
+				Metric:      MetricTemperature,
+				Start:       2,
+				End:         4,
+				Readings:    3,
+				Worst:       12,
+			}}))
+		})
+
+		g.It("should only apply the product range set by the manufacturer of the product", func() {
+			bed.as(testIdentity{mspID: "Org2MSP", certPath: org1Identity.certPath})
+			bed.mustInvoke("setTelemetryRange", "product", "Insulin", `{"maxTemperature":3}`)
+			bed.as(org1Identity)
+			bed.mustInvoke("setTelemetryRange", "product", "Insulin", `{"maxTemperature":5}`)
+
+			bed.as(manufacturerIdentity)
+			response := recordTelemetry(`[{"timestamp":1,"temperature":4},{"timestamp":2,"temperature":6}]`)
+
+			var result map[string][]Excursion
+			json.Unmarshal(response.Payload, &result)
+			Expect(response.Status).To(BeEquivalentTo(200))
+			Expect(result["excursions"]).To(HaveLen(1))
+			Expect(result["excursions"][0].Start).To(BeEquivalentTo(2))
+		})
+
+		g.It("should return 403 if the custodian widens the range of the container", func() {
+			response := bed.invoke("setTelemetryRange", "container", "cooler-1", `{"minTemperature":0,"maxTemperature":8}`)
+			Expect(response.Status).To(BeEquivalentTo(403))
+			response = bed.invoke("setTelemetryRange", "container", "cooler-1", `{"maxTemperature":8}`)
+			Expect(response.Status).To(BeEquivalentTo(403))
+
+			bed.mustInvoke("setTelemetryRange", "container", "cooler-1", `{"minTemperature":3,"maxTemperature":8}`)
+		})
+
+		g.It("should mark humidity excursions and report items the health rules keep from being compromised", func() {
+			rules := DefaultHealthRules()
+			rules.Transitions[HealthOK] = []string{HealthDestroyed}
+			rulesBytes, _ := json.Marshal(rules)
+			bed.as(org1Identity)
+			bed.mustInvoke("setHealthRules", string(rulesBytes))
+			bed.mustInvoke("setTelemetryRange", "product", "Insulin", `{"maxHumidity":70}`)
+
+			bed.as(manufacturerIdentity)
+			response := recordTelemetry(`[{"timestamp":1,"temperature":4.5,"humidity":80}]`)
+			Expect(response.Status).To(BeEquivalentTo(200))
+
+			var result struct {
+				Compromised []string          `json:"compromised"`
+				Skipped     map[string]string `json:"skipped"`
+			}
+			json.Unmarshal(response.Payload, &result)
+			Expect(result.Compromised).To(Equal([]string{"cooler-1"}))
+			Expect(result.Skipped).To(Equal(map[string]string{"insulin-1": HealthOK}))
+			Expect(getContainer().HealthReason).To(Equal(ReasonHumidityExcursion))
+		})
+
+		g.It("should return 400 for readings that are not newer than the last batch", func() {
+			readings := `[{"timestamp":1,"temperature":4.5},{"timestamp":2,"temperature":5}]`
+			Expect(recordTelemetry(readings).Status).To(BeEquivalentTo(200))
+
+			Expect(recordTelemetry(readings).Status).To(BeEquivalentTo(400))
+		})
+
+		g.It("should return 403 for readings with an invalid signature", func() {
+			signature := signECDSA(loggerKey, `[{"timestamp":1,"temperature":4.5}]`)
+			response := bed.invoke("recordTelemetry", "cooler-1", `[{"timestamp":1,"temperature":20}]`, "logger-1", signature)
+
+			Expect(response.Status).To(BeEquivalentTo(403))
+		})
+
+		g.It("should return 403 if the identity is not the custodian", func() {
+			bed.as(carrierIdentity)
+			response := recordTelemetry(`[{"timestamp":1,"temperature":4.5}]`)
+
+			Expect(response.Status).To(BeEquivalentTo(403))
+		})
+	})
+}
@@ -0,0 +1,157 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	. "github.com/chaincode/common"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// verifyProduct returns the public fields of a product to any identity, including consumers that are not
+// participants. Unknown trackingIDs are reported as not authentic.
+func (s *SmartContract) verifyProduct(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	//accept GS1 Digital Link URIs and element strings as trackingID
+	identifier, err := resolveIdentifier(stub, args[0])
+	if err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+	trackingID := identifier.TrackingID
+
+	productBytes, err := stub.GetState(trackingID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	var product Product
+	if len(productBytes) == 0 || json.Unmarshal(productBytes, &product) != nil {
+		s.logger.Warningf("Unknown product %s was verified\n", trackingID)
+		viewBytes, _ := json.Marshal(PublicView{TrackingID: trackingID})
+		return shim.Success(viewBytes)
+	}
+
+	var history []History
+	if product.Discloses(PublicJourney) {
+		history, err = getCustodyHistory(stub, trackingID)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("Error getting history: %s", err))
+		}
+	}
+	viewBytes, _ := json.Marshal(product.PublicView(s.clock.Now(), history))
+	return shim.Success(viewBytes)
+}
+
+// setPublicFields chooses the fields of a product disclosed by verifyProduct (manufacturers only)
+func (s *SmartContract) setPublicFields(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//get user identity
+	identity, err := GetInvokerIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Error getting invoker identity: %s\n", err.Error()))
+	}
+
+	if !identity.CanInvoke("setPublicFields") {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, cannot invoke setPublicFields"),
+		}
+	}
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	trackingID := args[0]
+
+	var fields []string
+	if err := json.Unmarshal([]byte(args[1]), &fields); err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(fields) == 0 {
+		err = errors.New("At least one public field is required")
+	} else {
+		err = ValidatePublicFields(fields)
+	}
+	if err != nil {
+		return peer.Response{
+			Status:  400,
+			Message: fmt.Sprintf("Error: %s ", err),
+		}
+	}
+
+	productBytes, _ := stub.GetState(trackingID)
+	var product Product
+	if len(productBytes) == 0 || json.Unmarshal(productBytes, &product) != nil || !product.AccessibleBy(identity) {
+		return peer.Response{
+			Status:  404,
+			Message: fmt.Sprintf("Product %s Not Found", trackingID),
+		}
+	}
+	manufacturer, err := resolveManufacturer(stub, &product, identity)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if manufacturer != identity.Organization {
+		return peer.Response{
+			Status:  403,
+			Message: fmt.Sprintf("You are not authorized to perform this transaction, product not manufactured by organization"),
+		}
+	}
+
+	//the update records the manufacturer of products created before it was kept
+	product.Manufacturer = manufacturer
+	product.PublicFields = fields
+	product.Timestamp = int64(s.clock.Now().UTC().Unix())
+	if response := putProducts(stub, product); response.Status != shim.OK {
+		return response
+	}
+	productBytes, _ = json.Marshal(product)
+	return shim.Success(productBytes)
+}
+
+// getCustodyHistory returns the custody changes of an item in the order they were committed
+func getCustodyHistory(stub shim.ChaincodeStubInterface, trackingID string) ([]History, error) {
+	iterator, err := stub.GetHistoryForKey(trackingID)
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+	history := []History{}
+	for iterator.HasNext() {
+		record, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var item History
+		if err := json.Unmarshal(record.Value, &item); err != nil {
+			continue
+		}
+		if len(history) == 0 || history[len(history)-1] != item {
+			history = append(history, item)
+		}
+	}
+	return history, nil
+}
+
+// resolveManufacturer returns the organization that manufactured the product. Products created before their
+// manufacturer was recorded fall back to their first custodian, who created them, which resolves to the organization
+// of the current user if it is that custodian and to no organization otherwise.
+func resolveManufacturer(stub shim.ChaincodeStubInterface, product *Product, identity *Identity) (string, error) {
+	if product.Manufacturer != "" {
+		return product.Manufacturer, nil
+	}
+	history, err := getCustodyHistory(stub, product.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(history) == 0 || history[0].Custodian != identity.Cert.Subject.String() {
+		return "", nil
+	}
+	return identity.Organization, nil
+}
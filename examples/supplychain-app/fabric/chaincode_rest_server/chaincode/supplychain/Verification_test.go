@@ -0,0 +1,88 @@
+package supplychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/chaincode/common"
+
+	"github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestVerification(t *testing.T) {
+	g := goblin.Goblin(t)
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var bed *testbed
+	chaincode := new(SmartContract)
+	now := time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC)
+	carrier := carrierIdentity.subject()
+
+	verifyProduct := func(trackingID string) string {
+		return string(bed.mustInvoke("verifyProduct", trackingID))
+	}
+
+	g.Describe("Verify Product", func() {
+		//Org1 makes an insulin pen the carrier takes custody of, consumers verify it as the retailer does
+		g.BeforeEach(func() {
+			bed = newTestbed(chaincode, now)
+			bed.as(org1Identity)
+			bed.mustInvoke("createProduct", `{"trackingID":"pen-1","productName":"Insulin Pen","misc":{"batchCost":1200},"expiry":"2020-06-30","counterparties":["`+carrier+`"]}`)
+			bed.as(carrierIdentity)
+			bed.mustInvoke("claimProduct", "pen-1", "51.50/-0.13/London")
+			bed.as(org1Identity)
+		})
+
+		g.It("should disclose only the default public fields to a consumer", func() {
+			bed.as(retailerIdentity)
+			Expect(verifyProduct("pen-1")).To(Equal(
+				`{"trackingID":"pen-1","authentic":true,"manufacturer":"Org1MSP","productName":"Insulin Pen","status":"ok","expiry":"2020-06-30"}`))
+
+			bed.as(org1Identity)
+			bed.mustInvoke("recallProduct", "pen-1")
+			bed.as(retailerIdentity)
+			Expect(verifyProduct("pen-1")).To(ContainSubstring(`"status":"recalled"`))
+		})
+
+		g.It("should report unknown products as not authentic", func() {
+			bed.as(retailerIdentity)
+			Expect(verifyProduct("pen-2")).To(Equal(`{"trackingID":"pen-2","authentic":false}`))
+		})
+
+		g.It("should let the manufacturer choose the public fields", func() {
+			bed.mustInvoke("setPublicFields", "pen-1", `["productName"]`)
+
+			bed.as(retailerIdentity)
+			Expect(verifyProduct("pen-1")).To(Equal(`{"trackingID":"pen-1","authentic":true,"productName":"Insulin Pen"}`))
+			Expect(bed.invoke("setPublicFields", "pen-1", `["status"]`).Status).To(BeEquivalentTo(403))
+		})
+
+		g.It("should return 400 for fields that cannot be disclosed", func() {
+			Expect(bed.invoke("setPublicFields", "pen-1", `["custodian"]`).Status).To(BeEquivalentTo(400))
+			Expect(bed.invoke("setPublicFields", "pen-1", `[]`).Status).To(BeEquivalentTo(400))
+		})
+	})
+
+	g.Describe("Products without a manufacturer", func() {
+		g.BeforeEach(func() {
+			bed = newLedgerTestbed(now)
+			bed.as(org1Identity)
+			bed.mustInvoke("createProduct", `{"trackingID":"pen-2","productName":"Insulin Pen","lastScannedAt":"Zurich","counterparties":["`+carrier+`"]}`)
+			//drop the manufacturer as products created before it was recorded lack it
+			var product Product
+			json.Unmarshal(bed.stub.State["pen-2"], &product)
+			product.Manufacturer = ""
+			productBytes, _ := json.Marshal(product)
+			bed.stub.State["pen-2"] = productBytes
+		})
+
+		g.It("should fall back to the creator of the product and record its organization as manufacturer", func() {
+			bed.mustInvoke("setPublicFields", "pen-2", `["productName","manufacturer"]`)
+
+			bed.as(retailerIdentity)
+			Expect(verifyProduct("pen-2")).To(Equal(`{"trackingID":"pen-2","authentic":true,"manufacturer":"Org1MSP","productName":"Insulin Pen"}`))
+		})
+	})
+}